@@ -14,6 +14,7 @@ import (
 	"github.com/getfinn/finn/internal/devserver"
 	"github.com/getfinn/finn/internal/tunnel"
 	"github.com/getfinn/finn/internal/ui"
+	"github.com/getfinn/finn/internal/usage"
 	"github.com/getfinn/finn/internal/watcher"
 	ws "github.com/getfinn/finn/internal/websocket"
 )
@@ -23,9 +24,13 @@ type ConversationState struct {
 	executor     claude.TaskRunner
 	pendingDiffs map[string]bool // file_path -> approved
 	totalDiffs   int
-	folderPath   string   // Track folder path for reprompts
-	folderID     string   // Track folder ID for commit tracking
-	files        []string // Files modified in this conversation (for selective discard)
+	folderPath   string         // Track folder path for reprompts
+	folderID     string         // Track folder ID for commit tracking
+	files        []string       // Files modified in this conversation (for selective discard)
+	prompt       string         // Last prompt started, re-run if a parked task never got a session
+	conflicts    []string       // Conflicted files this conversation resolves (resolve_conflicts)
+	blocked      *blockedCommit // Approval commit refused by a hook (see agent_hooks.go)
+	rateLimited  int            // Consecutive rate-limited parks, for backoff (see agent_quota.go)
 }
 
 // Agent is the main daemon agent that orchestrates all operations.
@@ -37,9 +42,11 @@ type Agent struct {
 	tray               *ui.TrayUI
 	isRunning          bool
 	headless           bool
-	executors          map[string]claude.TaskRunner  // conversation_id -> executor
-	conversationStates map[string]*ConversationState // conversation_id -> state
+	executors          map[string]claude.TaskRunner  // conversation_id -> executor (guarded by conversationsMu)
+	conversationStates map[string]*ConversationState // conversation_id -> state (guarded by conversationsMu)
+	conversationsMu    sync.Mutex                    // Held only around map access, never across calls
 	sessionWatcher     *watcher.Watcher              // Watches ~/.claude/projects for external sessions
+	toolOutputs        *claude.ToolOutputStore       // Full tool outputs for get_tool_output
 
 	// Client presence tracking (for skipping broadcasts when no listeners)
 	mobileOnline bool
@@ -56,6 +63,25 @@ type Agent struct {
 	lastKnownHeads   map[string]string
 	lastKnownHeadsMu sync.RWMutex
 	gitSyncStop      chan struct{} // Signal to stop git sync goroutine
+
+	// Tasks waiting for the Claude usage limit to reset (conversationID -> task)
+	parkedTasks map[string]parkedTask
+	parkedMu    sync.Mutex
+	quotaStop   chan struct{} // Signal to stop quota scheduler goroutine
+
+	// Claude CLI processes counted by the governor (see agent_governor.go)
+	processes   map[*cliProcess]bool
+	processesMu sync.Mutex
+	reaperStop  chan struct{} // Signal to stop idle process reaper goroutine
+
+	// Cost and token ledger with per-folder budgets (see agent_usage.go)
+	usageLedger       *usage.Ledger
+	conversationUsage map[string]*conversationUsage
+	usageMu           sync.Mutex
+
+	// Commits made by approvals, for "undo last Finn commit" (see agent_revert.go)
+	finnCommits   map[string][]string // folder_id -> full hashes, oldest first
+	finnCommitsMu sync.Mutex
 }
 
 // New creates a new agent instance.
@@ -65,19 +91,96 @@ func New(headless bool, dev bool) (*Agent, error) {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
+	usageLedger, err := usage.Open(usageLedgerPath())
+	if err != nil {
+		// Start a fresh ledger rather than refusing to run
+		log.Printf("⚠️  %v", err)
+	}
+
 	return &Agent{
 		cfg:                cfg,
 		isRunning:          false,
 		headless:           headless,
 		executors:          make(map[string]claude.TaskRunner),
 		conversationStates: make(map[string]*ConversationState),
+		toolOutputs:        claude.NewToolOutputStore(0),
 		tunnels:            make(map[string]*tunnel.Client),
 		devServers:         devserver.NewManager(),
 		lastKnownHeads:     make(map[string]string),
 		gitSyncStop:        make(chan struct{}),
+		quotaStop:          make(chan struct{}),
+		processes:          make(map[*cliProcess]bool),
+		reaperStop:         make(chan struct{}),
+		usageLedger:        usageLedger,
+		conversationUsage:  make(map[string]*conversationUsage),
+		finnCommits:        loadFinnCommits(),
 	}, nil
 }
 
+// executor returns a conversation's executor, nil if it has none
+func (a *Agent) executor(conversationID string) claude.TaskRunner {
+	a.conversationsMu.Lock()
+	defer a.conversationsMu.Unlock()
+	return a.executors[conversationID]
+}
+
+// conversationState returns a conversation's state, nil if it has none
+func (a *Agent) conversationState(conversationID string) *ConversationState {
+	a.conversationsMu.Lock()
+	defer a.conversationsMu.Unlock()
+	return a.conversationStates[conversationID]
+}
+
+// setExecutor registers a conversation's executor, keeping its state
+func (a *Agent) setExecutor(conversationID string, executor claude.TaskRunner) {
+	a.conversationsMu.Lock()
+	defer a.conversationsMu.Unlock()
+	a.executors[conversationID] = executor
+}
+
+// setConversation registers a conversation's executor and state
+func (a *Agent) setConversation(conversationID string, executor claude.TaskRunner, state *ConversationState) {
+	a.conversationsMu.Lock()
+	defer a.conversationsMu.Unlock()
+	a.executors[conversationID] = executor
+	a.conversationStates[conversationID] = state
+}
+
+// removeExecutor forgets a conversation's executor, keeping its state
+func (a *Agent) removeExecutor(conversationID string) {
+	a.conversationsMu.Lock()
+	defer a.conversationsMu.Unlock()
+	delete(a.executors, conversationID)
+}
+
+// removeConversation forgets a conversation's executor and state
+func (a *Agent) removeConversation(conversationID string) {
+	a.conversationsMu.Lock()
+	defer a.conversationsMu.Unlock()
+	delete(a.executors, conversationID)
+	delete(a.conversationStates, conversationID)
+}
+
+// activeConversation is a conversation's executor and state (nil if it has
+// none) as of a snapshot
+type activeConversation struct {
+	id       string
+	executor claude.TaskRunner
+	state    *ConversationState
+}
+
+// activeConversations snapshots the conversations with an executor, for
+// walking them without holding the lock
+func (a *Agent) activeConversations() []activeConversation {
+	a.conversationsMu.Lock()
+	defer a.conversationsMu.Unlock()
+	conversations := make([]activeConversation, 0, len(a.executors))
+	for id, executor := range a.executors {
+		conversations = append(conversations, activeConversation{id, executor, a.conversationStates[id]})
+	}
+	return conversations
+}
+
 // Start starts the agent and all its subsystems.
 func (a *Agent) Start() error {
 	log.Println("🚀 PocketVibe Desktop Daemon starting...")
@@ -152,10 +255,27 @@ func (a *Agent) Start() error {
 		}
 	}()
 
+	// Report Claude Code CLI state up front so setup problems show in the log
+	go func() {
+		health := claude.CheckHealth()
+		if health.Error != nil {
+			log.Printf("⚠️  Claude Code: %s - %s", health.Error.Message, health.Error.Action)
+		} else {
+			log.Printf("✅ Claude Code %s (auth: %s)", health.Version, health.Auth)
+		}
+	}()
+
 	// Start background subsystems
 	go a.monitorConnection()
 	go a.startGitSyncChecker()
 
+	// Resume tasks parked on a usage limit, including ones from a previous run
+	a.loadParkedTasks()
+	go a.startQuotaScheduler()
+
+	// Stop CLI processes of conversations left idle
+	go a.startProcessReaper()
+
 	// Initialize session watcher for external Claude Code sessions
 	a.initSessionWatcher()
 
@@ -194,6 +314,8 @@ func (a *Agent) handleQuit() {
 
 	// Stop git sync checker
 	close(a.gitSyncStop)
+	close(a.quotaStop)
+	close(a.reaperStop)
 
 	// Close all tunnel connections
 	a.closeAllTunnels()
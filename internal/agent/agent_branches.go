@@ -0,0 +1,254 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/getfinn/finn/internal/claude"
+	"github.com/getfinn/finn/internal/git"
+	ws "github.com/getfinn/finn/internal/websocket"
+)
+
+// Branch actions reported in branch_response
+const (
+	branchCreate = "create"
+	branchSwitch = "switch"
+	branchDelete = "delete"
+)
+
+// handleListBranches sends the local and remote branches of a folder.
+func (a *Agent) handleListBranches(msg *ws.Message) {
+	var payload struct {
+		FolderID string `json:"folder_id"`
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("Failed to parse list_branches payload: %v", err)
+		return
+	}
+
+	folder := a.cfg.GetFolderByID(payload.FolderID)
+	if folder == nil {
+		log.Printf("❌ Folder not found: %s", payload.FolderID)
+		a.sendBranchesList(payload.FolderID, "", []git.Branch{}, "Folder not found")
+		return
+	}
+	if !git.IsGitRepo(folder.Path) {
+		a.sendBranchesList(payload.FolderID, "", []git.Branch{}, "Not a git repository")
+		return
+	}
+
+	a.sendFolderBranches(payload.FolderID, folder.Path)
+}
+
+// sendFolderBranches lists the branches of a folder and sends them
+func (a *Agent) sendFolderBranches(folderID, folderPath string) {
+	repo := git.NewRepository(folderPath)
+	branches, err := repo.ListBranches()
+	if err != nil {
+		log.Printf("❌ Failed to list branches: %v", err)
+		a.sendBranchesList(folderID, "", []git.Branch{}, err.Error())
+		return
+	}
+	current, _ := repo.GetCurrentBranch()
+
+	log.Printf("🌿 Found %d branches in %s (current: %s)", len(branches), folderPath, current)
+	a.sendBranchesList(folderID, current, branches, "")
+}
+
+// sendBranchesList sends the branches of a folder, or why they couldn't be listed.
+func (a *Agent) sendBranchesList(folderID, current string, branches []git.Branch, errMsg string) {
+	data := map[string]interface{}{
+		"folder_id":      folderID,
+		"current_branch": current,
+		"branches":       branches,
+	}
+	if errMsg != "" {
+		data["error"] = errMsg
+	}
+	payload, _ := json.Marshal(data)
+
+	msg := &ws.Message{
+		UserID:     a.cfg.UserID,
+		DeviceType: "desktop",
+		Type:       ws.MessageTypeBranchesList,
+		Payload:    payload,
+	}
+
+	if err := a.wsClient.SendMessage(msg); err != nil {
+		log.Printf("Failed to send branches list: %v", err)
+	}
+}
+
+// handleCreateBranch creates a branch, optionally checking it out.
+// Uncommitted changes carry over to the new branch.
+func (a *Agent) handleCreateBranch(msg *ws.Message) {
+	var payload struct {
+		FolderID   string `json:"folder_id"`
+		Name       string `json:"name"`
+		StartPoint string `json:"start_point,omitempty"` // Branch or commit; HEAD if empty
+		Checkout   bool   `json:"checkout,omitempty"`
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("Failed to parse create_branch payload: %v", err)
+		return
+	}
+
+	repo, folderPath, ok := a.branchRepository(payload.FolderID, branchCreate, payload.Name, payload.Checkout)
+	if !ok {
+		return
+	}
+
+	if err := repo.CreateBranch(payload.Name, payload.StartPoint, payload.Checkout); err != nil {
+		log.Printf("❌ Failed to create branch %s: %v", payload.Name, err)
+		a.sendBranchResponse(payload.FolderID, branchCreate, payload.Name, err, "")
+		return
+	}
+
+	log.Printf("🌿 Created branch %s in %s", payload.Name, folderPath)
+	a.sendBranchResponse(payload.FolderID, branchCreate, payload.Name, nil, "")
+	a.afterBranchChange(payload.FolderID, folderPath)
+}
+
+// handleSwitchBranch checks out a branch. With uncommitted changes it refuses
+// (listing them) unless the request asks to stash them first.
+func (a *Agent) handleSwitchBranch(msg *ws.Message) {
+	var payload struct {
+		FolderID string `json:"folder_id"`
+		Name     string `json:"name"`
+		Stash    bool   `json:"stash,omitempty"` // Stash uncommitted changes and switch
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("Failed to parse switch_branch payload: %v", err)
+		return
+	}
+
+	repo, folderPath, ok := a.branchRepository(payload.FolderID, branchSwitch, payload.Name, true)
+	if !ok {
+		return
+	}
+
+	stash, err := repo.SwitchBranch(payload.Name, payload.Stash)
+	if err != nil {
+		log.Printf("❌ Failed to switch to %s: %v", payload.Name, err)
+		a.sendBranchResponse(payload.FolderID, branchSwitch, payload.Name, err, "")
+		return
+	}
+
+	if stash != "" {
+		log.Printf("📦 Stashed uncommitted changes: %s", stash)
+	}
+	log.Printf("🌿 Switched %s to %s", folderPath, payload.Name)
+	a.sendBranchResponse(payload.FolderID, branchSwitch, payload.Name, nil, stash)
+	a.afterBranchChange(payload.FolderID, folderPath)
+}
+
+// handleDeleteBranch deletes a local branch. Unmerged branches need force.
+func (a *Agent) handleDeleteBranch(msg *ws.Message) {
+	var payload struct {
+		FolderID string `json:"folder_id"`
+		Name     string `json:"name"`
+		Force    bool   `json:"force,omitempty"` // Delete even with unmerged commits
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("Failed to parse delete_branch payload: %v", err)
+		return
+	}
+
+	repo, folderPath, ok := a.branchRepository(payload.FolderID, branchDelete, payload.Name, false)
+	if !ok {
+		return
+	}
+
+	if err := repo.DeleteBranch(payload.Name, payload.Force); err != nil {
+		log.Printf("❌ Failed to delete branch %s: %v", payload.Name, err)
+		a.sendBranchResponse(payload.FolderID, branchDelete, payload.Name, err, "")
+		return
+	}
+
+	log.Printf("🗑️  Deleted branch %s in %s", payload.Name, folderPath)
+	a.sendBranchResponse(payload.FolderID, branchDelete, payload.Name, nil, "")
+	a.afterBranchChange(payload.FolderID, folderPath)
+}
+
+// branchRepository looks up the folder of a branch request, replying with
+// an error if it isn't usable. A checkout is refused while Claude is working
+// in the folder, since it would change the files under it.
+func (a *Agent) branchRepository(folderID, action, name string, checkout bool) (*git.Repository, string, bool) {
+	folder := a.cfg.GetFolderByID(folderID)
+	if folder == nil {
+		log.Printf("❌ Folder not found: %s", folderID)
+		a.sendBranchResponse(folderID, action, name, errors.New("Folder not found"), "")
+		return nil, "", false
+	}
+	if !git.IsGitRepo(folder.Path) {
+		a.sendBranchResponse(folderID, action, name, errors.New("Not a git repository"), "")
+		return nil, "", false
+	}
+	if checkout && a.taskRunningIn(folder.Path) {
+		log.Printf("⚠️  Refusing to check out %s while a task is running in %s", name, folder.Path)
+		a.sendBranchResponse(folderID, action, name, errors.New("A task is running in this folder"), "")
+		return nil, "", false
+	}
+	return git.NewRepository(folder.Path), folder.Path, true
+}
+
+// taskRunningIn reports whether Claude is in the middle of a task in a folder
+func (a *Agent) taskRunningIn(folderPath string) bool {
+	for _, conversation := range a.activeConversations() {
+		if conversation.state == nil || conversation.state.folderPath != folderPath {
+			continue
+		}
+		switch e := conversation.executor.(type) {
+		case *claude.InteractiveTaskExecutor:
+			if e.Busy() {
+				return true
+			}
+		default:
+			// One-shot executors are only kept while they run
+			return true
+		}
+	}
+	return false
+}
+
+// sendBranchResponse reports the outcome of a branch operation. A refusal
+// because of uncommitted changes lists them, so mobile can offer to stash.
+func (a *Agent) sendBranchResponse(folderID, action, branch string, err error, stash string) {
+	data := map[string]interface{}{
+		"folder_id": folderID,
+		"action":    action,
+		"branch":    branch,
+		"success":   err == nil,
+	}
+	if err != nil {
+		data["error"] = err.Error()
+		var dirty *git.DirtyTreeError
+		if errors.As(err, &dirty) {
+			data["dirty"] = true
+			data["changed_files"] = dirty.Files
+		}
+	}
+	if stash != "" {
+		data["stash"] = stash
+	}
+	payload, _ := json.Marshal(data)
+
+	msg := &ws.Message{
+		UserID:     a.cfg.UserID,
+		DeviceType: "desktop",
+		Type:       ws.MessageTypeBranchResponse,
+		Payload:    payload,
+	}
+
+	if err := a.wsClient.SendMessage(msg); err != nil {
+		log.Printf("Failed to send branch response: %v", err)
+	}
+}
+
+// afterBranchChange sends the updated branches and folder list (which shows
+// the current branch)
+func (a *Agent) afterBranchChange(folderID, folderPath string) {
+	a.sendFolderBranches(folderID, folderPath)
+	a.sendFolderListUpdate()
+}
@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/getfinn/finn/internal/claude"
+	ws "github.com/getfinn/finn/internal/websocket"
+)
+
+// handleListCommands sends the slash commands available in a folder.
+func (a *Agent) handleListCommands(msg *ws.Message) {
+	var payload struct {
+		FolderID string `json:"folder_id"`
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("Failed to parse list_commands payload: %v", err)
+		return
+	}
+
+	folder := a.cfg.GetFolderByID(payload.FolderID)
+	if folder == nil {
+		log.Printf("❌ Folder not found: %s", payload.FolderID)
+		a.sendCommandsList(payload.FolderID, []claude.Command{}, "Folder not found")
+		return
+	}
+
+	commands := claude.DiscoverCommands(folder.Path)
+	log.Printf("📋 Found %d commands for %s", len(commands), folder.Name)
+	a.sendCommandsList(payload.FolderID, commands, "")
+}
+
+// sendCommandsList sends the commands of a folder, or why they couldn't be listed.
+func (a *Agent) sendCommandsList(folderID string, commands []claude.Command, errMsg string) {
+	data := map[string]interface{}{
+		"folder_id": folderID,
+		"commands":  commands,
+	}
+	if errMsg != "" {
+		data["error"] = errMsg
+	}
+	payload, _ := json.Marshal(data)
+
+	msg := &ws.Message{
+		UserID:     a.cfg.UserID,
+		DeviceType: "desktop",
+		Type:       ws.MessageTypeCommandsList,
+		Payload:    payload,
+	}
+
+	if err := a.wsClient.SendMessage(msg); err != nil {
+		log.Printf("Failed to send commands list: %v", err)
+	}
+}
+
+// handleRunCommand runs a slash command in a conversation: in the running
+// conversation if there is one, otherwise as the start of a new one.
+func (a *Agent) handleRunCommand(msg *ws.Message) {
+	var payload struct {
+		ConversationID string `json:"conversation_id"`
+		FolderID       string `json:"folder_id"`
+		Command        string `json:"command"`             // e.g. "compact" or "/compact"
+		Arguments      string `json:"arguments,omitempty"` // Appended after the command
+		SessionID      string `json:"session_id,omitempty"`
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("Failed to parse run_command payload: %v", err)
+		return
+	}
+
+	folder := a.cfg.GetFolderByID(payload.FolderID)
+	if folder == nil {
+		log.Printf("❌ Folder not found or not approved: %s", payload.FolderID)
+		a.sendError(payload.ConversationID, "Folder not found or not approved")
+		return
+	}
+
+	command, ok := claude.FindCommand(folder.Path, payload.Command)
+	if !ok {
+		log.Printf("❌ Unknown command: %s", payload.Command)
+		a.sendError(payload.ConversationID, fmt.Sprintf("Unknown command: /%s", strings.TrimPrefix(payload.Command, "/")))
+		return
+	}
+
+	// One line: the CLI reads the whole message as the command's arguments
+	text := "/" + command.Name
+	if args := strings.Join(strings.Fields(payload.Arguments), " "); args != "" {
+		text += " " + args
+	}
+	log.Printf("⌨️  Running command in %s: %s", payload.ConversationID, text)
+
+	// Continue the running conversation, re-attaching to its session if needed
+	if state := a.conversationState(payload.ConversationID); state != nil {
+		if interactive, ok := state.executor.(*claude.InteractiveTaskExecutor); ok {
+			a.unparkTask(payload.ConversationID)
+			go func() {
+				if err := interactive.Continue(text); err != nil {
+					log.Printf("❌ Failed to run command: %v", err)
+					a.sendTaskError(payload.ConversationID, err)
+				}
+			}()
+			return
+		}
+	}
+
+	a.startPrompt(payload.ConversationID, payload.FolderID, text, payload.SessionID, true, nil)
+}
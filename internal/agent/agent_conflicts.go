@@ -0,0 +1,320 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/getfinn/finn/internal/event"
+	"github.com/getfinn/finn/internal/git"
+	ws "github.com/getfinn/finn/internal/websocket"
+)
+
+// Conflict actions for conflict_action and conflict_result
+const (
+	conflictContinue = "continue"
+	conflictAbort    = "abort"
+)
+
+// handleGetConflicts sends the conflicts of a folder, if any.
+func (a *Agent) handleGetConflicts(msg *ws.Message) {
+	var payload struct {
+		FolderID string `json:"folder_id"`
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("Failed to parse get_conflicts payload: %v", err)
+		return
+	}
+
+	folder := a.cfg.GetFolderByID(payload.FolderID)
+	if folder == nil {
+		log.Printf("❌ Folder not found: %s", payload.FolderID)
+		a.sendConflicts(payload.FolderID, nil, "Folder not found")
+		return
+	}
+	if !git.IsGitRepo(folder.Path) {
+		a.sendConflicts(payload.FolderID, nil, "Not a git repository")
+		return
+	}
+
+	a.sendFolderConflicts(payload.FolderID, folder.Path)
+}
+
+// sendFolderConflicts reads the conflicts of a folder and sends them
+func (a *Agent) sendFolderConflicts(folderID, folderPath string) {
+	conflicts, err := git.NewRepository(folderPath).GetConflicts()
+	if err != nil {
+		log.Printf("❌ Failed to read conflicts: %v", err)
+		a.sendConflicts(folderID, nil, err.Error())
+		return
+	}
+	if conflicts != nil {
+		log.Printf("⚔️  %d conflicted file(s) in %s (%s)", len(conflicts.Files), folderPath, conflicts.Operation)
+	}
+	a.sendConflicts(folderID, conflicts, "")
+}
+
+// sendConflicts sends the conflicted files of a folder with their hunks.
+// Without conflicts, files is empty.
+func (a *Agent) sendConflicts(folderID string, conflicts *git.ConflictState, errMsg string) {
+	data := map[string]interface{}{
+		"folder_id":     folderID,
+		"has_conflicts": conflicts != nil,
+		"operation":     git.OperationNone,
+		"files":         []git.FileConflict{},
+	}
+	if conflicts != nil {
+		data["operation"] = conflicts.Operation
+		data["files"] = conflicts.Files
+	}
+	if errMsg != "" {
+		data["error"] = errMsg
+	}
+	payload, _ := json.Marshal(data)
+
+	msg := &ws.Message{
+		UserID:     a.cfg.UserID,
+		DeviceType: "desktop",
+		Type:       ws.MessageTypeConflicts,
+		Payload:    payload,
+	}
+
+	if err := a.wsClient.SendMessage(msg); err != nil {
+		log.Printf("Failed to send conflicts: %v", err)
+	}
+}
+
+// handleResolveConflicts starts an interactive task that resolves the
+// conflicted files of a folder. Its result goes through the usual diff
+// review; approving continues the merge or rebase, rejecting aborts it.
+func (a *Agent) handleResolveConflicts(msg *ws.Message) {
+	var payload struct {
+		ConversationID string `json:"conversation_id"`
+		FolderID       string `json:"folder_id"`
+		Instructions   string `json:"instructions,omitempty"` // How to resolve, e.g. "prefer upstream"
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("Failed to parse resolve_conflicts payload: %v", err)
+		return
+	}
+
+	folder := a.cfg.GetFolderByID(payload.FolderID)
+	if folder == nil {
+		log.Printf("❌ Folder not found or not approved: %s", payload.FolderID)
+		a.sendError(payload.ConversationID, "Folder not found or not approved")
+		return
+	}
+	if a.taskRunningIn(folder.Path) {
+		a.sendError(payload.ConversationID, "A task is running in this folder")
+		return
+	}
+
+	conflicts, err := git.NewRepository(folder.Path).GetConflicts()
+	if err != nil {
+		a.sendError(payload.ConversationID, fmt.Sprintf("Failed to read conflicts: %v", err))
+		return
+	}
+	if conflicts == nil {
+		a.sendError(payload.ConversationID, "There are no conflicts to resolve")
+		return
+	}
+
+	files := make([]string, 0, len(conflicts.Files))
+	for _, file := range conflicts.Files {
+		files = append(files, file.Path)
+	}
+	log.Printf("⚔️  Resolving %d conflicted file(s) in %s with Claude", len(files), folder.Name)
+
+	onEvent := func(ev event.Event) {
+		if ev.Type == event.TypeDiff {
+			if state := a.conversationState(payload.ConversationID); state != nil {
+				a.trackDiffEvent(state, ev)
+			}
+		}
+		a.sendClaudeEvent(payload.ConversationID, ev)
+	}
+
+	executor, err := a.newInteractiveExecutor(payload.ConversationID, folder.Path, onEvent)
+	if err != nil {
+		log.Printf("❌ Failed to create executor: %v", err)
+		a.sendError(payload.ConversationID, err.Error())
+		return
+	}
+	executor.SetReviewFiles(files)
+	executor.SetSessionLinkedHandler(func(sid string) {
+		a.sendSessionLinked(payload.ConversationID, sid, payload.FolderID)
+	})
+
+	prompt := buildConflictPrompt(conflicts, payload.Instructions)
+	a.setConversation(payload.ConversationID, executor, &ConversationState{
+		executor:     executor,
+		pendingDiffs: make(map[string]bool),
+		folderPath:   folder.Path,
+		folderID:     payload.FolderID,
+		prompt:       prompt,
+		conflicts:    files,
+	})
+
+	go func() {
+		if err := executor.ExecuteTask(prompt); err != nil {
+			log.Printf("❌ Conflict resolution failed: %v", err)
+			a.sendTaskError(payload.ConversationID, err)
+			a.removeConversation(payload.ConversationID)
+		}
+	}()
+}
+
+// buildConflictPrompt asks Claude to resolve the conflicted files and
+// nothing else
+func buildConflictPrompt(conflicts *git.ConflictState, instructions string) string {
+	operation := string(conflicts.Operation)
+	if operation == "" {
+		operation = "merge"
+	}
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "A git %s stopped on conflicts. Resolve them in these files only:\n", operation)
+	for _, file := range conflicts.Files {
+		switch file.Kind {
+		case git.ConflictDeletedByUs:
+			fmt.Fprintf(&prompt, "- %s (deleted on our side, changed on theirs: keep it or delete it)\n", file.Path)
+		case git.ConflictDeletedByThem:
+			fmt.Fprintf(&prompt, "- %s (changed on our side, deleted on theirs: keep it or delete it)\n", file.Path)
+		default:
+			fmt.Fprintf(&prompt, "- %s (%d conflict(s))\n", file.Path, len(file.Hunks))
+		}
+	}
+	prompt.WriteString("\nEach conflict is marked with <<<<<<<, ======= and >>>>>>> lines. " +
+		"Combine both sides so the intent of each change is kept, remove every marker, " +
+		"and make sure the result still builds. Don't change other files and don't run git " +
+		"commands - the " + operation + " is continued after the user reviews your resolution.")
+	if instructions = strings.TrimSpace(instructions); instructions != "" {
+		prompt.WriteString("\n\nThe user says: " + instructions)
+	}
+	return prompt.String()
+}
+
+// finishConflictResolution completes the merge or rebase after the user
+// approved Claude's resolution, or aborts it after a rejection.
+func (a *Agent) finishConflictResolution(conversationID string, state *ConversationState, approved bool) {
+	action := conflictAbort
+	if approved {
+		action = conflictContinue
+	}
+	a.applyConflictAction(conversationID, state.folderID, state.folderPath, action, state.conflicts)
+}
+
+// handleConflictAction continues (after resolving outside Finn) or aborts the
+// merge or rebase in progress in a folder.
+func (a *Agent) handleConflictAction(msg *ws.Message) {
+	var payload struct {
+		FolderID string `json:"folder_id"`
+		Action   string `json:"action"` // continue or abort
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("Failed to parse conflict_action payload: %v", err)
+		return
+	}
+
+	folder := a.cfg.GetFolderByID(payload.FolderID)
+	if folder == nil {
+		log.Printf("❌ Folder not found: %s", payload.FolderID)
+		a.sendConflictResult("", payload.FolderID, payload.Action, "", fmt.Errorf("Folder not found"), false)
+		return
+	}
+	if payload.Action != conflictContinue && payload.Action != conflictAbort {
+		a.sendConflictResult("", payload.FolderID, payload.Action, "", fmt.Errorf("unknown action %q", payload.Action), false)
+		return
+	}
+
+	var files []string
+	if payload.Action == conflictContinue {
+		// Whatever is still unmerged must have been resolved by hand
+		var err error
+		if files, err = git.NewRepository(folder.Path).ConflictedFiles(); err != nil {
+			a.sendConflictResult("", payload.FolderID, payload.Action, "", err, false)
+			return
+		}
+	}
+	a.applyConflictAction("", payload.FolderID, folder.Path, payload.Action, files)
+}
+
+// applyConflictAction stages the resolved files and continues the operation,
+// or aborts it. A rebase that stops on the next commit sends its conflicts.
+func (a *Agent) applyConflictAction(conversationID, folderID, folderPath, action string, resolved []string) {
+	repo := git.NewRepository(folderPath)
+	operation := repo.OperationInProgress()
+
+	if action == conflictAbort {
+		if err := repo.AbortOperation(); err != nil {
+			log.Printf("❌ Failed to abort %s: %v", operation, err)
+			a.sendConflictResult(conversationID, folderID, action, operation, err, false)
+			return
+		}
+		log.Printf("↩️  Aborted %s in %s", operation, folderPath)
+		a.sendConflictResult(conversationID, folderID, action, operation, nil, true)
+		a.sendFolderListUpdate()
+		return
+	}
+
+	if err := repo.MarkResolved(resolved); err != nil {
+		log.Printf("❌ Conflicts not resolved: %v", err)
+		a.sendConflictResult(conversationID, folderID, action, operation, err, false)
+		return
+	}
+	// A revert of a Finn commit completing means it is undone
+	reverting := ""
+	if operation == git.OperationRevert {
+		reverting = repo.RevertingCommit()
+	}
+	next, err := repo.ContinueOperation()
+	if err != nil {
+		log.Printf("❌ Failed to continue %s: %v", operation, err)
+		a.sendConflictResult(conversationID, folderID, action, operation, err, false)
+		return
+	}
+
+	if next != nil {
+		log.Printf("⚔️  %s stopped again on %d conflicted file(s)", operation, len(next.Files))
+		a.sendConflictResult(conversationID, folderID, action, operation, nil, false)
+		a.sendConflicts(folderID, next, "")
+	} else {
+		log.Printf("✅ Completed %s in %s", operation, folderPath)
+		if reverting != "" {
+			a.forgetFinnCommit(folderID, reverting)
+		}
+		a.sendConflictResult(conversationID, folderID, action, operation, nil, true)
+	}
+	a.sendFolderListUpdate()
+}
+
+// sendConflictResult reports the outcome of continuing or aborting a
+// conflicted operation. completed is false while more conflicts remain.
+func (a *Agent) sendConflictResult(conversationID, folderID, action string, operation git.Operation, err error, completed bool) {
+	data := map[string]interface{}{
+		"folder_id": folderID,
+		"action":    action,
+		"operation": operation,
+		"success":   err == nil,
+		"completed": completed,
+	}
+	if conversationID != "" {
+		data["conversation_id"] = conversationID
+	}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	payload, _ := json.Marshal(data)
+
+	msg := &ws.Message{
+		UserID:     a.cfg.UserID,
+		DeviceType: "desktop",
+		Type:       ws.MessageTypeConflictResult,
+		Payload:    payload,
+	}
+
+	if err := a.wsClient.SendMessage(msg); err != nil {
+		log.Printf("Failed to send conflict result: %v", err)
+	}
+}
@@ -2,10 +2,14 @@ package agent
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"path/filepath"
+	"time"
 
 	"github.com/getfinn/finn/internal/claude"
+	"github.com/getfinn/finn/internal/event"
 	"github.com/getfinn/finn/internal/git"
 	ws "github.com/getfinn/finn/internal/websocket"
 )
@@ -14,10 +18,11 @@ import (
 // This starts a new Claude Code task execution.
 func (a *Agent) handlePrompt(msg *ws.Message) {
 	var payload struct {
-		ConversationID string `json:"conversation_id"`
-		FolderID       string `json:"folder_id"`
-		Text           string `json:"text"`
-		SessionID      string `json:"session_id,omitempty"` // If provided, resume this session
+		ConversationID string              `json:"conversation_id"`
+		FolderID       string              `json:"folder_id"`
+		Text           string              `json:"text"`
+		SessionID      string              `json:"session_id,omitempty"`  // If provided, resume this session
+		Attachments    []claude.Attachment `json:"attachments,omitempty"` // Images, PDFs and text files
 	}
 
 	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
@@ -27,58 +32,103 @@ func (a *Agent) handlePrompt(msg *ws.Message) {
 
 	log.Printf("📝 Received prompt: %s (folder: %s, session: %s)", payload.Text, payload.FolderID, payload.SessionID)
 
+	a.startPrompt(payload.ConversationID, payload.FolderID, payload.Text, payload.SessionID, false, payload.Attachments)
+}
+
+// startPrompt starts a new task in a folder. A command (run_command) is sent
+// to Claude as-is and always runs in the interactive executor.
+func (a *Agent) startPrompt(conversationID, folderID, text, sessionID string, command bool, attachments []claude.Attachment) {
+	// A new prompt replaces any wait for quota in this conversation
+	a.unparkTask(conversationID)
+
 	// Find the approved folder
 	var folderPath string
 	for _, folder := range a.cfg.ApprovedFolders {
-		if folder.ID == payload.FolderID {
+		if folder.ID == folderID {
 			folderPath = folder.Path
 			break
 		}
 	}
 
 	if folderPath == "" {
-		log.Printf("❌ Folder not found or not approved: %s", payload.FolderID)
-		a.sendError(payload.ConversationID, "Folder not found or not approved")
+		log.Printf("❌ Folder not found or not approved: %s", folderID)
+		a.sendError(conversationID, "Folder not found or not approved")
 		return
 	}
 
-	// Check if Claude Code is installed
-	if !claude.IsInstalled() {
-		log.Println("❌ Claude Code CLI not installed")
-		a.sendError(payload.ConversationID, "Claude Code CLI not installed. Please run: npm install -g @anthropic-ai/claude-code")
+	// Check Claude Code is usable (not needed when replaying a transcript).
+	// Only the PATH lookup runs per prompt; the full probe is cached.
+	if a.cfg.ReplayTranscript == "" {
+		if cliErr := claude.CheckInstalled(); cliErr != nil {
+			log.Println("❌ Claude Code CLI not installed")
+			a.sendTaskError(conversationID, cliErr)
+			return
+		}
+		if health, ok := claude.CachedHealth(); ok && health.Error != nil {
+			// Credential detection can't see every setup - let the run decide
+			log.Printf("⚠️  Claude Code health check: %s (auth: %s)", health.Error.Message, health.Auth)
+		}
+	}
+
+	// Only the interactive executor can send images inline; one-shot runs get files
+	interactive := a.cfg.ExecutionMode.InteractiveMode || command
+	prepared, err := claude.PrepareAttachments(attachments, interactive)
+	if err != nil {
+		log.Printf("❌ Invalid attachments: %v", err)
+		a.sendError(conversationID, err.Error())
 		return
 	}
 
+	// Attach the context of @file, @commit:, @session: and @dirty references
+	if !command {
+		var refs []reference
+		text, refs = a.expandReferences(folderPath, text)
+		a.sendPromptReferences(conversationID, folderID, refs)
+	}
+
 	// Create event handler for both executor types
-	onEvent := func(event claude.Event) {
+	onEvent := func(ev event.Event) {
 		// Track diff events to manage approval flow
-		if event.Type == claude.EventTypeDiff {
-			state := a.conversationStates[payload.ConversationID]
-			if state != nil {
-				a.trackDiffEvent(state, event)
+		if ev.Type == event.TypeDiff {
+			if state := a.conversationState(conversationID); state != nil {
+				a.trackDiffEvent(state, ev)
 			}
 		}
 
 		// Convert Claude events to WebSocket messages and send to mobile
-		a.sendClaudeEvent(payload.ConversationID, event)
+		a.sendClaudeEvent(conversationID, ev)
 	}
 
 	// Branch between one-shot and interactive modes based on interactiveMode setting
-	if !a.cfg.ExecutionMode.InteractiveMode {
-		a.startOneShotExecution(payload.ConversationID, folderPath, payload.Text, onEvent)
+	if !interactive {
+		a.startOneShotExecution(conversationID, folderID, folderPath, text+prepared.PromptNote(), onEvent)
 	} else {
-		a.startInteractiveExecution(payload.ConversationID, payload.FolderID, folderPath, payload.Text, payload.SessionID, onEvent)
+		a.startInteractiveExecution(conversationID, folderID, folderPath, text, sessionID, command, prepared, onEvent)
 	}
 }
 
 // startOneShotExecution starts a one-shot execution that auto-approves everything.
-func (a *Agent) startOneShotExecution(conversationID, folderPath, prompt string, onEvent func(claude.Event)) {
+func (a *Agent) startOneShotExecution(conversationID, folderID, folderPath, prompt string, onEvent event.Handler) {
 	log.Println("🚀 Using one-shot mode (auto-approve)")
+
+	// Nothing can stop a one-shot run for review, so don't start one the
+	// budget has no room for
+	if reached, budgetUSD, costUSD := a.hardBudgetReached(conversationID, folderID); reached {
+		log.Printf("💸 %s is at its hard budget ($%.2f of $%.2f) - not starting task", conversationID, costUSD, budgetUSD)
+		a.sendBudgetAlert(conversationID, folderID, budgetHard, budgetUSD, costUSD)
+		a.sendError(conversationID, fmt.Sprintf("This conversation has reached its hard budget ($%.2f of $%.2f)", costUSD, budgetUSD))
+		return
+	}
+	a.setUsageFolder(conversationID, folderID)
 	requiresApproval := false
 	executor := claude.NewTaskExecutor(folderPath, requiresApproval, onEvent)
+	executor.SetToolOutputStore(a.toolOutputs)
+	executor.SetLimits(a.taskLimits(folderPath))
+	executor.SetProcessHooks(a.processHooks(conversationID))
+	executor.SetSideBySideDiffs(a.cfg.ExecutionMode.SideBySideDiffs)
 
 	// Store executor
-	a.executors[conversationID] = executor
+	a.setExecutor(conversationID, executor)
 
 	// Execute and clean up after completion
 	go func() {
@@ -87,31 +137,36 @@ func (a *Agent) startOneShotExecution(conversationID, folderPath, prompt string,
 			a.sendError(conversationID, err.Error())
 		}
 		// Clean up one-shot executor after completion
-		delete(a.executors, conversationID)
+		a.removeExecutor(conversationID)
 	}()
 }
 
 // startInteractiveExecution starts an interactive execution that asks for decisions.
-func (a *Agent) startInteractiveExecution(conversationID, folderID, folderPath, prompt, sessionID string, onEvent func(claude.Event)) {
+// A command is sent as the first message instead of being wrapped in the security rules.
+func (a *Agent) startInteractiveExecution(conversationID, folderID, folderPath, prompt, sessionID string, command bool, attachments *claude.Attachments, onEvent event.Handler) {
 	log.Println("🤝 Using interactive mode (user decisions required)")
-	interactiveExec := claude.NewInteractiveTaskExecutor(folderPath, onEvent)
+	interactiveExec, err := a.newInteractiveExecutor(conversationID, folderPath, onEvent)
+	if err != nil {
+		log.Printf("❌ Failed to create executor: %v", err)
+		a.sendError(conversationID, err.Error())
+		return
+	}
+	interactiveExec.SetAttachments(attachments)
 
 	// Set up session linking callback
 	interactiveExec.SetSessionLinkedHandler(func(sid string) {
 		a.sendSessionLinked(conversationID, sid, folderID)
 	})
 
-	// Store executor
-	a.executors[conversationID] = interactiveExec
-
-	// Create conversation state for tracking approvals
-	a.conversationStates[conversationID] = &ConversationState{
+	// Store executor and conversation state for tracking approvals
+	a.setConversation(conversationID, interactiveExec, &ConversationState{
 		executor:     interactiveExec,
 		pendingDiffs: make(map[string]bool),
 		totalDiffs:   0,
 		folderPath:   folderPath,
 		folderID:     folderID,
-	}
+		prompt:       prompt,
+	})
 	log.Printf("📊 Created conversation state for: %s (folder: %s)", conversationID, folderID)
 
 	if sessionID != "" {
@@ -120,28 +175,78 @@ func (a *Agent) startInteractiveExecution(conversationID, folderID, folderPath,
 		go func() {
 			if err := interactiveExec.ResumeSession(sessionID, prompt); err != nil {
 				log.Printf("❌ Session resume failed: %v", err)
-				a.sendError(conversationID, err.Error())
-				delete(a.executors, conversationID)
-				delete(a.conversationStates, conversationID)
+				a.sendTaskError(conversationID, err)
+				a.removeConversation(conversationID)
 			}
 		}()
 	} else {
 		// Start new session
 		go func() {
-			if err := interactiveExec.ExecuteTask(prompt); err != nil {
+			start := interactiveExec.ExecuteTask
+			if command {
+				start = interactiveExec.ExecuteCommand
+			}
+			if err := start(prompt); err != nil {
 				log.Printf("❌ Task execution failed: %v", err)
-				a.sendError(conversationID, err.Error())
-				delete(a.executors, conversationID)
-				delete(a.conversationStates, conversationID)
+				a.sendTaskError(conversationID, err)
+				a.removeConversation(conversationID)
 			}
 		}()
 	}
 }
 
+// newInteractiveExecutor creates an interactive executor for a conversation.
+// When FINN_REPLAY_TRANSCRIPT is set the executor plays back that transcript
+// instead of running Claude; when FINN_RECORD_DIR is set the conversation is
+// recorded to <dir>/<conversation_id>.jsonl (or a numbered variant, see
+// claude.NewRecorder) for later replay.
+func (a *Agent) newInteractiveExecutor(conversationID, folderPath string, onEvent event.Handler) (*claude.InteractiveTaskExecutor, error) {
+	if a.cfg.ReplayTranscript != "" {
+		log.Printf("▶️  Replay mode - using transcript %s", a.cfg.ReplayTranscript)
+		replayer, err := claude.NewReplayer(a.cfg.ReplayTranscript, a.cfg.ReplaySpeed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load replay transcript: %w", err)
+		}
+		executor := claude.NewReplayTaskExecutor(folderPath, replayer, onEvent)
+		executor.SetToolOutputStore(a.toolOutputs)
+		executor.SetSideBySideDiffs(a.cfg.ExecutionMode.SideBySideDiffs)
+		return executor, nil
+	}
+
+	executor := claude.NewInteractiveTaskExecutor(folderPath, onEvent)
+	executor.SetToolOutputStore(a.toolOutputs)
+	executor.SetLimits(a.taskLimits(folderPath))
+	executor.SetProcessHooks(a.processHooks(conversationID))
+	executor.SetPartialMessages(!a.cfg.DisableTokenStreaming)
+	executor.SetSideBySideDiffs(a.cfg.ExecutionMode.SideBySideDiffs)
+
+	if a.cfg.RecordDir != "" {
+		recorder, err := claude.NewRecorder(filepath.Join(a.cfg.RecordDir, conversationID+".jsonl"))
+		if err != nil {
+			// Recording is a debugging aid - never block the task on it
+			log.Printf("⚠️  Failed to start transcript recording: %v", err)
+		} else {
+			executor.SetRecorder(recorder)
+		}
+	}
+
+	return executor, nil
+}
+
+// taskLimits returns the watchdog limits configured for a folder
+func (a *Agent) taskLimits(folderPath string) claude.Limits {
+	limits := a.cfg.LimitsForFolder(folderPath)
+	return claude.Limits{
+		MaxDuration: time.Duration(limits.MaxDurationMinutes) * time.Minute,
+		IdleTimeout: time.Duration(limits.IdleTimeoutMinutes) * time.Minute,
+		MaxTurns:    limits.MaxTurns,
+	}
+}
+
 // trackDiffEvent tracks a diff event for approval management.
-func (a *Agent) trackDiffEvent(state *ConversationState, event claude.Event) {
-	var diffData map[string]interface{}
-	if err := json.Unmarshal(event.Content, &diffData); err != nil {
+func (a *Agent) trackDiffEvent(state *ConversationState, ev event.Event) {
+	var diffData event.Diff
+	if err := ev.Decode(&diffData); err != nil {
 		return
 	}
 
@@ -149,25 +254,24 @@ func (a *Agent) trackDiffEvent(state *ConversationState, event claude.Event) {
 		state.pendingDiffs = make(map[string]bool)
 	}
 
-	// Incremental diff (single file)
-	if filePath, ok := diffData["file_path"].(string); ok && filePath != "" {
+	// A revision re-sends the files of earlier turns; each is listed once
+	addFile := func(path string) {
+		if !containsFile(state.files, path) {
+			state.files = append(state.files, path)
+		}
+	}
+
+	for filePath := range diffData.Diffs {
 		if !state.pendingDiffs[filePath] {
 			state.pendingDiffs[filePath] = false
 			state.totalDiffs++
-			state.files = append(state.files, filePath)
+			addFile(filePath)
 			log.Printf("📊 Tracking diff for approval: %s (total: %d)", filePath, state.totalDiffs)
 		}
-	}
 
-	// Batch diff format (multiple files in "diffs" map)
-	if diffsMap, ok := diffData["diffs"].(map[string]interface{}); ok {
-		for filePath := range diffsMap {
-			if !state.pendingDiffs[filePath] {
-				state.pendingDiffs[filePath] = false
-				state.totalDiffs++
-				state.files = append(state.files, filePath)
-				log.Printf("📊 Tracking diff for approval: %s (total: %d)", filePath, state.totalDiffs)
-			}
+		// A rejected rename must bring its source back too
+		if change := diffData.Files[filePath]; change.Kind == git.ChangeRenamed && change.OldPath != "" {
+			addFile(change.OldPath)
 		}
 	}
 }
@@ -190,8 +294,8 @@ func (a *Agent) handleChoice(msg *ws.Message) {
 	log.Printf("✅ User selected: %s for conversation: %s (remember=%v, tool=%s)",
 		payload.SelectedID, payload.ConversationID, payload.Remember, payload.ToolName)
 
-	executor, exists := a.executors[payload.ConversationID]
-	if !exists {
+	executor := a.executor(payload.ConversationID)
+	if executor == nil {
 		log.Printf("❌ No active executor for conversation: %s", payload.ConversationID)
 		a.sendError(payload.ConversationID, "No active task for this conversation")
 		return
@@ -230,6 +334,7 @@ func (a *Agent) handleApproval(msg *ws.Message) {
 		ConversationID string `json:"conversation_id"`
 		Approved       bool   `json:"approved"`
 		CommitMessage  string `json:"commit_message,omitempty"`
+		approvalDestination
 	}
 
 	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
@@ -237,8 +342,8 @@ func (a *Agent) handleApproval(msg *ws.Message) {
 		return
 	}
 
-	state, hasState := a.conversationStates[payload.ConversationID]
-	if !hasState {
+	state := a.conversationState(payload.ConversationID)
+	if state == nil {
 		log.Printf("❌ No conversation state for: %s (daemon may have restarted)", payload.ConversationID)
 		a.sendError(payload.ConversationID, "Conversation has expired. Please restart the task.")
 		return
@@ -253,19 +358,21 @@ func (a *Agent) handleApproval(msg *ws.Message) {
 
 	repo := git.NewRepository(folderPath)
 
-	if payload.Approved {
-		log.Printf("✅ Changes approved - committing %d files in folder: %s", len(state.files), folderPath)
+	if state.conflicts != nil {
+		// A conflict resolution: approving completes the merge or rebase, rejecting aborts it
+		a.finishConflictResolution(payload.ConversationID, state, payload.Approved)
+	} else if payload.Approved {
+		log.Printf("✅ Changes approved - applying %d files in folder: %s", len(state.files), folderPath)
 		commitMsg := payload.CommitMessage
 		if commitMsg == "" {
 			commitMsg = "Apply changes via Finn"
 		}
+		if payload.writesCommitMessage() {
+			commitMsg = git.AppendTrailers(commitMsg, a.commitTrailers(payload.ConversationID, state))
+		}
 		log.Printf("📝 Using commit message: %s", commitMsg)
-		if err := repo.CommitAndPush(commitMsg); err != nil {
-			log.Printf("❌ Failed to commit changes: %v", err)
-			a.sendError(payload.ConversationID, fmt.Sprintf("Failed to commit: %v", err))
-		} else {
-			log.Println("✅ Changes committed successfully")
-			a.sendCommitSuccess(payload.ConversationID, folderPath, state.folderID)
+		if !a.commitApproval(payload.ConversationID, state, payload.approvalDestination, commitMsg) {
+			return // Blocked by a hook - kept open for commit_blocked_action
 		}
 	} else {
 		log.Printf("❌ Changes rejected - discarding %d conversation files in folder: %s", len(state.files), folderPath)
@@ -288,8 +395,7 @@ func (a *Agent) handleApproval(msg *ws.Message) {
 	}
 
 	// Clean up
-	delete(a.executors, payload.ConversationID)
-	delete(a.conversationStates, payload.ConversationID)
+	a.removeConversation(payload.ConversationID)
 	log.Printf("🧹 Cleaned up conversation: %s", payload.ConversationID)
 }
 
@@ -307,8 +413,8 @@ func (a *Agent) handleDiffApproved(msg *ws.Message) {
 
 	log.Printf("✅ Diff approved for file: %s (conversation: %s)", payload.FilePath, payload.ConversationID)
 
-	state, exists := a.conversationStates[payload.ConversationID]
-	if !exists {
+	state := a.conversationState(payload.ConversationID)
+	if state == nil {
 		log.Printf("⚠️  No conversation state for: %s (may have already completed)", payload.ConversationID)
 		return
 	}
@@ -342,8 +448,10 @@ func (a *Agent) handleDiffApproved(msg *ws.Message) {
 // handleReprompt handles a reprompt request to revise changes.
 func (a *Agent) handleReprompt(msg *ws.Message) {
 	var payload struct {
-		ConversationID string `json:"conversation_id"`
-		RepromptText   string `json:"reprompt_text"`
+		ConversationID string              `json:"conversation_id"`
+		RepromptText   string              `json:"reprompt_text"`
+		StartOver      bool                `json:"start_over,omitempty"` // Begin a fresh Claude session instead of continuing
+		Attachments    []claude.Attachment `json:"attachments,omitempty"`
 		DiffContext    []struct {
 			FilePath string `json:"file_path"`
 			Diff     string `json:"diff"`
@@ -355,43 +463,113 @@ func (a *Agent) handleReprompt(msg *ws.Message) {
 		return
 	}
 
-	log.Printf("🔄 Reprompt received: %s (conversation: %s)", payload.RepromptText, payload.ConversationID)
+	log.Printf("🔄 Reprompt received: %s (conversation: %s, start_over=%v)",
+		payload.RepromptText, payload.ConversationID, payload.StartOver)
 
-	state, exists := a.conversationStates[payload.ConversationID]
-	if !exists {
+	state := a.conversationState(payload.ConversationID)
+	if state == nil {
 		log.Printf("❌ No conversation state for: %s", payload.ConversationID)
 		a.sendError(payload.ConversationID, "Conversation not found")
 		return
 	}
 
-	contextPrompt := buildRepromptWithContext(payload.RepromptText, payload.DiffContext)
+	attachments, err := claude.PrepareAttachments(payload.Attachments, true)
+	if err != nil {
+		log.Printf("❌ Invalid attachments: %v", err)
+		a.sendError(payload.ConversationID, err.Error())
+		return
+	}
+
+	// A new instruction replaces any wait for quota
+	a.unparkTask(payload.ConversationID)
+
+	repromptText, refs := a.expandReferences(state.folderPath, payload.RepromptText)
+	a.sendPromptReferences(payload.ConversationID, state.folderID, refs)
 
 	// Clear the approval state
 	state.pendingDiffs = make(map[string]bool)
 	state.totalDiffs = 0
 
-	onEvent := func(event claude.Event) {
-		if event.Type == claude.EventTypeDiff {
-			a.trackDiffEvent(state, event)
+	current, isInteractive := state.executor.(*claude.InteractiveTaskExecutor)
+
+	if !payload.StartOver {
+		if !isInteractive {
+			a.sendError(payload.ConversationID, "This conversation can't be continued - use \"start over\" to begin a new session")
+			return
 		}
-		a.sendClaudeEvent(payload.ConversationID, event)
+
+		// Continue the same Claude session so it keeps its context, tool
+		// history and session link
+		log.Printf("🔄 Continuing session %s for reprompt", current.SessionID())
+		current.SetAttachments(attachments)
+		go func() {
+			if err := current.Continue(buildRevisionPrompt(repromptText)); err != nil {
+				log.Printf("❌ Reprompt failed: %v", err)
+				var cliErr *claude.CLIError
+				if errors.As(err, &cliErr) {
+					a.sendTaskError(payload.ConversationID, err)
+					return
+				}
+				a.sendError(payload.ConversationID, fmt.Sprintf(
+					"Couldn't continue the Claude session (%v) - use \"start over\" to begin a new session", err))
+			}
+		}()
+		return
+	}
+
+	// Start over: fresh Claude session with the previous diffs pasted in as context
+	if isInteractive {
+		_ = current.Stop() // Best effort - the old session is being abandoned
 	}
+	contextPrompt := buildRepromptWithContext(repromptText, payload.DiffContext)
+	state.prompt = contextPrompt
 
-	log.Println("🔄 Creating new executor for reprompt iteration")
-	executor := claude.NewInteractiveTaskExecutor(state.folderPath, onEvent)
+	onEvent := func(ev event.Event) {
+		if ev.Type == event.TypeDiff {
+			a.trackDiffEvent(state, ev)
+		}
+		a.sendClaudeEvent(payload.ConversationID, ev)
+	}
 
-	a.executors[payload.ConversationID] = executor
+	log.Println("🔄 Starting over with a new Claude session")
+	executor, err := a.newInteractiveExecutor(payload.ConversationID, state.folderPath, onEvent)
+	if err != nil {
+		log.Printf("❌ Failed to create executor: %v", err)
+		a.sendError(payload.ConversationID, err.Error())
+		return
+	}
+
+	folderID := state.folderID
+	executor.SetSessionLinkedHandler(func(sid string) {
+		a.sendSessionLinked(payload.ConversationID, sid, folderID)
+	})
+
+	a.setExecutor(payload.ConversationID, executor)
 	state.executor = executor
+	executor.SetAttachments(attachments)
+	executor.SetReviewFiles(state.conflicts)
 
 	go func() {
 		if err := executor.ExecuteTask(contextPrompt); err != nil {
 			log.Printf("❌ Reprompt execution failed: %v", err)
-			a.sendError(payload.ConversationID, err.Error())
+			a.sendTaskError(payload.ConversationID, err)
 		}
 	}()
 }
 
+// buildRevisionPrompt builds the follow-up prompt for a reprompt that continues
+// the existing session. Claude already knows what it changed, so only the
+// user's feedback is sent.
+func buildRevisionPrompt(repromptText string) string {
+	return fmt.Sprintf(`The user reviewed your changes and wants adjustments.
+
+User's feedback: "%s"
+
+Please revise the changes based on the user's feedback.`, repromptText)
+}
+
 // buildRepromptWithContext builds a context-aware prompt with diff context.
+// Used when starting over in a fresh session that has no memory of the changes.
 func buildRepromptWithContext(repromptText string, diffs []struct {
 	FilePath string `json:"file_path"`
 	Diff     string `json:"diff"`
@@ -418,6 +596,7 @@ func (a *Agent) handleSettingsUpdate(msg *ws.Message) {
 	var payload struct {
 		InteractiveMode  bool   `json:"interactiveMode"`
 		DiffApprovalMode string `json:"diffApprovalMode"`
+		SideBySideDiffs  *bool  `json:"sideBySideDiffs,omitempty"` // Left as is when omitted
 	}
 
 	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
@@ -430,6 +609,9 @@ func (a *Agent) handleSettingsUpdate(msg *ws.Message) {
 
 	a.cfg.ExecutionMode.InteractiveMode = payload.InteractiveMode
 	a.cfg.ExecutionMode.DiffApprovalMode = payload.DiffApprovalMode
+	if payload.SideBySideDiffs != nil {
+		a.cfg.ExecutionMode.SideBySideDiffs = *payload.SideBySideDiffs
+	}
 
 	if err := a.cfg.Save(); err != nil {
 		log.Printf("❌ Failed to save settings: %v", err)
@@ -493,35 +675,56 @@ func (a *Agent) sendMockCompletion(conversationID string) {
 	}
 }
 
-// sendClaudeEvent converts a Claude event to a WebSocket message and sends it.
-func (a *Agent) sendClaudeEvent(conversationID string, event claude.Event) {
+// sendClaudeEvent converts an executor event to a WebSocket message and sends it.
+func (a *Agent) sendClaudeEvent(conversationID string, ev event.Event) {
 	var msgType ws.MessageType
 
-	switch event.Type {
-	case claude.EventTypeThinking:
+	switch ev.Type {
+	case event.TypeThinking:
 		msgType = ws.MessageTypeThinking
-	case claude.EventTypeToolUse:
+	case event.TypeThinkingDelta:
+		msgType = ws.MessageTypeThinkingDelta
+	case event.TypeToolUse:
 		msgType = ws.MessageTypeToolUse
-	case claude.EventTypeDecision:
+	case event.TypeToolResult:
+		msgType = ws.MessageTypeToolResult
+	case event.TypeDecision:
 		msgType = ws.MessageTypeDecision
-	case claude.EventTypeProgress:
+	case event.TypeProgress:
 		msgType = ws.MessageTypeProgress
-	case claude.EventTypeDiff:
+	case event.TypeDiff:
 		msgType = ws.MessageTypeDiff
-	case claude.EventTypeComplete:
+	case event.TypeComplete:
 		msgType = ws.MessageTypeComplete
-	case claude.EventTypeUsage:
+	case event.TypeUsage:
 		msgType = ws.MessageTypeUsage
-	case claude.EventTypeError:
+	case event.TypeError:
 		msgType = ws.MessageTypeError
 	default:
-		log.Printf("Unknown event type: %s", event.Type)
+		log.Printf("Unknown event type: %s", ev.Type)
 		return
 	}
 
+	// A usage or rate limit parks the task until it can resume (after the error is sent)
+	if ev.Type == event.TypeError || ev.Type == event.TypeComplete {
+		defer a.parkIfLimited(conversationID, ev)
+	}
+
+	// A fix for a commit blocked by a hook ends with a commit retry
+	if ev.Type == event.TypeComplete || ev.Type == event.TypeError {
+		defer a.retryBlockedCommit(conversationID, ev)
+	}
+
+	// Ledger and budgets (after the event is sent, so a budget stop follows the usage)
+	switch ev.Type {
+	case event.TypeUsage, event.TypeComplete, event.TypeError:
+		defer a.trackUsage(conversationID, ev)
+	}
+
 	payload := map[string]interface{}{
 		"conversation_id": conversationID,
-		"data":            json.RawMessage(event.Content),
+		"version":         ev.Version,
+		"data":            ev.Content,
 	}
 	payloadBytes, _ := json.Marshal(payload)
 
@@ -559,6 +762,89 @@ func (a *Agent) sendError(conversationID string, message string) {
 	}
 }
 
+// handleGetToolOutput sends the full output of a tool call whose tool_result
+// event was truncated. The output handle is the tool_use ID.
+func (a *Agent) handleGetToolOutput(msg *ws.Message) {
+	var payload struct {
+		ConversationID string `json:"conversation_id"`
+		OutputHandle   string `json:"output_handle"`
+	}
+
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("Failed to unmarshal get_tool_output payload: %v", err)
+		return
+	}
+
+	output, ok := a.toolOutputs.Get(payload.OutputHandle)
+	if !ok {
+		log.Printf("❌ Tool output not found: %s", payload.OutputHandle)
+		a.sendError(payload.ConversationID, "Tool output is no longer available")
+		return
+	}
+
+	responsePayload, _ := json.Marshal(map[string]interface{}{
+		"conversation_id": payload.ConversationID,
+		"output_handle":   payload.OutputHandle,
+		"tool":            output.Tool,
+		"output":          output.Output,
+		"is_error":        output.IsError,
+		"truncated":       output.Truncated,
+	})
+
+	responseMsg := &ws.Message{
+		UserID:     a.cfg.UserID,
+		DeviceType: "desktop",
+		Type:       ws.MessageTypeToolOutput,
+		Payload:    responsePayload,
+	}
+
+	if err := a.wsClient.SendMessage(responseMsg); err != nil {
+		log.Printf("❌ Failed to send tool output: %v", err)
+	} else {
+		log.Printf("📤 Sent full tool output for %s (%d bytes)", payload.OutputHandle, len(output.Output))
+	}
+}
+
+// handleGetCLIHealth reports the state of the local Claude Code CLI.
+func (a *Agent) handleGetCLIHealth(msg *ws.Message) {
+	health := claude.CheckHealth()
+	log.Printf("🩺 Claude Code health: installed=%v version=%s auth=%s", health.Installed, health.Version, health.Auth)
+
+	responseData := map[string]interface{}{
+		"installed": health.Installed,
+		"version":   health.Version,
+		"auth":      health.Auth,
+	}
+	if health.Error != nil {
+		responseData["code"] = health.Error.Code
+		responseData["message"] = health.Error.Message
+		responseData["action"] = health.Error.Action
+	}
+	responsePayload, _ := json.Marshal(responseData)
+
+	responseMsg := &ws.Message{
+		UserID:     a.cfg.UserID,
+		DeviceType: "desktop",
+		Type:       ws.MessageTypeCLIHealth,
+		Payload:    responsePayload,
+	}
+
+	if err := a.wsClient.SendMessage(responseMsg); err != nil {
+		log.Printf("❌ Failed to send CLI health: %v", err)
+	}
+}
+
+// sendTaskError reports a task that failed to start or continue. Classified
+// Claude CLI failures go out as typed error events (code, message, suggested action).
+func (a *Agent) sendTaskError(conversationID string, err error) {
+	var cliErr *claude.CLIError
+	if errors.As(err, &cliErr) {
+		a.sendClaudeEvent(conversationID, cliErr.Event())
+		return
+	}
+	a.sendError(conversationID, err.Error())
+}
+
 // sendSessionLinked sends a session_linked event to relay server.
 // This links the mobile-initiated conversation_id with Claude's session_id
 // so they can be merged in the database.
@@ -405,6 +405,11 @@ func (a *Agent) sendFolderListUpdate() {
 			if branch, err := repo.GetCurrentBranch(); err == nil && branch != "" {
 				folderData["current_branch"] = branch
 			}
+			if status, err := repo.GetSyncStatus(); err == nil && status.Upstream != "" {
+				folderData["upstream"] = status.Upstream
+				folderData["ahead"] = status.Ahead
+				folderData["behind"] = status.Behind
+			}
 
 			commits := a.getCommitsForFolder(folder.Path)
 			if len(commits) > 0 {
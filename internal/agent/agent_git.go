@@ -2,10 +2,14 @@ package agent
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"path/filepath"
 	"time"
 
+	"github.com/getfinn/finn/internal/claude"
+	"github.com/getfinn/finn/internal/config"
 	"github.com/getfinn/finn/internal/git"
 	ws "github.com/getfinn/finn/internal/websocket"
 )
@@ -341,15 +345,17 @@ func (a *Agent) handleGetCommits(msg *ws.Message) {
 	commitData := make([]map[string]interface{}, 0, len(commits))
 	for _, commit := range commits {
 		commitData = append(commitData, map[string]interface{}{
-			"commit_hash":   commit.FullHash,
-			"short_hash":    commit.Hash,
-			"message":       commit.Message,
-			"author":        commit.Author,
-			"author_email":  commit.Email,
-			"committed_at":  time.Unix(commit.Timestamp, 0).Format(time.RFC3339),
-			"additions":     commit.Stats.Additions,
-			"deletions":     commit.Stats.Deletions,
-			"files_changed": commit.Stats.FilesChanged,
+			"commit_hash":     commit.FullHash,
+			"short_hash":      commit.Hash,
+			"message":         commit.Message,
+			"author":          commit.Author,
+			"author_email":    commit.Email,
+			"committed_at":    time.Unix(commit.Timestamp, 0).Format(time.RFC3339),
+			"additions":       commit.Stats.Additions,
+			"deletions":       commit.Stats.Deletions,
+			"files_changed":   commit.Stats.FilesChanged,
+			"conversation_id": commit.ConversationID,
+			"session_id":      commit.SessionID,
 		})
 	}
 
@@ -426,18 +432,29 @@ func (a *Agent) handleGetCommitDetail(msg *ws.Message) {
 		return
 	}
 
+	if a.cfg.ExecutionMode.SideBySideDiffs {
+		for _, file := range detail.Files {
+			if file.Parsed != nil {
+				file.Parsed.AlignSideBySide()
+			}
+		}
+	}
+
 	responsePayload, _ := json.Marshal(map[string]interface{}{
-		"folder_id":     payload.FolderID,
-		"commit_hash":   detail.FullHash,
-		"short_hash":    detail.Hash,
-		"message":       detail.Message,
-		"author":        detail.Author,
-		"author_email":  detail.Email,
-		"committed_at":  time.Unix(detail.Timestamp, 0).Format(time.RFC3339),
-		"additions":     detail.Stats.Additions,
-		"deletions":     detail.Stats.Deletions,
-		"files_changed": detail.Stats.FilesChanged,
-		"files":         detail.Files,
+		"folder_id":       payload.FolderID,
+		"commit_hash":     detail.FullHash,
+		"short_hash":      detail.Hash,
+		"message":         detail.Message,
+		"author":          detail.Author,
+		"author_email":    detail.Email,
+		"committed_at":    time.Unix(detail.Timestamp, 0).Format(time.RFC3339),
+		"additions":       detail.Stats.Additions,
+		"deletions":       detail.Stats.Deletions,
+		"files_changed":   detail.Stats.FilesChanged,
+		"files":           detail.Files,
+		"trailers":        detail.Trailers,
+		"conversation_id": detail.ConversationID,
+		"session_id":      detail.SessionID,
 	})
 
 	responseMsg := &ws.Message{
@@ -472,31 +489,311 @@ func (a *Agent) sendCommitDetailError(folderID, commitHash, errMsg string) {
 	a.wsClient.SendMessage(msg)
 }
 
-// sendCommitSuccess sends a commit_success event to mobile.
-func (a *Agent) sendCommitSuccess(conversationID string, folderPath string, folderID string) {
-	repo := git.NewRepository(folderPath)
+// handleGetCommitConversation looks up the conversation and Claude session a
+// commit's trailers link it to and sends the session transcript.
+func (a *Agent) handleGetCommitConversation(msg *ws.Message) {
+	var payload struct {
+		FolderID   string `json:"folder_id"`
+		CommitHash string `json:"commit_hash"`
+	}
 
-	latestCommit, err := repo.GetCommits(1)
-	if err != nil || len(latestCommit) == 0 {
-		log.Printf("⚠️  Could not get latest commit for success message")
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("Failed to unmarshal get_commit_conversation payload: %v", err)
 		return
 	}
 
-	commit := latestCommit[0]
+	folder := a.cfg.GetFolderByID(payload.FolderID)
+	if folder == nil {
+		log.Printf("❌ Folder not found: %s", payload.FolderID)
+		a.sendCommitConversation(payload.FolderID, payload.CommitHash, map[string]interface{}{"error": "Folder not found"})
+		return
+	}
 
-	payload, _ := json.Marshal(map[string]interface{}{
+	repo := git.NewRepository(folder.Path)
+	detail, err := repo.GetCommitDetails(payload.CommitHash)
+	if err != nil {
+		log.Printf("❌ Failed to get commit: %v", err)
+		a.sendCommitConversation(payload.FolderID, payload.CommitHash, map[string]interface{}{"error": fmt.Sprintf("Failed to get commit: %v", err)})
+		return
+	}
+
+	conversationID, sessionID := detail.ConversationID, detail.SessionID
+	if conversationID == "" && sessionID == "" {
+		a.sendCommitConversation(payload.FolderID, detail.FullHash, map[string]interface{}{"error": "Commit isn't linked to a conversation"})
+		return
+	}
+
+	// A conversation still open here knows its session even if the trailer is missing
+	state := a.conversationState(conversationID)
+	active := state != nil
+	if sessionID == "" && active {
+		if interactive, ok := state.executor.(*claude.InteractiveTaskExecutor); ok {
+			sessionID = interactive.SessionID()
+		}
+	}
+
+	data := map[string]interface{}{
+		"conversation_id": conversationID,
+		"session_id":      sessionID,
+		"active":          active,
+		"messages":        []interface{}{},
+	}
+
+	if sessionID != "" && a.sessionWatcher != nil {
+		messages, err := a.sessionWatcher.GetSessionMessages(sessionID)
+		if err == nil && messages == nil {
+			// Not tracked yet: discover the folder's sessions and retry
+			a.sessionWatcher.ScanProjectSessions(folder.Path)
+			messages, err = a.sessionWatcher.GetSessionMessages(sessionID)
+		}
+		if err != nil {
+			data["error"] = fmt.Sprintf("Failed to read session: %v", err)
+		} else if messages == nil {
+			data["error"] = "Session transcript not found on this machine"
+		} else {
+			data["messages"] = displaySessionMessages(messages)
+			data["total_count"] = len(messages)
+		}
+	}
+
+	log.Printf("🔗 Commit %s links to conversation %s, session %s", detail.Hash, conversationID, sessionID)
+	a.sendCommitConversation(payload.FolderID, detail.FullHash, data)
+}
+
+// sendCommitConversation sends the conversation a commit links to
+func (a *Agent) sendCommitConversation(folderID, commitHash string, data map[string]interface{}) {
+	data["folder_id"] = folderID
+	data["commit_hash"] = commitHash
+	payload, _ := json.Marshal(data)
+
+	msg := &ws.Message{
+		UserID:     a.cfg.UserID,
+		DeviceType: "desktop",
+		Type:       ws.MessageTypeCommitConversation,
+		Payload:    payload,
+	}
+
+	if err := a.wsClient.SendMessage(msg); err != nil {
+		log.Printf("❌ Failed to send commit conversation: %v", err)
+	}
+}
+
+// Approval destinations: where approved changes go
+const (
+	destinationCommit = "commit" // Commit (and push) on the current branch
+	destinationBranch = "branch" // Commit on a new branch, optionally pushed
+	destinationStaged = "staged" // Stage, don't commit
+	destinationStash  = "stash"  // Save as a named stash
+	destinationPatch  = "patch"  // Export to a patch file in ~/.finn/patches
+)
+
+// destinationActions describe each destination in error messages
+var destinationActions = map[string]string{
+	destinationCommit: "commit",
+	destinationBranch: "commit to a new branch",
+	destinationStaged: "stage changes",
+	destinationStash:  "stash changes",
+	destinationPatch:  "export patch",
+}
+
+// approvalDestination is the part of an approval saying where changes go
+type approvalDestination struct {
+	Destination string `json:"destination,omitempty"`  // commit (default), branch, staged, stash or patch
+	Branch      string `json:"branch,omitempty"`       // New branch for "branch"
+	Push        bool   `json:"push,omitempty"`         // Push the new branch with upstream
+	Name        string `json:"name,omitempty"`         // Stash message or patch file name
+	FormatPatch bool   `json:"format_patch,omitempty"` // git format-patch instead of a plain diff
+
+	noVerify bool // Skip commit hooks (commit_blocked_action "no_verify" only)
+}
+
+// writesCommitMessage reports whether the commit message ends up in a commit
+// (rather than naming a stash or plain patch), so trailers belong in it
+func (d approvalDestination) writesCommitMessage() bool {
+	switch d.Destination {
+	case "", destinationCommit, destinationBranch:
+		return true
+	case destinationPatch:
+		return d.FormatPatch
+	}
+	return false
+}
+
+// commitTrailers returns the configured trailers linking a commit to the
+// conversation and Claude session that produced it
+func (a *Agent) commitTrailers(conversationID string, state *ConversationState) []git.Trailer {
+	settings := a.cfg.CommitTrailers
+	if settings.Disabled {
+		return nil
+	}
+
+	var trailers []git.Trailer
+	if !settings.DisableConversation {
+		trailers = append(trailers, git.Trailer{Key: git.TrailerConversation, Value: conversationID})
+	}
+	if !settings.DisableSession {
+		if interactive, ok := state.executor.(*claude.InteractiveTaskExecutor); ok {
+			trailers = append(trailers, git.Trailer{Key: git.TrailerSession, Value: interactive.SessionID()})
+		}
+	}
+	for _, coAuthor := range settings.CoAuthors {
+		trailers = append(trailers, git.Trailer{Key: git.TrailerCoAuthor, Value: coAuthor})
+	}
+	return trailers
+}
+
+// approvalOutcome is what happened to approved changes, for commit_success
+type approvalOutcome struct {
+	Destination  string
+	Committed    bool
+	Branch       string // Branch committed to
+	Pushed       bool
+	PushError    string // Committed, but the push failed
+	PushRejected bool   // The push failed because the remote has diverged
+	Stash        string // Stash ref
+	PatchPath    string
+}
+
+// patchesDir returns where exported patches are written
+func patchesDir() string {
+	return filepath.Join(config.Dir(), "patches")
+}
+
+// applyApproval moves approved changes to their destination. Staging,
+// stashing and exporting take only files, the conversation's own changes.
+func (a *Agent) applyApproval(folderID string, repo *git.Repository, dest approvalDestination, commitMsg string, files []string) (approvalOutcome, error) {
+	outcome := approvalOutcome{Destination: dest.Destination}
+	if outcome.Destination == "" {
+		outcome.Destination = destinationCommit
+	}
+
+	switch outcome.Destination {
+	case destinationCommit:
+		commit := repo.Commit
+		if dest.noVerify {
+			commit = repo.CommitNoVerify
+		}
+		if err := commit(commitMsg); err != nil {
+			return outcome, err
+		}
+		outcome.Committed = true
+		outcome.Branch, _ = repo.GetCurrentBranch()
+
+		// The commit stands whatever happens to the push
+		if err := repo.Push(); errors.Is(err, git.ErrNoRemote) {
+			log.Printf("ℹ️  No remote repository configured - changes committed locally only")
+		} else if err != nil {
+			log.Printf("⚠️  Committed but push failed: %v", err)
+			outcome.PushError = err.Error()
+			outcome.PushRejected = isPushRejected(err)
+		} else {
+			outcome.Pushed = true
+		}
+
+	case destinationBranch:
+		if err := repo.CommitToNewBranch(dest.Branch, commitMsg, dest.noVerify); err != nil {
+			return outcome, err
+		}
+		outcome.Committed = true
+		outcome.Branch = dest.Branch
+		if dest.Push {
+			if err := repo.PushUpstream(dest.Branch); err != nil {
+				// The commit stands; report the push separately
+				log.Printf("⚠️  Committed to %s but push failed: %v", dest.Branch, err)
+				outcome.PushError = err.Error()
+				outcome.PushRejected = isPushRejected(err)
+			} else {
+				outcome.Pushed = true
+			}
+		}
+
+	case destinationStaged:
+		if err := repo.StageFiles(files); err != nil {
+			return outcome, err
+		}
+
+	case destinationStash:
+		name := dest.Name
+		if name == "" {
+			name = commitMsg
+		}
+		stash, err := repo.StashFiles(files, name)
+		if err != nil {
+			return outcome, err
+		}
+		outcome.Stash = stash
+
+	case destinationPatch:
+		name := dest.Name
+		if name == "" {
+			name = commitMsg
+		}
+		path, err := repo.ExportPatch(patchesDir(), name, commitMsg, files, dest.FormatPatch)
+		if err != nil {
+			return outcome, err
+		}
+		outcome.PatchPath = path
+
+	default:
+		return outcome, fmt.Errorf("unknown destination %q", dest.Destination)
+	}
+
+	if outcome.Committed {
+		a.recordFinnCommit(folderID, repo)
+	}
+	return outcome, nil
+}
+
+// sendCommitSuccess sends a commit_success event to mobile, describing where
+// the approved changes went. Commit details are included when a commit was made.
+func (a *Agent) sendCommitSuccess(conversationID string, folderPath string, folderID string, outcome approvalOutcome) {
+	repo := git.NewRepository(folderPath)
+
+	data := map[string]interface{}{
 		"conversation_id": conversationID,
 		"folder_id":       folderID,
-		"commit_hash":     commit.FullHash,
-		"short_hash":      commit.Hash,
-		"message":         commit.Message,
-		"author":          commit.Author,
-		"author_email":    commit.Email,
-		"committed_at":    time.Unix(commit.Timestamp, 0).Format(time.RFC3339),
-		"additions":       commit.Stats.Additions,
-		"deletions":       commit.Stats.Deletions,
-		"files_changed":   commit.Stats.FilesChanged,
-	})
+		"destination":     outcome.Destination,
+		"committed":       outcome.Committed,
+	}
+	if outcome.Branch != "" {
+		data["branch"] = outcome.Branch
+	}
+	if outcome.Committed {
+		data["pushed"] = outcome.Pushed
+		if outcome.PushError != "" {
+			data["push_error"] = outcome.PushError
+			data["push_rejected"] = outcome.PushRejected
+		}
+	}
+	if outcome.Stash != "" {
+		data["stash"] = outcome.Stash
+	}
+	if outcome.PatchPath != "" {
+		data["patch_path"] = outcome.PatchPath
+	}
+
+	summary := outcome.Destination
+	if outcome.Committed {
+		latestCommit, err := repo.GetCommits(1)
+		if err != nil || len(latestCommit) == 0 {
+			log.Printf("⚠️  Could not get latest commit for success message")
+			return
+		}
+
+		commit := latestCommit[0]
+		data["commit_hash"] = commit.FullHash
+		data["short_hash"] = commit.Hash
+		data["message"] = commit.Message
+		data["author"] = commit.Author
+		data["author_email"] = commit.Email
+		data["committed_at"] = time.Unix(commit.Timestamp, 0).Format(time.RFC3339)
+		data["additions"] = commit.Stats.Additions
+		data["deletions"] = commit.Stats.Deletions
+		data["files_changed"] = commit.Stats.FilesChanged
+		summary = commit.Hash + " - " + commit.Message
+	}
+
+	payload, _ := json.Marshal(data)
 
 	msg := &ws.Message{
 		UserID:     a.cfg.UserID,
@@ -508,7 +805,7 @@ func (a *Agent) sendCommitSuccess(conversationID string, folderPath string, fold
 	if err := a.wsClient.SendMessage(msg); err != nil {
 		log.Printf("❌ Failed to send commit_success: %v", err)
 	} else {
-		log.Printf("📤 Sent commit_success: %s - %s", commit.Hash, commit.Message)
+		log.Printf("📤 Sent commit_success: %s", summary)
 	}
 
 	// Also send updated folder list with new commits
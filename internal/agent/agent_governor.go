@@ -0,0 +1,235 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/getfinn/finn/internal/claude"
+	ws "github.com/getfinn/finn/internal/websocket"
+)
+
+// How often finished conversations are checked for idle CLI processes
+const reapCheckInterval = time.Minute
+
+// cliProcess is a Claude CLI process counted against the concurrency limit.
+// Fields are guarded by Agent.processesMu.
+type cliProcess struct {
+	conversationID string
+	pid            int       // 0 until the process has started
+	startedAt      time.Time // Zero until the process has started
+	capsError      string    // Why resource caps couldn't be applied
+	cleanup        func()    // Undoes the resource caps
+}
+
+// processHooks returns the governor hooks for a conversation's executor: it
+// caps how many CLI processes run at once, making room by suspending idle
+// ones, and applies the configured memory/CPU caps to each process.
+func (a *Agent) processHooks(conversationID string) claude.ProcessHooks {
+	proc := &cliProcess{conversationID: conversationID}
+
+	return claude.ProcessHooks{
+		Acquire: func() error {
+			limits := a.cfg.EffectiveProcessLimits()
+			if limits.MaxConcurrent > 0 && a.processCount() >= limits.MaxConcurrent {
+				a.reapOldestIdle(conversationID)
+			}
+
+			a.processesMu.Lock()
+			defer a.processesMu.Unlock()
+
+			if limits.MaxConcurrent > 0 && len(a.processes) >= limits.MaxConcurrent {
+				log.Printf("🚦 Refusing to start Claude for %s: %d processes already running", conversationID, len(a.processes))
+				return &claude.CLIError{
+					Code:    claude.ErrorBusy,
+					Message: fmt.Sprintf("%d Claude tasks are already running on this computer", len(a.processes)),
+					Action:  "Wait for a running task to finish or stop one, then retry",
+				}
+			}
+			a.processes[proc] = true
+			return nil
+		},
+
+		Started: func(pid int) {
+			a.resetSessionCost(conversationID)
+
+			limits := a.cfg.EffectiveProcessLimits()
+			caps := claude.ResourceCaps{MemoryMB: limits.MemoryMB, CPUPercent: limits.CPUPercent}
+			cleanup, err := claude.ApplyResourceCaps(pid, caps)
+			if err != nil {
+				log.Printf("⚠️  Resource caps not fully applied to Claude (pid %d): %v", pid, err)
+			}
+
+			a.processesMu.Lock()
+			defer a.processesMu.Unlock()
+			proc.pid = pid
+			proc.startedAt = time.Now()
+			proc.cleanup = cleanup
+			if err != nil {
+				proc.capsError = err.Error()
+			}
+		},
+
+		Release: func() {
+			a.processesMu.Lock()
+			cleanup := proc.cleanup
+			delete(a.processes, proc)
+			*proc = cliProcess{conversationID: conversationID}
+			a.processesMu.Unlock()
+
+			if cleanup != nil {
+				cleanup()
+			}
+		},
+	}
+}
+
+// processCount returns how many CLI processes are running
+func (a *Agent) processCount() int {
+	a.processesMu.Lock()
+	defer a.processesMu.Unlock()
+	return len(a.processes)
+}
+
+// idleExecutor returns a conversation's interactive executor if its CLI
+// process is alive between turns, with the time it went idle.
+func (a *Agent) idleExecutor(conversationID string) (*claude.InteractiveTaskExecutor, time.Time, bool) {
+	return idleInteractive(a.executor(conversationID))
+}
+
+// idleInteractive is idleExecutor for an executor already looked up
+func idleInteractive(executor claude.TaskRunner) (*claude.InteractiveTaskExecutor, time.Time, bool) {
+	interactive, ok := executor.(*claude.InteractiveTaskExecutor)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	since, idle := interactive.IdleSince()
+	return interactive, since, idle
+}
+
+// reapOldestIdle suspends the longest-idle CLI process (other than the
+// requesting conversation's) to make room for a new one.
+func (a *Agent) reapOldestIdle(exclude string) bool {
+	var oldest *claude.InteractiveTaskExecutor
+	var oldestID string
+	var oldestSince time.Time
+
+	for _, conversation := range a.activeConversations() {
+		if conversation.id == exclude {
+			continue
+		}
+		interactive, since, idle := idleInteractive(conversation.executor)
+		if idle && (oldest == nil || since.Before(oldestSince)) {
+			oldest, oldestID, oldestSince = interactive, conversation.id, since
+		}
+	}
+
+	if oldest == nil {
+		return false
+	}
+
+	log.Printf("💤 Process limit reached - suspending %s (idle since %s)", oldestID, oldestSince.Format(time.Kitchen))
+	return oldest.Suspend()
+}
+
+// startProcessReaper suspends CLI processes of conversations that have been
+// idle longer than the configured limit. They re-attach with --resume on the
+// next reprompt.
+func (a *Agent) startProcessReaper() {
+	ticker := time.NewTicker(reapCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.reapIdleProcesses()
+		case <-a.reaperStop:
+			log.Println("🛑 Process reaper stopped")
+			return
+		}
+	}
+}
+
+// reapIdleProcesses suspends every CLI process idle past the limit.
+func (a *Agent) reapIdleProcesses() {
+	limits := a.cfg.EffectiveProcessLimits()
+	if limits.IdleReapMinutes == 0 {
+		return
+	}
+	cutoff := time.Now().Add(-time.Duration(limits.IdleReapMinutes) * time.Minute)
+
+	for _, conversation := range a.activeConversations() {
+		interactive, since, idle := idleInteractive(conversation.executor)
+		if idle && since.Before(cutoff) {
+			log.Printf("💤 Suspending %s - idle for %d+ minutes", conversation.id, limits.IdleReapMinutes)
+			interactive.Suspend()
+		}
+	}
+}
+
+// handleGetResourceStatus sends the current process usage to mobile.
+func (a *Agent) handleGetResourceStatus(msg *ws.Message) {
+	a.sendResourceStatus()
+}
+
+// sendResourceStatus reports running CLI processes against the configured limits.
+func (a *Agent) sendResourceStatus() {
+	limits := a.cfg.EffectiveProcessLimits()
+
+	a.processesMu.Lock()
+	type processInfo struct {
+		conversationID string
+		pid            int
+		startedAt      time.Time
+		capsError      string
+	}
+	var running []processInfo
+	for proc := range a.processes {
+		running = append(running, processInfo{proc.conversationID, proc.pid, proc.startedAt, proc.capsError})
+	}
+	a.processesMu.Unlock()
+
+	processes := make([]map[string]interface{}, 0, len(running))
+	for _, proc := range running {
+		info := map[string]interface{}{
+			"conversation_id": proc.conversationID,
+			"pid":             proc.pid,
+			"idle":            false,
+		}
+		if !proc.startedAt.IsZero() {
+			info["started_at"] = proc.startedAt
+		}
+		if memory := claude.ProcessMemory(proc.pid); memory > 0 {
+			info["memory_bytes"] = memory
+		}
+		if _, since, idle := a.idleExecutor(proc.conversationID); idle {
+			info["idle"] = true
+			info["idle_since"] = since
+		}
+		if proc.capsError != "" {
+			info["caps_error"] = proc.capsError
+		}
+		processes = append(processes, info)
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"running":           len(processes),
+		"max_concurrent":    limits.MaxConcurrent,
+		"idle_reap_minutes": limits.IdleReapMinutes,
+		"memory_mb":         limits.MemoryMB,
+		"cpu_percent":       limits.CPUPercent,
+		"processes":         processes,
+	})
+
+	msg := &ws.Message{
+		UserID:     a.cfg.UserID,
+		DeviceType: "desktop",
+		Type:       ws.MessageTypeResourceStatus,
+		Payload:    payload,
+	}
+
+	if err := a.wsClient.SendMessage(msg); err != nil {
+		log.Printf("Failed to send resource status: %v", err)
+	}
+}
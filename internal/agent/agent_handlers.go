@@ -29,6 +29,46 @@ func (a *Agent) handleMessage(msg *ws.Message) {
 		a.handleReprompt(msg)
 	case ws.MessageTypeSettingsUpdate:
 		a.handleSettingsUpdate(msg)
+	case ws.MessageTypeGetToolOutput:
+		a.handleGetToolOutput(msg)
+	case ws.MessageTypeGetCLIHealth:
+		a.handleGetCLIHealth(msg)
+	case ws.MessageTypeGetResourceStatus:
+		a.handleGetResourceStatus(msg)
+	case ws.MessageTypeGetUsageReport:
+		a.handleGetUsageReport(msg)
+	case ws.MessageTypeListCommands:
+		a.handleListCommands(msg)
+	case ws.MessageTypeRunCommand:
+		a.handleRunCommand(msg)
+	case ws.MessageTypeResolveReferences:
+		a.handleResolveReferences(msg)
+	case ws.MessageTypeListBranches:
+		a.handleListBranches(msg)
+	case ws.MessageTypeCreateBranch:
+		a.handleCreateBranch(msg)
+	case ws.MessageTypeSwitchBranch:
+		a.handleSwitchBranch(msg)
+	case ws.MessageTypeDeleteBranch:
+		a.handleDeleteBranch(msg)
+	case ws.MessageTypeGitFetch:
+		a.handleGitFetch(msg)
+	case ws.MessageTypeGitPull:
+		a.handleGitPull(msg)
+	case ws.MessageTypeGitPush:
+		a.handleGitPush(msg)
+	case ws.MessageTypeGetConflicts:
+		a.handleGetConflicts(msg)
+	case ws.MessageTypeResolveConflicts:
+		a.handleResolveConflicts(msg)
+	case ws.MessageTypeConflictAction:
+		a.handleConflictAction(msg)
+	case ws.MessageTypeCommitBlockedAction:
+		a.handleCommitBlockedAction(msg)
+	case ws.MessageTypeRevertCommit:
+		a.handleRevertCommit(msg)
+	case ws.MessageTypeUndoLastCommit:
+		a.handleUndoLastCommit(msg)
 
 	// Folder management messages
 	case "folder_sync":
@@ -52,6 +92,8 @@ func (a *Agent) handleMessage(msg *ws.Message) {
 	case ws.MessageTypeGetCommitDetail:
 		log.Println("📜 Mobile requested commit details")
 		a.handleGetCommitDetail(msg)
+	case ws.MessageTypeGetCommitConversation:
+		a.handleGetCommitConversation(msg)
 	case "request_commit_sync":
 		a.handleRequestCommitSync(msg)
 
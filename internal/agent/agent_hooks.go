@@ -0,0 +1,209 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/getfinn/finn/internal/claude"
+	"github.com/getfinn/finn/internal/event"
+	"github.com/getfinn/finn/internal/git"
+	ws "github.com/getfinn/finn/internal/websocket"
+)
+
+// Actions offered on a commit blocked by a hook
+const (
+	blockedActionFix      = "fix"       // Claude fixes what the hook reported, then the commit is retried
+	blockedActionNoVerify = "no_verify" // Commit skipping the hooks (allow_no_verify only)
+	blockedActionAbort    = "abort"     // Give up; the changes stay in the working tree
+)
+
+// Automatic fix-and-retry rounds before only no_verify and abort are offered
+const maxHookFixAttempts = 3
+
+// Hook output sent in commit_blocked and, from its end, in the fix prompt
+const (
+	maxHookOutputBytes    = 256 * 1024
+	maxHookFixPromptBytes = 32 * 1024
+)
+
+// blockedCommit is an approval whose commit a hook refused
+type blockedCommit struct {
+	dest     approvalDestination
+	message  string
+	hook     *git.HookFailedError
+	attempts int  // Fix rounds so far
+	fixing   bool // Claude is fixing; retry the commit when its turn completes
+}
+
+// commitApproval applies approved changes and reports the outcome. It returns
+// false when a hook blocked the commit: the conversation then stays open for
+// a commit_blocked_action.
+func (a *Agent) commitApproval(conversationID string, state *ConversationState, dest approvalDestination, commitMsg string) bool {
+	repo := git.NewRepository(state.folderPath)
+	outcome, err := a.applyApproval(state.folderID, repo, dest, commitMsg, state.files)
+
+	var hookErr *git.HookFailedError
+	if errors.As(err, &hookErr) {
+		log.Printf("🪝 Commit blocked by the %s hook", hookErr.Hook)
+		if state.blocked == nil {
+			state.blocked = &blockedCommit{dest: dest, message: commitMsg}
+		}
+		state.blocked.hook = hookErr
+		state.blocked.fixing = false
+		a.sendCommitBlocked(conversationID, state)
+		return false
+	}
+
+	state.blocked = nil
+	if err != nil {
+		log.Printf("❌ Failed to apply changes (%s): %v", outcome.Destination, err)
+		a.sendError(conversationID, fmt.Sprintf("Failed to %s: %v", destinationActions[outcome.Destination], err))
+	} else {
+		log.Printf("✅ Changes applied (%s)", outcome.Destination)
+		a.sendCommitSuccess(conversationID, state.folderPath, state.folderID, outcome)
+	}
+	return true
+}
+
+// handleCommitBlockedAction acts on a commit blocked by a hook
+func (a *Agent) handleCommitBlockedAction(msg *ws.Message) {
+	var payload struct {
+		ConversationID string `json:"conversation_id"`
+		Action         string `json:"action"` // fix, no_verify or abort
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("Failed to parse commit_blocked_action payload: %v", err)
+		return
+	}
+
+	state := a.conversationState(payload.ConversationID)
+	if state == nil || state.blocked == nil {
+		a.sendError(payload.ConversationID, "No blocked commit in this conversation")
+		return
+	}
+	available := false
+	for _, action := range a.blockedCommitActions(state) {
+		available = available || action == payload.Action
+	}
+	if !available {
+		a.sendError(payload.ConversationID, fmt.Sprintf("Action %q isn't available for this commit", payload.Action))
+		return
+	}
+
+	switch payload.Action {
+	case blockedActionFix:
+		executor := state.executor.(*claude.InteractiveTaskExecutor)
+		state.blocked.attempts++
+		state.blocked.fixing = true
+		state.pendingDiffs = make(map[string]bool)
+		state.totalDiffs = 0
+
+		log.Printf("🔧 Asking Claude to fix the %s hook failure (attempt %d/%d)", state.blocked.hook.Hook, state.blocked.attempts, maxHookFixAttempts)
+		prompt := buildHookFixPrompt(state.blocked.hook)
+		go func() {
+			if err := executor.Continue(prompt); err != nil {
+				log.Printf("❌ Hook fix failed: %v", err)
+				state.blocked.fixing = false
+				a.sendTaskError(payload.ConversationID, err)
+			}
+		}()
+
+	case blockedActionNoVerify:
+		log.Printf("⚠️  Committing without the %s hook", state.blocked.hook.Hook)
+		dest := state.blocked.dest
+		dest.noVerify = true
+		if a.commitApproval(payload.ConversationID, state, dest, state.blocked.message) {
+			a.endApprovedConversation(payload.ConversationID)
+		}
+
+	case blockedActionAbort:
+		log.Printf("🛑 Blocked commit aborted - changes left in %s", state.folderPath)
+		a.endApprovedConversation(payload.ConversationID)
+	}
+}
+
+// retryBlockedCommit retries a blocked commit once Claude's fix completes.
+// A failed turn offers the actions again instead.
+func (a *Agent) retryBlockedCommit(conversationID string, ev event.Event) {
+	state := a.conversationState(conversationID)
+	if state == nil || state.blocked == nil || !state.blocked.fixing {
+		return
+	}
+	state.blocked.fixing = false
+
+	if ev.Type != event.TypeComplete {
+		a.sendCommitBlocked(conversationID, state)
+		return
+	}
+
+	log.Printf("🔁 Retrying the commit blocked by the %s hook", state.blocked.hook.Hook)
+	if a.commitApproval(conversationID, state, state.blocked.dest, state.blocked.message) {
+		a.endApprovedConversation(conversationID)
+	}
+}
+
+// endApprovedConversation forgets a conversation whose approval is done
+func (a *Agent) endApprovedConversation(conversationID string) {
+	a.removeConversation(conversationID)
+	log.Printf("🧹 Cleaned up conversation: %s", conversationID)
+}
+
+// blockedCommitActions lists what can be done about a blocked commit
+func (a *Agent) blockedCommitActions(state *ConversationState) []string {
+	var actions []string
+	if _, ok := state.executor.(*claude.InteractiveTaskExecutor); ok && state.blocked.attempts < maxHookFixAttempts {
+		actions = append(actions, blockedActionFix)
+	}
+	if a.cfg.AllowNoVerify && state.blocked.hook.Bypassable {
+		actions = append(actions, blockedActionNoVerify)
+	}
+	return append(actions, blockedActionAbort)
+}
+
+// buildHookFixPrompt asks Claude to fix what a commit hook reported
+func buildHookFixPrompt(hookErr *git.HookFailedError) string {
+	output := hookErr.Output
+	if len(output) > maxHookFixPromptBytes {
+		output = "...\n" + output[len(output)-maxHookFixPromptBytes:]
+	}
+	return fmt.Sprintf(`The user approved your changes, but the git %s hook blocked the commit with this output:
+
+%s
+
+Please fix the problems it reports (do not disable or bypass the hook). The commit will be retried when you're done.`, hookErr.Hook, "```\n"+output+"\n```")
+}
+
+// sendCommitBlocked tells mobile a hook blocked the commit, with its output
+// and the available actions
+func (a *Agent) sendCommitBlocked(conversationID string, state *ConversationState) {
+	blocked := state.blocked
+	output := blocked.hook.Output
+	truncated := len(output) > maxHookOutputBytes
+	if truncated {
+		output = output[len(output)-maxHookOutputBytes:]
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"conversation_id": conversationID,
+		"folder_id":       state.folderID,
+		"hook":            blocked.hook.Hook,
+		"output":          output,
+		"truncated":       truncated,
+		"destination":     blocked.dest.Destination,
+		"attempts":        blocked.attempts,
+		"actions":         a.blockedCommitActions(state),
+	})
+
+	msg := &ws.Message{
+		UserID:     a.cfg.UserID,
+		DeviceType: "desktop",
+		Type:       ws.MessageTypeCommitBlocked,
+		Payload:    payload,
+	}
+
+	if err := a.wsClient.SendMessage(msg); err != nil {
+		log.Printf("Failed to send commit_blocked: %v", err)
+	}
+}
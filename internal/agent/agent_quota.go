@@ -0,0 +1,330 @@
+package agent
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/getfinn/finn/internal/claude"
+	"github.com/getfinn/finn/internal/config"
+	"github.com/getfinn/finn/internal/event"
+	ws "github.com/getfinn/finn/internal/websocket"
+)
+
+const (
+	// How often parked tasks are checked against the wall clock. A ticker
+	// rather than timers, so tasks still resume after the machine sleeps.
+	quotaCheckInterval = 30 * time.Second
+
+	// Extra wait after the reported reset time before resuming
+	quotaResumeGrace = time.Minute
+
+	// Retry interval when Claude didn't say when the limit resets
+	quotaDefaultRetry = 30 * time.Minute
+
+	// Backoff for overloads and rate limits: doubles from the first retry up
+	// to the max, giving up after rateLimitMaxRetries in a row
+	rateLimitFirstRetry = time.Minute
+	rateLimitMaxRetry   = 15 * time.Minute
+	rateLimitMaxRetries = 6
+
+	// Prompt sent to a parked session once the limit resets
+	quotaContinuePrompt = "continue"
+)
+
+// parkedTask is a conversation waiting for the Claude usage limit to reset,
+// or to retry after an overload or rate limit. Parked tasks are persisted so
+// they resume even if the daemon restarts.
+type parkedTask struct {
+	ConversationID string    `json:"conversation_id"`
+	FolderID       string    `json:"folder_id"`
+	FolderPath     string    `json:"folder_path"`
+	SessionID      string    `json:"session_id,omitempty"` // Resumed with "continue" when set
+	Prompt         string    `json:"prompt,omitempty"`     // Re-run when no session was started
+	ResetsAt       time.Time `json:"resets_at,omitempty"`  // As reported by Claude (zero if unknown)
+	ResumeAt       time.Time `json:"resume_at"`
+	ParkedAt       time.Time `json:"parked_at"`
+	Reason         string    `json:"reason,omitempty"`  // usage_limit or rate_limited
+	Retries        int       `json:"retries,omitempty"` // Consecutive rate-limited parks
+}
+
+// parkedTasksPath returns where parked tasks are persisted
+func parkedTasksPath() string {
+	return filepath.Join(config.Dir(), "parked_tasks.json")
+}
+
+// loadParkedTasks restores parked tasks saved by a previous run.
+func (a *Agent) loadParkedTasks() {
+	data, err := os.ReadFile(parkedTasksPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️  Failed to read parked tasks: %v", err)
+		}
+		return
+	}
+
+	var tasks map[string]parkedTask
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		log.Printf("⚠️  Failed to parse parked tasks: %v", err)
+		return
+	}
+
+	a.parkedMu.Lock()
+	a.parkedTasks = tasks
+	a.parkedMu.Unlock()
+
+	if len(tasks) > 0 {
+		log.Printf("⏸️  Restored %d task(s) waiting for Claude quota", len(tasks))
+	}
+}
+
+// saveParkedTasks persists parked tasks. Caller holds parkedMu.
+func (a *Agent) saveParkedTasks() {
+	data, err := json.MarshalIndent(a.parkedTasks, "", "  ")
+	if err != nil {
+		log.Printf("⚠️  Failed to encode parked tasks: %v", err)
+		return
+	}
+
+	path := parkedTasksPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Printf("⚠️  Failed to save parked tasks: %v", err)
+		return
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		log.Printf("⚠️  Failed to save parked tasks: %v", err)
+	}
+}
+
+// parkIfLimited parks a conversation whose error event reports a usage
+// limit, or an overload or rate limit (retried with backoff). A successful
+// turn resets the backoff.
+func (a *Agent) parkIfLimited(conversationID string, ev event.Event) {
+	state := a.conversationState(conversationID)
+	if state == nil {
+		// One-shot tasks have no session to continue
+		return
+	}
+	if ev.Type == event.TypeComplete {
+		// A failed turn completes too, right after parking: keep its backoff
+		if !a.isParked(conversationID) {
+			state.rateLimited = 0
+		}
+		return
+	}
+
+	var errData event.Error
+	if err := ev.Decode(&errData); err != nil {
+		return
+	}
+	switch claude.ErrorCode(errData.Code) {
+	case claude.ErrorUsageLimit:
+	case claude.ErrorRateLimited:
+		if state.rateLimited >= rateLimitMaxRetries {
+			log.Printf("⚠️  %s still rate limited after %d retries - leaving it to the user", conversationID, state.rateLimited)
+			state.rateLimited = 0
+			return
+		}
+		state.rateLimited++
+	default:
+		return
+	}
+
+	task := parkedTask{
+		ConversationID: conversationID,
+		FolderID:       state.folderID,
+		FolderPath:     state.folderPath,
+		Prompt:         state.prompt,
+		ParkedAt:       time.Now(),
+	}
+	if interactive, ok := state.executor.(*claude.InteractiveTaskExecutor); ok {
+		task.SessionID = interactive.SessionID()
+	}
+
+	task.Reason = errData.Code
+	switch {
+	case errData.Code == string(claude.ErrorRateLimited):
+		task.Retries = state.rateLimited
+		task.ResumeAt = task.ParkedAt.Add(rateLimitBackoff(state.rateLimited))
+	case errData.ResetsAt != nil:
+		task.ResetsAt = *errData.ResetsAt
+		task.ResumeAt = task.ResetsAt.Add(quotaResumeGrace)
+	default:
+		task.ResumeAt = task.ParkedAt.Add(quotaDefaultRetry)
+	}
+
+	a.parkedMu.Lock()
+	if a.parkedTasks == nil {
+		a.parkedTasks = make(map[string]parkedTask)
+	}
+	a.parkedTasks[conversationID] = task
+	a.saveParkedTasks()
+	a.parkedMu.Unlock()
+
+	log.Printf("⏸️  Parked %s until %s (%s)", conversationID, task.ResumeAt.Format(time.RFC3339), task.Reason)
+	a.sendQuotaStatus(ws.MessageTypeTaskParked, task)
+}
+
+// rateLimitBackoff is how long to wait before the nth retry (from 1) of a
+// rate-limited task
+func rateLimitBackoff(retry int) time.Duration {
+	wait := rateLimitFirstRetry
+	for i := 1; i < retry && wait < rateLimitMaxRetry; i++ {
+		wait *= 2
+	}
+	if wait > rateLimitMaxRetry {
+		wait = rateLimitMaxRetry
+	}
+	return wait
+}
+
+// isParked reports whether a conversation is waiting to resume
+func (a *Agent) isParked(conversationID string) bool {
+	a.parkedMu.Lock()
+	defer a.parkedMu.Unlock()
+	_, ok := a.parkedTasks[conversationID]
+	return ok
+}
+
+// unparkTask drops a parked task, e.g. because the user sent a new prompt for it.
+func (a *Agent) unparkTask(conversationID string) {
+	a.parkedMu.Lock()
+	defer a.parkedMu.Unlock()
+
+	if _, ok := a.parkedTasks[conversationID]; !ok {
+		return
+	}
+	delete(a.parkedTasks, conversationID)
+	a.saveParkedTasks()
+	log.Printf("▶️  Unparked %s", conversationID)
+}
+
+// startQuotaScheduler resumes parked tasks once their usage limit has reset.
+func (a *Agent) startQuotaScheduler() {
+	ticker := time.NewTicker(quotaCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.resumeDueTasks()
+		case <-a.quotaStop:
+			log.Println("🛑 Quota scheduler stopped")
+			return
+		}
+	}
+}
+
+// resumeDueTasks resumes every parked task whose resume time has passed.
+func (a *Agent) resumeDueTasks() {
+	if !a.wsClient.IsConnected() {
+		// Wait for the relay so mobile sees the resumed task
+		return
+	}
+
+	now := time.Now()
+	var due []parkedTask
+
+	a.parkedMu.Lock()
+	for id, task := range a.parkedTasks {
+		if !now.Before(task.ResumeAt) {
+			due = append(due, task)
+			delete(a.parkedTasks, id)
+		}
+	}
+	if len(due) > 0 {
+		a.saveParkedTasks()
+	}
+	a.parkedMu.Unlock()
+
+	for _, task := range due {
+		a.resumeParkedTask(task)
+	}
+}
+
+// resumeParkedTask continues a parked conversation in its Claude session.
+func (a *Agent) resumeParkedTask(task parkedTask) {
+	log.Printf("▶️  Usage limit reset - resuming %s", task.ConversationID)
+	a.sendQuotaStatus(ws.MessageTypeTaskResumed, task)
+
+	// Still have the executor: continue in the same session
+	if state := a.conversationState(task.ConversationID); state != nil {
+		if interactive, ok := state.executor.(*claude.InteractiveTaskExecutor); ok && interactive.SessionID() != "" {
+			go func() {
+				if err := interactive.Continue(quotaContinuePrompt); err != nil {
+					log.Printf("❌ Failed to resume parked task: %v", err)
+					a.sendTaskError(task.ConversationID, err)
+				}
+			}()
+			return
+		}
+	}
+
+	// Daemon restarted since parking: rebuild the conversation from the saved task
+	state := &ConversationState{
+		pendingDiffs: make(map[string]bool),
+		folderPath:   task.FolderPath,
+		folderID:     task.FolderID,
+		prompt:       task.Prompt,
+		rateLimited:  task.Retries,
+	}
+	onEvent := func(ev event.Event) {
+		if ev.Type == event.TypeDiff {
+			a.trackDiffEvent(state, ev)
+		}
+		a.sendClaudeEvent(task.ConversationID, ev)
+	}
+
+	executor, err := a.newInteractiveExecutor(task.ConversationID, task.FolderPath, onEvent)
+	if err != nil {
+		log.Printf("❌ Failed to create executor: %v", err)
+		a.sendError(task.ConversationID, err.Error())
+		return
+	}
+	executor.SetSessionLinkedHandler(func(sid string) {
+		a.sendSessionLinked(task.ConversationID, sid, task.FolderID)
+	})
+
+	state.executor = executor
+	a.setConversation(task.ConversationID, executor, state)
+
+	go func() {
+		var err error
+		if task.SessionID != "" {
+			err = executor.ResumeSession(task.SessionID, quotaContinuePrompt)
+		} else {
+			err = executor.ExecuteTask(task.Prompt)
+		}
+		if err != nil {
+			log.Printf("❌ Failed to resume parked task: %v", err)
+			a.sendTaskError(task.ConversationID, err)
+		}
+	}()
+}
+
+// sendQuotaStatus notifies mobile that a task was parked or resumed.
+func (a *Agent) sendQuotaStatus(msgType ws.MessageType, task parkedTask) {
+	data := map[string]interface{}{
+		"conversation_id": task.ConversationID,
+		"folder_id":       task.FolderID,
+		"resume_at":       task.ResumeAt,
+		"reason":          task.Reason,
+	}
+	if !task.ResetsAt.IsZero() {
+		data["resets_at"] = task.ResetsAt
+	}
+	payload, _ := json.Marshal(data)
+
+	msg := &ws.Message{
+		UserID:     a.cfg.UserID,
+		DeviceType: "desktop",
+		Type:       msgType,
+		Payload:    payload,
+	}
+
+	if err := a.wsClient.SendMessage(msg); err != nil {
+		log.Printf("Failed to send %s: %v", msgType, err)
+	}
+}
@@ -0,0 +1,642 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/getfinn/finn/internal/git"
+	ws "github.com/getfinn/finn/internal/websocket"
+)
+
+// Reference kinds
+const (
+	referenceFile    = "file"
+	referenceCommit  = "commit"
+	referenceSession = "session"
+	referenceDirty   = "dirty"
+)
+
+// Reference expansion limits
+const (
+	maxReferences          = 10         // Expanded per prompt; the rest are left as written
+	maxReferenceBytes      = 64 * 1024  // Content of one reference
+	maxReferencesBytes     = 256 * 1024 // Content of all references of a prompt
+	maxReferenceFiles      = 20000      // Files considered for fuzzy matching
+	maxSessionMessages     = 10         // Most recent messages in a session summary
+	maxSessionMessageChars = 1000
+	defaultSuggestions     = 20
+)
+
+// referencePattern finds reference tokens. The leading group keeps e-mail
+// addresses and decorators like "foo@bar" from being read as references.
+var referencePattern = regexp.MustCompile(`(^|[\s(\[{"'` + "`" + `])@(dirty\b|commit:[0-9A-Za-z]+|session:[0-9A-Za-z-]+|[A-Za-z0-9_./-]+)`)
+
+// commitHashPattern is what a commit reference may point at
+var commitHashPattern = regexp.MustCompile(`^[0-9a-fA-F]{4,40}$`)
+
+// skippedDirs are never searched for file references
+var skippedDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+	"vendor":       true,
+	".venv":        true,
+	"__pycache__":  true,
+	"dist":         true,
+	"build":        true,
+}
+
+// reference is a resolved (or unresolvable) reference token
+type reference struct {
+	Token  string `json:"token"` // As written, e.g. "@src/app"
+	Kind   string `json:"kind"`
+	Target string `json:"target,omitempty"` // File path, full commit hash or session ID
+	Label  string `json:"label,omitempty"`  // Human-readable description
+	Error  string `json:"error,omitempty"`  // Why it couldn't be resolved
+
+	content string // Expanded context for Claude
+}
+
+// referenceSuggestion is an autocomplete candidate
+type referenceSuggestion struct {
+	Token  string `json:"token"` // Replaces the partial token
+	Kind   string `json:"kind"`
+	Label  string `json:"label"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// expandReferences resolves the reference tokens in a prompt and returns the
+// prompt with their context appended, plus what each token resolved to. The
+// prompt text itself is left as written.
+func (a *Agent) expandReferences(folderPath, text string) (string, []reference) {
+	refs := a.resolveReferences(folderPath, text)
+	if len(refs) == 0 {
+		return text, nil
+	}
+
+	var context strings.Builder
+	total := 0
+	for _, ref := range refs {
+		if ref.Error != "" {
+			continue
+		}
+		content := truncateReference(ref.content, maxReferenceBytes)
+		if total+len(content) > maxReferencesBytes {
+			content = truncateReference(content, maxReferencesBytes-total)
+		}
+		total += len(content)
+
+		fmt.Fprintf(&context, "\n<reference token=%q kind=%q target=%q>\n%s\n</reference>\n", ref.Token, ref.Kind, ref.Target, content)
+		if total >= maxReferencesBytes {
+			break
+		}
+	}
+	if context.Len() == 0 {
+		return text, refs
+	}
+
+	return text + "\n\nThe user referenced the following context:\n" + context.String(), refs
+}
+
+// resolveReferences resolves each distinct reference token in text, up to
+// maxReferences
+func (a *Agent) resolveReferences(folderPath, text string) []reference {
+	var refs []reference
+	seen := make(map[string]bool)
+	var files []string // Listed on the first file reference
+
+	for _, match := range referencePattern.FindAllStringSubmatch(text, -1) {
+		value := match[2]
+		if !strings.Contains(value, ":") && value != referenceDirty {
+			// Sentence punctuation after a path isn't part of it
+			value = strings.TrimRight(value, ".,;:")
+		}
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		if len(refs) == maxReferences {
+			log.Printf("⚠️  More than %d references, ignoring the rest", maxReferences)
+			break
+		}
+
+		var ref reference
+		switch {
+		case value == referenceDirty:
+			ref = resolveDirtyReference(folderPath)
+		case strings.HasPrefix(value, "commit:"):
+			ref = resolveCommitReference(folderPath, strings.TrimPrefix(value, "commit:"))
+		case strings.HasPrefix(value, "session:"):
+			ref = a.resolveSessionReference(folderPath, strings.TrimPrefix(value, "session:"))
+		default:
+			if files == nil {
+				files = listFolderFiles(folderPath)
+			}
+			ref = resolveFileReference(folderPath, value, files)
+		}
+		ref.Token = "@" + value
+
+		if ref.Error != "" {
+			log.Printf("⚠️  Unresolved reference %s: %s", ref.Token, ref.Error)
+		} else {
+			log.Printf("🔗 Resolved reference %s → %s", ref.Token, ref.Target)
+		}
+		refs = append(refs, ref)
+	}
+
+	return refs
+}
+
+// resolveDirtyReference expands to the folder's uncommitted diff
+func resolveDirtyReference(folderPath string) reference {
+	ref := reference{Kind: referenceDirty, Target: "working tree", Label: "Uncommitted changes"}
+
+	repo := git.NewRepository(folderPath)
+	diffs, err := repo.GenerateAllDiffs()
+	if err != nil {
+		ref.Error = fmt.Sprintf("failed to get uncommitted changes: %v", err)
+		return ref
+	}
+	if len(diffs) == 0 {
+		ref.Error = "no uncommitted changes"
+		return ref
+	}
+
+	paths := make([]string, 0, len(diffs))
+	for path := range diffs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var content strings.Builder
+	for _, path := range paths {
+		content.WriteString(diffs[path])
+		if !strings.HasSuffix(diffs[path], "\n") {
+			content.WriteString("\n")
+		}
+	}
+	ref.Label = fmt.Sprintf("Uncommitted changes (%d files)", len(paths))
+	ref.content = content.String()
+	return ref
+}
+
+// resolveCommitReference expands to a commit's message and diff
+func resolveCommitReference(folderPath, hash string) reference {
+	ref := reference{Kind: referenceCommit, Target: hash}
+	if !commitHashPattern.MatchString(hash) {
+		ref.Error = "not a commit hash"
+		return ref
+	}
+
+	repo := git.NewRepository(folderPath)
+	details, err := repo.GetCommitDetails(hash)
+	if err != nil {
+		ref.Error = "commit not found"
+		return ref
+	}
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "commit %s\nAuthor: %s <%s>\nDate: %s\n\n%s\n\n",
+		details.FullHash, details.Author, details.Email,
+		time.Unix(details.Timestamp, 0).Format(time.RFC1123Z), strings.TrimSpace(details.FullMessage))
+	for _, file := range details.Files {
+		content.WriteString(file.Diff)
+		if !strings.HasSuffix(file.Diff, "\n") {
+			content.WriteString("\n")
+		}
+	}
+
+	ref.Target = details.FullHash
+	ref.Label = fmt.Sprintf("%s %s", details.Hash, details.Message)
+	ref.content = content.String()
+	return ref
+}
+
+// resolveSessionReference expands to a summary of a Claude session of the
+// folder. The ID may be a unique prefix.
+func (a *Agent) resolveSessionReference(folderPath, id string) reference {
+	ref := reference{Kind: referenceSession, Target: id}
+	if a.sessionWatcher == nil {
+		ref.Error = "session watcher not running"
+		return ref
+	}
+
+	var matches []string
+	for _, session := range a.sessionWatcher.ScanProjectSessions(folderPath) {
+		if session.SessionID == id {
+			matches = []string{id}
+			break
+		}
+		if strings.HasPrefix(session.SessionID, id) {
+			matches = append(matches, session.SessionID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		ref.Error = "session not found"
+		return ref
+	case 1:
+	default:
+		ref.Error = fmt.Sprintf("ambiguous session ID (%d matches)", len(matches))
+		return ref
+	}
+
+	session := a.sessionWatcher.GetSession(matches[0])
+	if session == nil {
+		ref.Error = "session not found"
+		return ref
+	}
+	messages, err := a.sessionWatcher.GetSessionMessages(session.SessionID)
+	if err != nil {
+		ref.Error = fmt.Sprintf("failed to read session: %v", err)
+		return ref
+	}
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "Session %s\nTitle: %s\nModel: %s\nMessages: %d\nCost: $%.2f\nLast activity: %s\n",
+		session.SessionID, session.Title, session.Model, session.MessageCount,
+		session.TotalCostUSD, session.LastActivity.Format(time.RFC1123Z))
+
+	// The most recent exchanges say most about where the session stands
+	var recent []string
+	for i := len(messages) - 1; i >= 0 && len(recent) < maxSessionMessages; i-- {
+		role := messages[i].GetRole()
+		if role != "user" && role != "assistant" {
+			continue
+		}
+		text := strings.TrimSpace(messages[i].GetTextContent())
+		if text == "" {
+			continue
+		}
+		if len(text) > maxSessionMessageChars {
+			text = truncateReference(text, maxSessionMessageChars)
+		}
+		recent = append(recent, fmt.Sprintf("[%s] %s", role, text))
+	}
+	if len(recent) > 0 {
+		content.WriteString("\nMost recent messages:\n")
+		for i := len(recent) - 1; i >= 0; i-- {
+			content.WriteString(recent[i] + "\n")
+		}
+	}
+
+	ref.Target = session.SessionID
+	ref.Label = session.Title
+	ref.content = content.String()
+	return ref
+}
+
+// resolveFileReference expands to the contents of a file in the folder,
+// matched exactly or else by name, path suffix or substring. A query whose
+// characters a path only has in order stays unresolved: "@param" or
+// "@alice" in a prompt is likely not meant as a file.
+func resolveFileReference(folderPath, query string, files []string) reference {
+	ref := reference{Kind: referenceFile}
+
+	path := ""
+	if clean := filepath.ToSlash(filepath.Clean(query)); containsFile(files, clean) {
+		path = clean
+	} else if matches := fuzzyMatchFiles(query, files, 1, substringScore); len(matches) > 0 {
+		path = matches[0]
+	}
+	if path == "" {
+		ref.Error = "no matching file"
+		return ref
+	}
+
+	full, err := folderFile(folderPath, path)
+	if err != nil {
+		ref.Error = err.Error()
+		return ref
+	}
+	data, err := os.ReadFile(full)
+	if err != nil {
+		ref.Error = fmt.Sprintf("failed to read file: %v", err)
+		return ref
+	}
+
+	ref.Target = path
+	ref.Label = path
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		ref.content = fmt.Sprintf("(binary file, %d bytes - read it from the project if needed)", len(data))
+	} else {
+		ref.content = string(data)
+	}
+	return ref
+}
+
+// folderFile returns the absolute path of a file in the folder, refusing
+// anything that leads outside it (including through symlinks)
+func folderFile(folderPath, path string) (string, error) {
+	root, err := filepath.EvalSymlinks(folderPath)
+	if err != nil {
+		return "", fmt.Errorf("folder not accessible")
+	}
+	full, err := filepath.EvalSymlinks(filepath.Join(folderPath, filepath.FromSlash(path)))
+	if err != nil {
+		return "", fmt.Errorf("file not accessible")
+	}
+	rel, err := filepath.Rel(root, full)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file is outside the folder")
+	}
+	return full, nil
+}
+
+// listFolderFiles lists the files of a folder as slash-separated relative
+// paths: tracked and untracked-but-not-ignored files in a git repository,
+// otherwise a walk of the folder.
+func listFolderFiles(folderPath string) []string {
+	cmd := exec.Command("git", "ls-files", "--cached", "--others", "--exclude-standard", "-z")
+	cmd.Dir = folderPath
+	if output, err := cmd.Output(); err == nil {
+		var files []string
+		for _, path := range strings.Split(string(output), "\x00") {
+			if path != "" && len(files) < maxReferenceFiles {
+				files = append(files, path)
+			}
+		}
+		return files
+	}
+
+	var files []string
+	filepath.WalkDir(folderPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != folderPath && (skippedDirs[d.Name()] || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if len(files) == maxReferenceFiles {
+			return filepath.SkipAll
+		}
+		if rel, err := filepath.Rel(folderPath, path); err == nil && d.Type().IsRegular() {
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return files
+}
+
+// containsFile reports whether files has path
+func containsFile(files []string, path string) bool {
+	for _, file := range files {
+		if file == path {
+			return true
+		}
+	}
+	return false
+}
+
+// fuzzyMatchFiles returns up to limit files matching query with at least
+// minScore, best first
+func fuzzyMatchFiles(query string, files []string, limit, minScore int) []string {
+	type match struct {
+		path  string
+		score int
+	}
+	var matches []match
+	for _, file := range files {
+		if score := fuzzyScore(query, file); score > 0 && score >= minScore {
+			matches = append(matches, match{file, score})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		if len(matches[i].path) != len(matches[j].path) {
+			return len(matches[i].path) < len(matches[j].path)
+		}
+		return matches[i].path < matches[j].path
+	})
+
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	paths := make([]string, len(matches))
+	for i, m := range matches {
+		paths[i] = m.path
+	}
+	return paths
+}
+
+// substringScore is the lowest fuzzyScore of a path containing the query;
+// lower scores only have its characters in order
+const substringScore = 500
+
+// fuzzyScore rates how well path matches query: exact path, then file name,
+// then path suffix, then substring, then the query's characters in order.
+// Zero means no match.
+func fuzzyScore(query, path string) int {
+	q := strings.ToLower(query)
+	p := strings.ToLower(path)
+	base := p[strings.LastIndex(p, "/")+1:]
+
+	switch {
+	case q == "":
+		return 1
+	case p == q:
+		return 1000
+	case base == q:
+		return 900
+	case strings.HasSuffix(p, "/"+q):
+		return 800
+	case strings.HasPrefix(base, q):
+		return 700
+	case strings.Contains(base, q):
+		return 600
+	case strings.Contains(p, q):
+		return substringScore
+	}
+
+	// Subsequence: reward runs of consecutive characters
+	score, run, qi := 0, 0, 0
+	for pi := 0; pi < len(p) && qi < len(q); pi++ {
+		if p[pi] == q[qi] {
+			run++
+			score += run
+			qi++
+		} else {
+			run = 0
+		}
+	}
+	if qi < len(q) {
+		return 0
+	}
+	return min(score, 400)
+}
+
+// truncateReference cuts content to at most limit bytes on a UTF-8 boundary,
+// marking the cut
+func truncateReference(content string, limit int) string {
+	if len(content) <= limit {
+		return content
+	}
+	const marker = "\n... (truncated)"
+	cut := limit - len(marker)
+	if cut < 0 {
+		cut = 0
+	}
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	return content[:cut] + marker
+}
+
+// handleResolveReferences serves reference autocomplete. With a query (a
+// partial token like "@src/ap" or "@commit:3f") it suggests completions;
+// with text it reports what each token of a draft prompt resolves to.
+func (a *Agent) handleResolveReferences(msg *ws.Message) {
+	var payload struct {
+		FolderID string `json:"folder_id"`
+		Query    string `json:"query,omitempty"`
+		Text     string `json:"text,omitempty"`
+		Limit    int    `json:"limit,omitempty"`
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("Failed to parse resolve_references payload: %v", err)
+		return
+	}
+	if payload.Limit <= 0 {
+		payload.Limit = defaultSuggestions
+	}
+
+	data := map[string]interface{}{
+		"folder_id": payload.FolderID,
+		"query":     payload.Query,
+	}
+
+	folder := a.cfg.GetFolderByID(payload.FolderID)
+	if folder == nil {
+		log.Printf("❌ Folder not found: %s", payload.FolderID)
+		data["error"] = "Folder not found"
+		a.sendReferencesResolved(data)
+		return
+	}
+
+	data["suggestions"] = a.suggestReferences(folder.Path, payload.Query, payload.Limit)
+	if payload.Text != "" {
+		refs := a.resolveReferences(folder.Path, payload.Text)
+		if refs == nil {
+			refs = []reference{}
+		}
+		data["references"] = refs
+	}
+	a.sendReferencesResolved(data)
+}
+
+// suggestReferences lists completions for a partial reference token
+func (a *Agent) suggestReferences(folderPath, query string, limit int) []referenceSuggestion {
+	query = strings.TrimPrefix(query, "@")
+	suggestions := []referenceSuggestion{}
+
+	switch {
+	case strings.HasPrefix(query, "commit:"):
+		prefix := strings.ToLower(strings.TrimPrefix(query, "commit:"))
+		commits, err := git.NewRepository(folderPath).GetCommits(100)
+		if err != nil {
+			return suggestions
+		}
+		for _, commit := range commits {
+			if len(suggestions) == limit {
+				break
+			}
+			if strings.HasPrefix(commit.FullHash, prefix) || strings.Contains(strings.ToLower(commit.Message), prefix) {
+				suggestions = append(suggestions, referenceSuggestion{
+					Token:  "@commit:" + commit.Hash,
+					Kind:   referenceCommit,
+					Label:  commit.Message,
+					Detail: fmt.Sprintf("%s · %s", commit.Author, time.Unix(commit.Timestamp, 0).Format("2006-01-02")),
+				})
+			}
+		}
+		return suggestions
+
+	case strings.HasPrefix(query, "session:"):
+		prefix := strings.ToLower(strings.TrimPrefix(query, "session:"))
+		if a.sessionWatcher == nil {
+			return suggestions
+		}
+		sessions := a.sessionWatcher.ScanProjectSessions(folderPath)
+		sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastActivity.After(sessions[j].LastActivity) })
+		for _, session := range sessions {
+			if len(suggestions) == limit {
+				break
+			}
+			if strings.HasPrefix(session.SessionID, prefix) || strings.Contains(strings.ToLower(session.Title), prefix) {
+				suggestions = append(suggestions, referenceSuggestion{
+					Token:  "@session:" + session.SessionID,
+					Kind:   referenceSession,
+					Label:  session.Title,
+					Detail: fmt.Sprintf("%d messages · %s", session.MessageCount, session.LastActivity.Format("2006-01-02 15:04")),
+				})
+			}
+		}
+		return suggestions
+	}
+
+	// The special forms first, while they still match what's typed
+	for _, special := range []referenceSuggestion{
+		{Token: "@dirty", Kind: referenceDirty, Label: "Uncommitted changes"},
+		{Token: "@commit:", Kind: referenceCommit, Label: "A commit"},
+		{Token: "@session:", Kind: referenceSession, Label: "A Claude session"},
+	} {
+		if strings.HasPrefix(special.Token, "@"+query) && len(suggestions) < limit {
+			suggestions = append(suggestions, special)
+		}
+	}
+
+	for _, path := range fuzzyMatchFiles(query, listFolderFiles(folderPath), limit-len(suggestions), 1) {
+		suggestions = append(suggestions, referenceSuggestion{
+			Token:  "@" + path,
+			Kind:   referenceFile,
+			Label:  path[strings.LastIndex(path, "/")+1:],
+			Detail: path,
+		})
+	}
+	return suggestions
+}
+
+// sendPromptReferences tells mobile what the references of a prompt resolved
+// to, so it can show which were attached and which weren't found
+func (a *Agent) sendPromptReferences(conversationID, folderID string, refs []reference) {
+	if len(refs) == 0 {
+		return
+	}
+	a.sendReferencesResolved(map[string]interface{}{
+		"conversation_id": conversationID,
+		"folder_id":       folderID,
+		"references":      refs,
+	})
+}
+
+// sendReferencesResolved sends a references_resolved message
+func (a *Agent) sendReferencesResolved(data map[string]interface{}) {
+	payload, _ := json.Marshal(data)
+
+	msg := &ws.Message{
+		UserID:     a.cfg.UserID,
+		DeviceType: "desktop",
+		Type:       ws.MessageTypeReferencesResolved,
+		Payload:    payload,
+	}
+
+	if err := a.wsClient.SendMessage(msg); err != nil {
+		log.Printf("Failed to send resolved references: %v", err)
+	}
+}
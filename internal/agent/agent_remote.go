@@ -0,0 +1,185 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/getfinn/finn/internal/git"
+	ws "github.com/getfinn/finn/internal/websocket"
+)
+
+// Remote sync actions reported in git_sync_result
+const (
+	syncFetch = "fetch"
+	syncPull  = "pull"
+	syncPush  = "push"
+)
+
+// handleGitFetch fetches all remotes of a folder and reports how far the
+// current branch is ahead of and behind its upstream.
+func (a *Agent) handleGitFetch(msg *ws.Message) {
+	var payload struct {
+		FolderID string `json:"folder_id"`
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("Failed to parse git_fetch payload: %v", err)
+		return
+	}
+
+	repo, _, ok := a.syncRepository(payload.FolderID, syncFetch, false)
+	if !ok {
+		return
+	}
+
+	if err := repo.Fetch(); err != nil {
+		log.Printf("❌ Fetch failed: %v", err)
+		a.sendSyncResult(payload.FolderID, syncFetch, repo, nil, err)
+		return
+	}
+
+	log.Printf("⬇️  Fetched remotes of %s", payload.FolderID)
+	a.sendSyncResult(payload.FolderID, syncFetch, repo, nil, nil)
+}
+
+// handleGitPull pulls the upstream of the current branch by merge or rebase.
+// Uncommitted changes stop it; conflicts leave it in progress and are sent
+// for resolution.
+func (a *Agent) handleGitPull(msg *ws.Message) {
+	var payload struct {
+		FolderID string           `json:"folder_id"`
+		Strategy git.PullStrategy `json:"strategy,omitempty"` // merge (default) or rebase
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("Failed to parse git_pull payload: %v", err)
+		return
+	}
+
+	repo, folderPath, ok := a.syncRepository(payload.FolderID, syncPull, true)
+	if !ok {
+		return
+	}
+
+	result, err := repo.Pull(payload.Strategy)
+	if err != nil {
+		log.Printf("❌ Pull failed: %v", err)
+		a.sendSyncResult(payload.FolderID, syncPull, repo, nil, err)
+		var conflict *git.PullConflictError
+		if errors.As(err, &conflict) {
+			a.sendFolderConflicts(payload.FolderID, folderPath)
+		}
+		return
+	}
+
+	log.Printf("⬇️  Pulled %d commit(s) into %s (%s)", result.Pulled, payload.FolderID, result.Strategy)
+	a.sendSyncResult(payload.FolderID, syncPull, repo, result, nil)
+	if !result.UpToDate {
+		a.sendFolderListUpdate()
+	}
+}
+
+// handleGitPush pushes the current branch, setting its upstream if it has none.
+func (a *Agent) handleGitPush(msg *ws.Message) {
+	var payload struct {
+		FolderID string `json:"folder_id"`
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("Failed to parse git_push payload: %v", err)
+		return
+	}
+
+	repo, _, ok := a.syncRepository(payload.FolderID, syncPush, false)
+	if !ok {
+		return
+	}
+
+	if err := repo.Push(); err != nil {
+		log.Printf("❌ Push failed: %v", err)
+		a.sendSyncResult(payload.FolderID, syncPush, repo, nil, err)
+		return
+	}
+
+	log.Printf("⬆️  Pushed %s", payload.FolderID)
+	a.sendSyncResult(payload.FolderID, syncPush, repo, nil, nil)
+}
+
+// syncRepository looks up the folder of a sync request, replying with an
+// error if it isn't usable. Changing files is refused while Claude works in
+// the folder.
+func (a *Agent) syncRepository(folderID, action string, changesFiles bool) (*git.Repository, string, bool) {
+	folder := a.cfg.GetFolderByID(folderID)
+	if folder == nil {
+		log.Printf("❌ Folder not found: %s", folderID)
+		a.sendSyncResult(folderID, action, nil, nil, errors.New("Folder not found"))
+		return nil, "", false
+	}
+	if !git.IsGitRepo(folder.Path) {
+		a.sendSyncResult(folderID, action, nil, nil, errors.New("Not a git repository"))
+		return nil, "", false
+	}
+	if changesFiles && a.taskRunningIn(folder.Path) {
+		log.Printf("⚠️  Refusing to %s while a task is running in %s", action, folder.Path)
+		a.sendSyncResult(folderID, action, nil, nil, errors.New("A task is running in this folder"))
+		return nil, "", false
+	}
+	return git.NewRepository(folder.Path), folder.Path, true
+}
+
+// isPushRejected reports whether a push failed because the remote has diverged
+func isPushRejected(err error) bool {
+	var rejected *git.PushRejectedError
+	return errors.As(err, &rejected)
+}
+
+// sendSyncResult reports the outcome of a fetch, pull or push with the
+// branch's position relative to its upstream afterwards. Failures say why in
+// a form mobile can act on: rejected pushes, uncommitted changes, conflicts.
+func (a *Agent) sendSyncResult(folderID, action string, repo *git.Repository, pull *git.PullResult, err error) {
+	data := map[string]interface{}{
+		"folder_id": folderID,
+		"action":    action,
+		"success":   err == nil,
+	}
+	if repo != nil {
+		if status, statusErr := repo.GetSyncStatus(); statusErr == nil {
+			data["status"] = status
+		}
+	}
+	if pull != nil {
+		data["pull"] = pull
+	}
+
+	if err != nil {
+		data["error"] = err.Error()
+
+		var rejected *git.PushRejectedError
+		var dirty *git.DirtyTreeError
+		var conflict *git.PullConflictError
+		switch {
+		case errors.As(err, &rejected):
+			data["rejected"] = true
+			data["reason"] = rejected.Reason
+		case errors.As(err, &dirty):
+			data["dirty"] = true
+			data["changed_files"] = dirty.Files
+		case errors.As(err, &conflict):
+			data["conflicts"] = conflict.Files
+		case errors.Is(err, git.ErrNoRemote):
+			data["no_remote"] = true
+		case errors.Is(err, git.ErrNoUpstream):
+			data["no_upstream"] = true
+		}
+	}
+	payload, _ := json.Marshal(data)
+
+	msg := &ws.Message{
+		UserID:     a.cfg.UserID,
+		DeviceType: "desktop",
+		Type:       ws.MessageTypeGitSyncResult,
+		Payload:    payload,
+	}
+
+	if err := a.wsClient.SendMessage(msg); err != nil {
+		log.Printf("Failed to send git sync result: %v", err)
+	}
+}
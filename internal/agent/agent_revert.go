@@ -0,0 +1,257 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/getfinn/finn/internal/config"
+	"github.com/getfinn/finn/internal/git"
+	ws "github.com/getfinn/finn/internal/websocket"
+)
+
+// Commits remembered per folder for undo
+const maxFinnCommits = 50
+
+// How a commit was undone, reported in revert_result
+const (
+	undoRevert = "revert" // A new commit reversing it
+	undoReset  = "reset"  // Soft reset; its changes are left staged
+)
+
+// finnCommitsPath returns where the commits made by approvals are persisted
+func finnCommitsPath() string {
+	return filepath.Join(config.Dir(), "finn_commits.json")
+}
+
+// loadFinnCommits reads the persisted Finn commits. A missing or unreadable
+// file starts an empty record.
+func loadFinnCommits() map[string][]string {
+	commits := make(map[string][]string)
+	data, err := os.ReadFile(finnCommitsPath())
+	if err != nil {
+		return commits
+	}
+	if err := json.Unmarshal(data, &commits); err != nil {
+		log.Printf("⚠️  Failed to parse %s: %v", finnCommitsPath(), err)
+		return make(map[string][]string)
+	}
+	return commits
+}
+
+// saveFinnCommitsLocked persists the Finn commits. Caller holds finnCommitsMu.
+func (a *Agent) saveFinnCommitsLocked() {
+	data, _ := json.MarshalIndent(a.finnCommits, "", "  ")
+	tmp := finnCommitsPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		log.Printf("⚠️  Failed to save Finn commits: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, finnCommitsPath()); err != nil {
+		log.Printf("⚠️  Failed to save Finn commits: %v", err)
+	}
+}
+
+// recordFinnCommit remembers HEAD of a folder as a commit made by Finn
+func (a *Agent) recordFinnCommit(folderID string, repo *git.Repository) {
+	head, err := repo.GetHeadHash()
+	if err != nil {
+		return
+	}
+
+	a.finnCommitsMu.Lock()
+	defer a.finnCommitsMu.Unlock()
+
+	commits := append(a.finnCommits[folderID], head)
+	if len(commits) > maxFinnCommits {
+		commits = commits[len(commits)-maxFinnCommits:]
+	}
+	a.finnCommits[folderID] = commits
+	a.saveFinnCommitsLocked()
+}
+
+// forgetFinnCommit drops an undone commit from the record
+func (a *Agent) forgetFinnCommit(folderID, hash string) {
+	a.finnCommitsMu.Lock()
+	defer a.finnCommitsMu.Unlock()
+
+	commits := a.finnCommits[folderID]
+	for i, c := range commits {
+		if c == hash {
+			a.finnCommits[folderID] = append(commits[:i:i], commits[i+1:]...)
+			a.saveFinnCommitsLocked()
+			return
+		}
+	}
+}
+
+// lastFinnCommit returns the newest recorded Finn commit still on the
+// current branch of a folder
+func (a *Agent) lastFinnCommit(folderID string, repo *git.Repository) string {
+	a.finnCommitsMu.Lock()
+	commits := append([]string(nil), a.finnCommits[folderID]...)
+	a.finnCommitsMu.Unlock()
+
+	for i := len(commits) - 1; i >= 0; i-- {
+		if repo.IsAncestor(commits[i]) {
+			return commits[i]
+		}
+	}
+	return ""
+}
+
+// handleRevertCommit reverts a commit with a new commit. Conflicts leave the
+// revert in progress and are sent for resolution.
+func (a *Agent) handleRevertCommit(msg *ws.Message) {
+	var payload struct {
+		FolderID   string `json:"folder_id"`
+		CommitHash string `json:"commit_hash"`
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("Failed to parse revert_commit payload: %v", err)
+		return
+	}
+
+	repo, folderPath, ok := a.revertRepository(payload.FolderID, payload.CommitHash)
+	if !ok {
+		return
+	}
+
+	full, err := repo.ResolveCommit(payload.CommitHash)
+	if err != nil {
+		a.sendRevertResult(payload.FolderID, payload.CommitHash, undoRevert, repo, err)
+		return
+	}
+	a.revertCommit(payload.FolderID, folderPath, repo, full)
+}
+
+// handleUndoLastCommit undoes the newest Finn commit on the current branch:
+// an unpushed commit that is still the latest is soft reset (its changes
+// stay staged), anything else is reverted. Published history is never
+// rewritten.
+func (a *Agent) handleUndoLastCommit(msg *ws.Message) {
+	var payload struct {
+		FolderID string `json:"folder_id"`
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("Failed to parse undo_last_commit payload: %v", err)
+		return
+	}
+
+	repo, folderPath, ok := a.revertRepository(payload.FolderID, "")
+	if !ok {
+		return
+	}
+
+	hash := a.lastFinnCommit(payload.FolderID, repo)
+	if hash == "" {
+		a.sendRevertResult(payload.FolderID, "", undoRevert, repo, errors.New("No Finn commit to undo on this branch"))
+		return
+	}
+
+	head, _ := repo.GetHeadHash()
+	pushed, err := repo.IsPushed(hash)
+	if err != nil {
+		a.sendRevertResult(payload.FolderID, hash, undoRevert, repo, err)
+		return
+	}
+
+	if hash == head && !pushed {
+		if err := repo.UndoHeadCommit(hash); err != nil {
+			log.Printf("❌ Failed to undo %s: %v", hash[:7], err)
+			a.sendRevertResult(payload.FolderID, hash, undoReset, repo, err)
+			return
+		}
+		log.Printf("↩️  Undid unpushed commit %s in %s (changes kept staged)", hash[:7], folderPath)
+		a.forgetFinnCommit(payload.FolderID, hash)
+		a.sendRevertResult(payload.FolderID, hash, undoReset, repo, nil)
+		a.sendFolderListUpdate()
+		return
+	}
+
+	a.revertCommit(payload.FolderID, folderPath, repo, hash)
+}
+
+// revertCommit reverts a commit and reports the outcome
+func (a *Agent) revertCommit(folderID, folderPath string, repo *git.Repository, hash string) {
+	conflicts, err := repo.Revert(hash)
+	if err != nil {
+		log.Printf("❌ Failed to revert %s: %v", hash[:7], err)
+		a.sendRevertResult(folderID, hash, undoRevert, repo, err)
+		return
+	}
+
+	if conflicts != nil {
+		// Still a Finn commit until the revert is continued (see applyConflictAction)
+		log.Printf("⚔️  Revert of %s stopped on %d conflicted file(s)", hash[:7], len(conflicts.Files))
+		a.sendRevertResult(folderID, hash, undoRevert, repo, fmt.Errorf("the revert stopped on conflicts in %d file(s)", len(conflicts.Files)))
+		a.sendConflicts(folderID, conflicts, "")
+		return
+	}
+
+	a.forgetFinnCommit(folderID, hash)
+	log.Printf("↩️  Reverted %s in %s", hash[:7], folderPath)
+	a.sendRevertResult(folderID, hash, undoRevert, repo, nil)
+	a.sendFolderListUpdate()
+}
+
+// revertRepository looks up the folder of a revert request, replying with an
+// error if it isn't usable
+func (a *Agent) revertRepository(folderID, hash string) (*git.Repository, string, bool) {
+	folder := a.cfg.GetFolderByID(folderID)
+	if folder == nil {
+		log.Printf("❌ Folder not found: %s", folderID)
+		a.sendRevertResult(folderID, hash, undoRevert, nil, errors.New("Folder not found"))
+		return nil, "", false
+	}
+	if !git.IsGitRepo(folder.Path) {
+		a.sendRevertResult(folderID, hash, undoRevert, nil, errors.New("Not a git repository"))
+		return nil, "", false
+	}
+	if a.taskRunningIn(folder.Path) {
+		a.sendRevertResult(folderID, hash, undoRevert, nil, errors.New("A task is running in this folder"))
+		return nil, "", false
+	}
+	return git.NewRepository(folder.Path), folder.Path, true
+}
+
+// sendRevertResult reports a revert or undo. On success it carries the new
+// HEAD (the revert commit, or the parent after a reset).
+func (a *Agent) sendRevertResult(folderID, hash, method string, repo *git.Repository, err error) {
+	data := map[string]interface{}{
+		"folder_id":   folderID,
+		"commit_hash": hash,
+		"method":      method,
+		"success":     err == nil,
+	}
+	if err != nil {
+		data["error"] = err.Error()
+		var dirty *git.DirtyTreeError
+		if errors.As(err, &dirty) {
+			data["dirty"] = true
+			data["changed_files"] = dirty.Files
+		}
+		if errors.Is(err, git.ErrPublishedHistory) {
+			data["published"] = true
+		}
+	} else if repo != nil {
+		if head, err := repo.GetHeadHash(); err == nil {
+			data["head"] = head
+		}
+	}
+	payload, _ := json.Marshal(data)
+
+	msg := &ws.Message{
+		UserID:     a.cfg.UserID,
+		DeviceType: "desktop",
+		Type:       ws.MessageTypeRevertResult,
+		Payload:    payload,
+	}
+
+	if err := a.wsClient.SendMessage(msg); err != nil {
+		log.Printf("Failed to send revert result: %v", err)
+	}
+}
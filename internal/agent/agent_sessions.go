@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/getfinn/finn/internal/claude"
+	"github.com/getfinn/finn/internal/event"
 	"github.com/getfinn/finn/internal/git"
 	"github.com/getfinn/finn/internal/watcher"
 	ws "github.com/getfinn/finn/internal/websocket"
@@ -187,7 +188,29 @@ func (a *Agent) handleGetSessionMessages(msg *ws.Message) {
 		messages = messages[:payload.Limit]
 	}
 
-	// Convert to serializable format
+	messageData := displaySessionMessages(messages)
+
+	responsePayload, _ := json.Marshal(map[string]interface{}{
+		"session_id":  payload.SessionID,
+		"messages":    messageData,
+		"total_count": len(messages),
+		"offset":      payload.Offset,
+		"has_more":    false,
+	})
+
+	a.wsClient.SendMessage(&ws.Message{
+		UserID:     a.cfg.UserID,
+		DeviceType: "desktop",
+		Type:       "session_messages",
+		Payload:    responsePayload,
+	})
+
+	log.Printf("📤 Sent %d display messages for session %s (from %d raw)", len(messageData), payload.SessionID, len(messages))
+}
+
+// displaySessionMessages converts stored session messages to the display
+// format of session_messages, collapsing runs of tool uses into one line.
+func displaySessionMessages(messages []*claude.StoredMessage) []map[string]interface{} {
 	messageData := make([]map[string]interface{}, 0, len(messages))
 	var pendingTools []string
 	var lastToolTimestamp time.Time
@@ -254,22 +277,7 @@ func (a *Agent) handleGetSessionMessages(msg *ws.Message) {
 
 	flushTools()
 
-	responsePayload, _ := json.Marshal(map[string]interface{}{
-		"session_id":  payload.SessionID,
-		"messages":    messageData,
-		"total_count": len(messages),
-		"offset":      payload.Offset,
-		"has_more":    false,
-	})
-
-	a.wsClient.SendMessage(&ws.Message{
-		UserID:     a.cfg.UserID,
-		DeviceType: "desktop",
-		Type:       "session_messages",
-		Payload:    responsePayload,
-	})
-
-	log.Printf("📤 Sent %d display messages for session %s (from %d raw)", len(messageData), payload.SessionID, len(messages))
+	return messageData
 }
 
 // sendSessionMessagesError sends an error response for session message requests.
@@ -336,26 +344,36 @@ func (a *Agent) handleResumeSession(msg *ws.Message) {
 
 	payload.FolderID = actualFolderID
 
-	onEvent := func(event claude.Event) {
-		a.sendClaudeEvent(payload.ConversationID, event)
+	onEvent := func(ev event.Event) {
+		a.sendClaudeEvent(payload.ConversationID, ev)
+	}
+
+	executor, err := a.newInteractiveExecutor(payload.ConversationID, folderPath, onEvent)
+	if err != nil {
+		log.Printf("❌ Failed to create executor: %v", err)
+		a.sendError(payload.ConversationID, err.Error())
+		return
 	}
 
-	executor := claude.NewInteractiveTaskExecutor(folderPath, onEvent)
+	// Re-link if Claude Code forks the resumed session under a new ID
+	folderID := payload.FolderID
+	executor.SetSessionLinkedHandler(func(sid string) {
+		a.sendSessionLinked(payload.ConversationID, sid, folderID)
+	})
 
-	a.executors[payload.ConversationID] = executor
-	a.conversationStates[payload.ConversationID] = &ConversationState{
+	a.setConversation(payload.ConversationID, executor, &ConversationState{
 		executor:     executor,
 		folderPath:   folderPath,
 		folderID:     payload.FolderID,
 		pendingDiffs: make(map[string]bool),
-	}
+		prompt:       payload.Prompt,
+	})
 
 	go func() {
 		if err := executor.ResumeSession(payload.SessionID, payload.Prompt); err != nil {
 			log.Printf("❌ Failed to resume session: %v", err)
-			a.sendError(payload.ConversationID, err.Error())
-			delete(a.executors, payload.ConversationID)
-			delete(a.conversationStates, payload.ConversationID)
+			a.sendTaskError(payload.ConversationID, err)
+			a.removeConversation(payload.ConversationID)
 			return
 		}
 	}()
@@ -0,0 +1,285 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/getfinn/finn/internal/claude"
+	"github.com/getfinn/finn/internal/config"
+	"github.com/getfinn/finn/internal/event"
+	"github.com/getfinn/finn/internal/usage"
+	ws "github.com/getfinn/finn/internal/websocket"
+)
+
+// Days covered by get_usage_report when the request doesn't say
+const defaultUsageReportDays = 30
+
+// Budget alert levels
+const (
+	budgetSoft = "soft"
+	budgetHard = "hard"
+)
+
+// conversationUsage is what the agent tracks between a conversation's usage
+// events. Guarded by Agent.usageMu.
+type conversationUsage struct {
+	folderID    string                  // Folder of a one-shot run, which has no ConversationState
+	model       string                  // Last model reported by Claude
+	sessionCost float64                 // Running total_cost_usd of the current CLI process
+	inflight    map[string]usage.Totals // Estimated usage of the current turn, by API message ID
+	softAlerted bool                    // Soft budget alert already sent
+	hardTripped bool                    // Current turn already aborted for the hard budget
+}
+
+// usageLedgerPath returns where the usage ledger is persisted
+func usageLedgerPath() string {
+	return filepath.Join(config.Dir(), "usage_ledger.json")
+}
+
+// trackUsage records usage, complete and error events in the ledger and
+// enforces the folder's budget. Per-message usage is priced from tokens until
+// the turn's result reports the actual cost; a turn that never gets a result
+// (stopped or failed) is recorded at its estimate.
+func (a *Agent) trackUsage(conversationID string, ev event.Event) {
+	folderID := ""
+	if state := a.conversationState(conversationID); state != nil {
+		folderID = state.folderID
+	}
+
+	a.usageMu.Lock()
+	cu := a.usageOf(conversationID)
+	if folderID == "" {
+		folderID = cu.folderID
+	}
+
+	switch ev.Type {
+	case event.TypeUsage:
+		var u event.Usage
+		if err := ev.Decode(&u); err != nil {
+			a.usageMu.Unlock()
+			return
+		}
+		if u.Model != "" {
+			cu.model = u.Model
+		}
+
+		totals := usage.Totals{
+			InputTokens:              int64(u.InputTokens),
+			OutputTokens:             int64(u.OutputTokens),
+			CacheReadInputTokens:     int64(u.CacheReadInputTokens),
+			CacheCreationInputTokens: int64(u.CacheCreationInputTokens),
+		}
+
+		if u.IsFinal {
+			// A result reports what the CLI process has cost so far, so the
+			// turn cost is the increase since the previous result
+			totals.CostUSD = u.CostUSD - cu.sessionCost
+			if totals.CostUSD < 0 {
+				totals.CostUSD = u.CostUSD // A new process whose start wasn't seen (e.g. replay)
+			}
+			cu.sessionCost = u.CostUSD
+			totals.DurationMs = u.DurationMs
+			totals.Turns = 1
+			a.recordUsage(conversationID, folderID, cu.model, totals)
+			cu.inflight = make(map[string]usage.Totals)
+			cu.hardTripped = false
+		} else {
+			// Each content block of a message repeats its usage, so key by message ID
+			key := u.MessageID
+			if key == "" {
+				key = strconv.Itoa(len(cu.inflight))
+			}
+			totals.CostUSD = usage.EstimateCost(cu.model, u.InputTokens, u.OutputTokens, u.CacheReadInputTokens, u.CacheCreationInputTokens)
+			totals.EstimatedCostUSD = totals.CostUSD
+			cu.inflight[key] = totals
+		}
+
+	case event.TypeComplete, event.TypeError:
+		a.flushInflightUsage(conversationID, folderID, cu)
+		if ev.Type == event.TypeComplete {
+			// An aborted task still drains output until it exits; re-arm only after that
+			cu.hardTripped = false
+		}
+		a.usageMu.Unlock()
+		return
+
+	default:
+		a.usageMu.Unlock()
+		return
+	}
+
+	// Enforce the folder's budget against everything this conversation has cost
+	budget := a.cfg.BudgetForFolder(folderID)
+	cost := a.usageLedger.ConversationCost(conversationID)
+	for _, totals := range cu.inflight {
+		cost += totals.CostUSD
+	}
+
+	var alert string
+	if budget.HardUSD > 0 && cost >= budget.HardUSD && !cu.hardTripped {
+		cu.hardTripped = true
+		alert = budgetHard
+	} else if budget.SoftUSD > 0 && cost >= budget.SoftUSD && !cu.softAlerted {
+		cu.softAlerted = true
+		alert = budgetSoft
+	}
+	a.usageMu.Unlock()
+
+	switch alert {
+	case budgetHard:
+		log.Printf("💸 %s crossed its hard budget ($%.2f of $%.2f) - stopping task", conversationID, cost, budget.HardUSD)
+		if executor, ok := a.executor(conversationID).(abortable); ok {
+			// Off the event path: aborting waits for the process to exit
+			go executor.Abort(claude.TimeoutBudget, fmt.Sprintf("$%.2f", budget.HardUSD))
+		}
+		a.sendBudgetAlert(conversationID, folderID, budgetHard, budget.HardUSD, cost)
+	case budgetSoft:
+		log.Printf("💸 %s crossed its soft budget ($%.2f of $%.2f)", conversationID, cost, budget.SoftUSD)
+		a.sendBudgetAlert(conversationID, folderID, budgetSoft, budget.SoftUSD, cost)
+	}
+}
+
+// abortable is an executor the hard budget can stop mid-task
+type abortable interface {
+	Abort(reason claude.TimeoutReason, limit string)
+}
+
+// usageOf returns the usage tracked for a conversation, creating it on first
+// use. Caller holds usageMu.
+func (a *Agent) usageOf(conversationID string) *conversationUsage {
+	cu := a.conversationUsage[conversationID]
+	if cu == nil {
+		cu = &conversationUsage{inflight: make(map[string]usage.Totals)}
+		a.conversationUsage[conversationID] = cu
+	}
+	return cu
+}
+
+// setUsageFolder attributes the usage of a conversation without a
+// ConversationState (a one-shot run) to its folder, for the ledger and budget
+func (a *Agent) setUsageFolder(conversationID, folderID string) {
+	a.usageMu.Lock()
+	defer a.usageMu.Unlock()
+	a.usageOf(conversationID).folderID = folderID
+}
+
+// hardBudgetReached reports whether a conversation has already cost its
+// folder's hard budget, with the budget and the cost
+func (a *Agent) hardBudgetReached(conversationID, folderID string) (bool, float64, float64) {
+	budget := a.cfg.BudgetForFolder(folderID)
+	if budget.HardUSD <= 0 {
+		return false, 0, 0
+	}
+	cost := a.usageLedger.ConversationCost(conversationID)
+	return cost >= budget.HardUSD, budget.HardUSD, cost
+}
+
+// resetSessionCost starts the conversation's cost baseline over for a new
+// CLI process, whose results count from zero
+func (a *Agent) resetSessionCost(conversationID string) {
+	a.usageMu.Lock()
+	defer a.usageMu.Unlock()
+	if cu := a.conversationUsage[conversationID]; cu != nil {
+		cu.sessionCost = 0
+	}
+}
+
+// flushInflightUsage records a turn that ended without a result at its
+// estimated cost. Caller holds usageMu.
+func (a *Agent) flushInflightUsage(conversationID, folderID string, cu *conversationUsage) {
+	if len(cu.inflight) == 0 {
+		return
+	}
+
+	var totals usage.Totals
+	for _, t := range cu.inflight {
+		totals.Add(t)
+	}
+	totals.Turns = 1
+	a.recordUsage(conversationID, folderID, cu.model, totals)
+	cu.inflight = make(map[string]usage.Totals)
+}
+
+// recordUsage adds usage to today's ledger record for the conversation
+func (a *Agent) recordUsage(conversationID, folderID, model string, totals usage.Totals) {
+	key := usage.Key{
+		Day:            time.Now().Format(usage.DayFormat),
+		FolderID:       folderID,
+		ConversationID: conversationID,
+		Model:          model,
+	}
+	if err := a.usageLedger.Add(key, totals); err != nil {
+		log.Printf("⚠️  Failed to record usage: %v", err)
+	}
+}
+
+// sendBudgetAlert notifies mobile that a conversation crossed a budget.
+func (a *Agent) sendBudgetAlert(conversationID, folderID, level string, budgetUSD, costUSD float64) {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"conversation_id": conversationID,
+		"folder_id":       folderID,
+		"level":           level,
+		"budget_usd":      budgetUSD,
+		"cost_usd":        costUSD,
+	})
+
+	msg := &ws.Message{
+		UserID:     a.cfg.UserID,
+		DeviceType: "desktop",
+		Type:       ws.MessageTypeBudgetAlert,
+		Payload:    payload,
+	}
+
+	if err := a.wsClient.SendMessage(msg); err != nil {
+		log.Printf("Failed to send budget alert: %v", err)
+	}
+}
+
+// handleGetUsageReport sends daily, weekly and per-folder usage rollups.
+func (a *Agent) handleGetUsageReport(msg *ws.Message) {
+	var payload struct {
+		Days     int    `json:"days"`
+		FolderID string `json:"folder_id"`
+	}
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			log.Printf("Failed to parse get_usage_report payload: %v", err)
+			return
+		}
+	}
+	if payload.Days <= 0 {
+		payload.Days = defaultUsageReportDays
+	}
+
+	report := a.usageLedger.Report(time.Now(), payload.Days, payload.FolderID)
+
+	// Budgets alongside the rollups, so mobile can show spend against them
+	budgets := make(map[string]config.Budget)
+	for _, folder := range a.cfg.ApprovedFolders {
+		if folder.Budget != nil {
+			budgets[folder.ID] = *folder.Budget
+		}
+	}
+
+	response, _ := json.Marshal(map[string]interface{}{
+		"folder_id": payload.FolderID,
+		"days":      payload.Days,
+		"report":    report,
+		"budgets":   budgets,
+	})
+
+	reply := &ws.Message{
+		UserID:     a.cfg.UserID,
+		DeviceType: "desktop",
+		Type:       ws.MessageTypeUsageReport,
+		Payload:    response,
+	}
+
+	if err := a.wsClient.SendMessage(reply); err != nil {
+		log.Printf("Failed to send usage report: %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package claude
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Attachment limits. Images go to the API inline, which caps them at 5 MB.
+const (
+	MaxAttachments      = 10
+	maxImageBytes       = 5 * 1024 * 1024
+	maxPDFBytes         = 10 * 1024 * 1024
+	maxTextBytes        = 1 * 1024 * 1024
+	maxAttachmentsBytes = 10 * 1024 * 1024 // All attachments of one message
+
+	// Attachment directories older than this are removed
+	attachmentRetention = 24 * time.Hour
+)
+
+// Attachment is a file sent with a prompt, base64 encoded
+type Attachment struct {
+	Name     string `json:"name"`
+	MIMEType string `json:"mime_type"`
+	Data     string `json:"data"`
+}
+
+// imageTypes are the image formats Claude accepts as image content blocks
+var imageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// textTypes are the non-text/* types accepted as text files
+var textTypes = map[string]bool{
+	"application/json":   true,
+	"application/xml":    true,
+	"application/yaml":   true,
+	"application/x-yaml": true,
+}
+
+// unsafeNameChars are replaced in attachment file names
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// imageAttachment is a validated image, sent inline
+type imageAttachment struct {
+	name     string
+	mimeType string
+	data     string // base64, as received
+}
+
+// Attachments are validated attachments ready to go with a message: images
+// as content blocks, everything else as files in a temporary directory
+type Attachments struct {
+	images []imageAttachment
+	Dir    string   // Where files were written ("" if none)
+	Files  []string // Paths of the written files
+}
+
+// PrepareAttachments validates attachments and writes the non-image ones to
+// a new temporary directory. Images are also written there when inlineImages
+// is false (for executors that can only pass Claude a text prompt).
+func PrepareAttachments(attachments []Attachment, inlineImages bool) (*Attachments, error) {
+	if len(attachments) == 0 {
+		return nil, nil
+	}
+	if len(attachments) > MaxAttachments {
+		return nil, fmt.Errorf("too many attachments (%d, max %d)", len(attachments), MaxAttachments)
+	}
+
+	sweepAttachmentDirs()
+
+	prepared := &Attachments{}
+	fail := func(err error) (*Attachments, error) {
+		prepared.Cleanup()
+		return nil, err
+	}
+
+	total := 0
+	for i, attachment := range attachments {
+		data, err := base64.StdEncoding.DecodeString(attachment.Data)
+		if err != nil {
+			return fail(fmt.Errorf("attachment %q is not valid base64", attachment.Name))
+		}
+		total += len(data)
+		if total > maxAttachmentsBytes {
+			return fail(fmt.Errorf("attachments exceed %d MB in total", maxAttachmentsBytes/(1024*1024)))
+		}
+
+		mimeType, err := validateAttachment(attachment, data)
+		if err != nil {
+			return fail(err)
+		}
+
+		if imageTypes[mimeType] && inlineImages {
+			prepared.images = append(prepared.images, imageAttachment{
+				name:     attachment.Name,
+				mimeType: mimeType,
+				data:     attachment.Data,
+			})
+			continue
+		}
+
+		if prepared.Dir == "" {
+			dir, err := os.MkdirTemp(attachmentsRoot(), "attachments-")
+			if err != nil {
+				return fail(fmt.Errorf("failed to store attachments: %w", err))
+			}
+			prepared.Dir = dir
+		}
+		path := filepath.Join(prepared.Dir, attachmentFileName(attachment.Name, i))
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return fail(fmt.Errorf("failed to store attachment %q: %w", attachment.Name, err))
+		}
+		prepared.Files = append(prepared.Files, path)
+	}
+
+	log.Printf("📎 Prepared %d attachment(s): %d image(s), %d file(s)", len(attachments), len(prepared.images), len(prepared.Files))
+	return prepared, nil
+}
+
+// validateAttachment checks the declared MIME type is allowed, matches the
+// content and is within its size limit. Returns the normalized MIME type.
+func validateAttachment(attachment Attachment, data []byte) (string, error) {
+	mimeType := strings.ToLower(strings.TrimSpace(strings.Split(attachment.MIMEType, ";")[0]))
+	sniffed := http.DetectContentType(data)
+
+	switch {
+	case imageTypes[mimeType]:
+		if sniffed != mimeType {
+			return "", fmt.Errorf("attachment %q is not a valid %s", attachment.Name, mimeType)
+		}
+		if len(data) > maxImageBytes {
+			return "", fmt.Errorf("image %q is larger than %d MB", attachment.Name, maxImageBytes/(1024*1024))
+		}
+
+	case mimeType == "application/pdf":
+		if sniffed != mimeType {
+			return "", fmt.Errorf("attachment %q is not a valid PDF", attachment.Name)
+		}
+		if len(data) > maxPDFBytes {
+			return "", fmt.Errorf("PDF %q is larger than %d MB", attachment.Name, maxPDFBytes/(1024*1024))
+		}
+
+	case strings.HasPrefix(mimeType, "text/") || textTypes[mimeType]:
+		if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+			return "", fmt.Errorf("attachment %q is not a text file", attachment.Name)
+		}
+		if len(data) > maxTextBytes {
+			return "", fmt.Errorf("text file %q is larger than %d MB", attachment.Name, maxTextBytes/(1024*1024))
+		}
+
+	default:
+		return "", fmt.Errorf("attachment %q has unsupported type %q (images, PDFs and text files only)", attachment.Name, attachment.MIMEType)
+	}
+
+	return mimeType, nil
+}
+
+// attachmentFileName makes a safe, unique file name for attachment i
+func attachmentFileName(name string, i int) string {
+	name = unsafeNameChars.ReplaceAllString(filepath.Base(name), "_")
+	name = strings.Trim(name, "._")
+	if name == "" {
+		name = "attachment"
+	}
+	return fmt.Sprintf("%d-%s", i+1, name)
+}
+
+// attachmentsRoot is the directory holding all attachment directories
+func attachmentsRoot() string {
+	root := filepath.Join(os.TempDir(), "finn-attachments")
+	os.MkdirAll(root, 0700)
+	return root
+}
+
+// sweepAttachmentDirs removes attachment directories past their retention
+func sweepAttachmentDirs() {
+	root := attachmentsRoot()
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-attachmentRetention)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err == nil && entry.IsDir() && info.ModTime().Before(cutoff) {
+			os.RemoveAll(filepath.Join(root, entry.Name()))
+		}
+	}
+}
+
+// PromptNote tells Claude where the attached files are. Empty without files.
+func (a *Attachments) PromptNote() string {
+	if a == nil || len(a.Files) == 0 {
+		return ""
+	}
+
+	var note strings.Builder
+	note.WriteString("\n\nThe user attached these files. They are outside the project folder, but you may read them:\n")
+	for _, path := range a.Files {
+		note.WriteString("- " + path + "\n")
+	}
+	return note.String()
+}
+
+// contentBlocks builds the stream-json content of a user message: the images
+// followed by the text (with the file note)
+func (a *Attachments) contentBlocks(text string) []map[string]interface{} {
+	blocks := make([]map[string]interface{}, 0, len(a.images)+1)
+	for _, img := range a.images {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "image",
+			"source": map[string]interface{}{
+				"type":       "base64",
+				"media_type": img.mimeType,
+				"data":       img.data,
+			},
+		})
+	}
+	blocks = append(blocks, map[string]interface{}{
+		"type": "text",
+		"text": text + a.PromptNote(),
+	})
+	return blocks
+}
+
+// Cleanup removes the attachment files
+func (a *Attachments) Cleanup() {
+	if a != nil && a.Dir != "" {
+		os.RemoveAll(a.Dir)
+	}
+}
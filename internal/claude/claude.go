@@ -6,11 +6,19 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"sync"
+
+	"github.com/getfinn/finn/internal/event"
 )
 
 // Executor handles Claude Code CLI execution
 type Executor struct {
 	projectPath string
+	limits      Limits
+	hooks       ProcessHooks
+
+	mu  sync.Mutex
+	dog *watchdog // Watchdog of the running Execute call, nil between calls
 }
 
 // NewExecutor creates a new Claude Code executor
@@ -20,23 +28,48 @@ func NewExecutor(projectPath string) *Executor {
 	}
 }
 
+// SetLimits sets the watchdog limits applied to each Execute call
+func (e *Executor) SetLimits(limits Limits) {
+	e.limits = limits
+}
+
+// SetProcessHooks sets the callbacks run around the spawned CLI process
+func (e *Executor) SetProcessHooks(hooks ProcessHooks) {
+	e.hooks = hooks
+}
+
+// Abort stops the running Execute call as if it had crossed a watchdog
+// limit; Execute then returns the timeout error. No-op between calls.
+func (e *Executor) Abort(reason TimeoutReason, limit string) {
+	e.mu.Lock()
+	dog := e.dog
+	e.mu.Unlock()
+
+	if dog != nil {
+		dog.trip(reason, limit)
+	}
+}
+
 // StreamMessage represents a message from Claude's streaming output
 type StreamMessage struct {
-	Type    string `json:"type"`
-	Subtype string `json:"subtype,omitempty"`
-	Message struct {
-		Content []struct {
-			Type    string          `json:"type"`
-			Text    string          `json:"text,omitempty"`
-			Name    string          `json:"name,omitempty"`
-			Input   json.RawMessage `json:"input,omitempty"`
-			ID      string          `json:"id,omitempty"`
-		} `json:"content"`
-		StopReason string     `json:"stop_reason,omitempty"`
-		Model      string     `json:"model,omitempty"`
-		Usage      *UsageInfo `json:"usage,omitempty"`
+	Type      string `json:"type"`
+	Subtype   string `json:"subtype,omitempty"`
+	SessionID string `json:"session_id,omitempty"` // Present on system/init, result and most other messages
+	Message   struct {
+		ID         string                `json:"id,omitempty"` // API message ID, repeated on each block of the message
+		Content    []MessageContentBlock `json:"content"`
+		StopReason string                `json:"stop_reason,omitempty"`
+		Model      string                `json:"model,omitempty"`
+		Usage      *UsageInfo            `json:"usage,omitempty"`
 	} `json:"message,omitempty"`
-	Result string `json:"result,omitempty"`
+	Result  string `json:"result,omitempty"`
+	IsError bool   `json:"is_error,omitempty"` // Set on failed "result" messages
+
+	// Raw API event for "stream_event" messages (--include-partial-messages)
+	Event json.RawMessage `json:"event,omitempty"`
+
+	// Set on messages produced inside a sub-agent (the Task tool_use ID that spawned it)
+	ParentToolUseID string `json:"parent_tool_use_id,omitempty"`
 
 	// Top-level fields for "result" messages
 	TopLevelUsage *UsageInfo `json:"usage,omitempty"`          // Final aggregated usage (result messages)
@@ -81,19 +114,58 @@ User request: `, e.projectPath)
 		return fmt.Errorf("failed to get stderr pipe: %w", err)
 	}
 
-	// Start command
+	setProcessGroup(cmd) // Lets the watchdog stop the CLI together with the tools it runs
+
+	// Start command, if the governor has room for it
+	if err := e.hooks.acquire(); err != nil {
+		return err
+	}
+	defer e.hooks.release()
 	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start claude: %w", err)
+		return ClassifyExit(err, "")
 	}
+	e.hooks.started(cmd.Process.Pid)
+
+	// Stop the task if it crosses one of its limits
+	done := make(chan struct{})
+	defer close(done)
+	var expired *event.Timeout
+	var expiredMu sync.Mutex
+	dog := &watchdog{limits: e.limits}
+	dog.onExpire = func(stats event.Timeout) {
+		expiredMu.Lock()
+		expired = &stats
+		expiredMu.Unlock()
+		terminateProcess(cmd, done)
+	}
+	dog.arm()
+	defer dog.disarm()
+	e.mu.Lock()
+	e.dog = dog
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.dog = nil
+		e.mu.Unlock()
+	}()
+
+	// Both pipes must be drained before Wait
+	var wg sync.WaitGroup
+	wg.Add(2)
 
 	// Stream stdout (Claude's output)
 	go func() {
+		defer wg.Done()
 		scanner := bufio.NewScanner(stdout)
 		for scanner.Scan() {
 			line := scanner.Text()
+			dog.touch()
 
 			var msg StreamMessage
 			if err := json.Unmarshal([]byte(line), &msg); err == nil {
+				if msg.Type == "assistant" {
+					dog.countTurn()
+				}
 				// Call handler for each message
 				if handler != nil {
 					handler(msg)
@@ -102,28 +174,35 @@ User request: `, e.projectPath)
 		}
 	}()
 
-	// Stream stderr (errors)
+	// Stream stderr (errors), keeping the tail for failure classification
+	var stderrOutput stderrTail
 	go func() {
+		defer wg.Done()
 		scanner := bufio.NewScanner(stderr)
 		for scanner.Scan() {
 			// Log errors
 			fmt.Printf("Claude stderr: %s\n", scanner.Text())
+			stderrOutput.add(scanner.Text())
 		}
 	}()
 
 	// Wait for completion
-	if err := cmd.Wait(); err != nil {
-		return fmt.Errorf("claude code failed: %w", err)
+	wg.Wait()
+	err = cmd.Wait()
+	dog.disarm()
+
+	expiredMu.Lock()
+	stats := expired
+	expiredMu.Unlock()
+	if stats != nil {
+		return timeoutError(*stats)
+	}
+	if err != nil {
+		return ClassifyExit(err, stderrOutput.String())
 	}
 
 	return nil
 }
 
-// IsInstalled checks if Claude Code CLI is installed
-func IsInstalled() bool {
-	_, err := exec.LookPath("claude")
-	return err == nil
-}
-
 // Note: Claude Code CLI authentication is handled by the user's subscription.
 // No API key setup is required - the daemon uses the existing authenticated session.
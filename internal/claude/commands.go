@@ -0,0 +1,142 @@
+package claude
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CommandSource says where a slash command comes from
+type CommandSource string
+
+const (
+	CommandBuiltin CommandSource = "builtin" // Part of Claude Code
+	CommandProject CommandSource = "project" // <folder>/.claude/commands/*.md
+	CommandUser    CommandSource = "user"    // ~/.claude/commands/*.md
+)
+
+// Command is a slash command that can be run in a conversation
+type Command struct {
+	Name         string        `json:"name"` // Without the leading "/"
+	Description  string        `json:"description,omitempty"`
+	ArgumentHint string        `json:"argument_hint,omitempty"` // e.g. "[pr-number]"
+	Source       CommandSource `json:"source"`
+	Namespace    string        `json:"namespace,omitempty"` // Subdirectory of a custom command, e.g. "frontend"
+}
+
+// builtinCommands are the Claude Code commands that work without a terminal
+var builtinCommands = []Command{
+	{Name: "compact", Description: "Summarize the conversation to free up context", ArgumentHint: "[instructions]"},
+	{Name: "clear", Description: "Clear the conversation history"},
+	{Name: "review", Description: "Review a pull request", ArgumentHint: "[pr-number]"},
+	{Name: "security-review", Description: "Security review of the pending changes on the current branch"},
+	{Name: "pr-comments", Description: "Get the comments of a GitHub pull request", ArgumentHint: "[pr-number]"},
+	{Name: "init", Description: "Create a CLAUDE.md with documentation of the codebase"},
+}
+
+// DiscoverCommands lists the built-in commands followed by the custom
+// commands of the project and of the user, each sorted by name.
+func DiscoverCommands(projectPath string) []Command {
+	commands := make([]Command, 0, len(builtinCommands))
+	for _, cmd := range builtinCommands {
+		cmd.Source = CommandBuiltin
+		commands = append(commands, cmd)
+	}
+
+	commands = append(commands, customCommands(filepath.Join(projectPath, ".claude", "commands"), CommandProject)...)
+	if configDir, err := claudeConfigDir(); err == nil {
+		commands = append(commands, customCommands(filepath.Join(configDir, "commands"), CommandUser)...)
+	}
+
+	return commands
+}
+
+// FindCommand returns the command called name (with or without the leading "/")
+func FindCommand(projectPath, name string) (Command, bool) {
+	name = strings.TrimPrefix(name, "/")
+	for _, cmd := range DiscoverCommands(projectPath) {
+		if cmd.Name == name {
+			return cmd, true
+		}
+	}
+	return Command{}, false
+}
+
+// customCommands reads the markdown commands under dir. Subdirectories only
+// namespace a command; its name is the file name.
+func customCommands(dir string, source CommandSource) []Command {
+	var commands []Command
+
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(d.Name(), ".md") {
+			return nil
+		}
+
+		cmd := Command{
+			Name:   strings.TrimSuffix(d.Name(), ".md"),
+			Source: source,
+		}
+		if rel, err := filepath.Rel(dir, filepath.Dir(path)); err == nil && rel != "." {
+			cmd.Namespace = strings.ReplaceAll(filepath.ToSlash(rel), "/", ":")
+		}
+		cmd.Description, cmd.ArgumentHint = readCommandFile(path)
+
+		commands = append(commands, cmd)
+		return nil
+	})
+
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+	return commands
+}
+
+// readCommandFile returns the description and argument hint from a command's
+// frontmatter. Without a description, the first line of the body is used.
+func readCommandFile(path string) (description, argumentHint string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	inFrontmatter := false
+	for lineNo := 0; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if lineNo == 0 && line == "---" {
+			inFrontmatter = true
+			continue
+		}
+		if inFrontmatter {
+			if line == "---" {
+				inFrontmatter = false
+				continue
+			}
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			value = strings.Trim(strings.TrimSpace(value), `"'`)
+			switch strings.TrimSpace(key) {
+			case "description":
+				description = value
+			case "argument-hint":
+				argumentHint = value
+			}
+			continue
+		}
+
+		if line == "" {
+			continue
+		}
+		if description == "" {
+			description = strings.TrimLeft(line, "# ")
+		}
+		break
+	}
+
+	return description, argumentHint
+}
@@ -36,11 +36,15 @@
 //
 // # Event Streaming
 //
-// Both executors emit events via callbacks as Claude processes the request:
+// Both executors emit events from package event via callbacks as Claude
+// processes the request:
 //   - Thinking: Claude's reasoning process
 //   - ToolUse: File operations, searches, etc.
+//   - ToolResult: Output returned by a tool
 //   - Decision: AskUserQuestion tool calls requiring user input
 //   - Diff: File modification diffs for review
+//   - Usage: Token usage and cost
+//   - Progress: Structured task progress
 //   - Complete: Task completion
 //   - Error: Error conditions
 package claude
@@ -0,0 +1,308 @@
+package claude
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getfinn/finn/internal/event"
+)
+
+// ErrorCode classifies why the Claude CLI failed
+type ErrorCode string
+
+const (
+	ErrorNotInstalled  ErrorCode = "not_installed"           // claude binary not on PATH
+	ErrorNotLoggedIn   ErrorCode = "not_logged_in"           // No credentials, or they expired
+	ErrorUsageLimit    ErrorCode = "usage_limit"             // Subscription usage limit hit, resets at a set time
+	ErrorRateLimited   ErrorCode = "rate_limited"            // API overloaded or rate limiting; retry shortly
+	ErrorContextWindow ErrorCode = "context_window_exceeded" // Conversation no longer fits in the model's context
+	ErrorMaxTurns      ErrorCode = "max_turns"               // Stopped after the maximum number of turns
+	ErrorNetwork       ErrorCode = "network"                 // Couldn't reach the API
+	ErrorCrash         ErrorCode = "crash"                   // Process exited abnormally for another reason
+	ErrorTimeout       ErrorCode = "timeout"                 // Stopped by the watchdog (see Limits)
+	ErrorBusy          ErrorCode = "busy"                    // Too many CLI processes running (see ProcessHooks)
+	ErrorUnknown       ErrorCode = "unknown"                 // Reported as an error, cause not recognised
+)
+
+// CLIError is a classified failure of the Claude CLI
+type CLIError struct {
+	Code     ErrorCode
+	Message  string    // Human-readable summary
+	Action   string    // Suggested remediation
+	ResetsAt time.Time // When a usage limit resets (zero if unknown)
+	Detail   string    // Raw result text / stderr tail the classification was based on
+	Err      error     // Underlying error, if any
+
+	Timeout *event.Timeout // Set for ErrorTimeout
+}
+
+func (e *CLIError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *CLIError) Unwrap() error {
+	return e.Err
+}
+
+// Event converts the failure into a typed error event
+func (e *CLIError) Event() event.Event {
+	payload := event.Error{
+		Message: e.Message,
+		Code:    string(e.Code),
+		Action:  e.Action,
+		Detail:  e.Detail,
+		Timeout: e.Timeout,
+	}
+	if !e.ResetsAt.IsZero() {
+		resetsAt := e.ResetsAt
+		payload.ResetsAt = &resetsAt
+	}
+	return event.New(event.TypeError, payload)
+}
+
+// errorClass is a recognised failure: any of the patterns (lowercase) in the
+// result text or stderr selects it
+type errorClass struct {
+	code     ErrorCode
+	patterns []string
+	message  string
+	action   string
+}
+
+// errorClasses are checked in order; the first match wins
+var errorClasses = []errorClass{
+	{
+		code: ErrorNotLoggedIn,
+		patterns: []string{
+			"invalid api key", "please run /login", "not logged in", "oauth token has expired",
+			"token expired", "authentication_error", "invalid x-api-key",
+		},
+		message: "Claude Code is not logged in or its credentials have expired",
+		action:  "Run `claude` on this computer and complete /login, then retry",
+	},
+	{
+		code: ErrorUsageLimit,
+		patterns: []string{
+			// "Claude AI usage limit reached|<epoch>", "5-hour limit reached ∙ resets 3pm"
+			"usage limit reached", "hour limit reached", "weekly limit reached",
+		},
+		message: "Claude usage limit reached",
+		action:  "Wait for the limit to reset, then continue the conversation",
+	},
+	{
+		code: ErrorRateLimited,
+		patterns: []string{
+			"rate_limit_error", "rate limit", "too many requests", "overloaded_error", "overloaded",
+		},
+		message: "The Claude API is overloaded or rate limiting requests",
+		action:  "The task retries automatically in a few minutes, or continue the conversation to retry now",
+	},
+	{
+		code: ErrorContextWindow,
+		patterns: []string{
+			"prompt is too long", "context window", "context length", "maximum context", "input is too long",
+		},
+		message: "The conversation is too long for Claude's context window",
+		action:  "Start over with a shorter prompt, or run /compact in this session",
+	},
+	{
+		code: ErrorNetwork,
+		patterns: []string{
+			"connection error", "econnrefused", "econnreset", "enotfound", "etimedout", "getaddrinfo",
+			"socket hang up", "fetch failed", "request timed out", "network error", "unable to connect",
+		},
+		message: "Couldn't reach the Claude API",
+		action:  "Check this computer's internet connection, then retry",
+	},
+}
+
+var (
+	// "Claude AI usage limit reached|1735689600"
+	resetEpochPattern = regexp.MustCompile(`limit reached\|(\d{9,})`)
+	// "5-hour limit reached ∙ resets 3pm" / "resets 10:30am (Europe/Berlin)"
+	resetClockPattern = regexp.MustCompile(`(?i)resets\s+(?:at\s+)?(\d{1,2})(?::(\d{2}))?\s*([ap]m)(?:\s*\(([^)]+)\))?`)
+)
+
+// ClassifyResult classifies a "result" stream message. Returns nil if the
+// result is a success.
+func ClassifyResult(msg StreamMessage, stderr string) *CLIError {
+	if !msg.IsError && (msg.Subtype == "" || msg.Subtype == "success") {
+		return nil
+	}
+
+	if msg.Subtype == "error_max_turns" {
+		return &CLIError{
+			Code:    ErrorMaxTurns,
+			Message: "Claude stopped after reaching the maximum number of turns",
+			Action:  "Continue the conversation to let Claude keep going",
+			Detail:  msg.Result,
+		}
+	}
+
+	if cliErr := classifyText(msg.Result + "\n" + stderr); cliErr != nil {
+		cliErr.Detail = detailText(msg.Result, stderr)
+		return cliErr
+	}
+
+	message := strings.TrimSpace(msg.Result)
+	if message == "" {
+		message = fmt.Sprintf("Claude reported an error (%s)", msg.Subtype)
+	}
+	return &CLIError{
+		Code:    ErrorUnknown,
+		Message: message,
+		Action:  "Retry, or check the daemon log for details",
+		Detail:  detailText(msg.Result, stderr),
+	}
+}
+
+// ClassifyExit classifies a failure to start or an abnormal exit of the CLI
+// process. Returns nil if err is nil.
+func ClassifyExit(err error, stderr string) *CLIError {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, exec.ErrNotFound) {
+		return &CLIError{
+			Code:    ErrorNotInstalled,
+			Message: "Claude Code CLI not installed",
+			Action:  "Install it with: npm install -g @anthropic-ai/claude-code",
+			Err:     err,
+		}
+	}
+
+	if cliErr := classifyText(stderr); cliErr != nil {
+		cliErr.Detail = detailText("", stderr)
+		cliErr.Err = err
+		return cliErr
+	}
+
+	return &CLIError{
+		Code:    ErrorCrash,
+		Message: "Claude Code exited unexpectedly",
+		Action:  "Retry; if it keeps happening, run `claude` in a terminal to see the error",
+		Detail:  detailText("", stderr),
+		Err:     err,
+	}
+}
+
+// classifyText matches text against the known failure classes
+func classifyText(text string) *CLIError {
+	lower := strings.ToLower(text)
+	for _, class := range errorClasses {
+		for _, pattern := range class.patterns {
+			if strings.Contains(lower, pattern) {
+				cliErr := &CLIError{
+					Code:    class.code,
+					Message: class.message,
+					Action:  class.action,
+				}
+				if class.code == ErrorUsageLimit {
+					cliErr.ResetsAt = parseResetTime(text, time.Now())
+				}
+				return cliErr
+			}
+		}
+	}
+	return nil
+}
+
+// parseResetTime extracts when a usage limit resets, or zero if not stated
+func parseResetTime(text string, now time.Time) time.Time {
+	if m := resetEpochPattern.FindStringSubmatch(text); m != nil {
+		if epoch, err := strconv.ParseInt(m[1], 10, 64); err == nil {
+			return time.Unix(epoch, 0)
+		}
+	}
+
+	m := resetClockPattern.FindStringSubmatch(text)
+	if m == nil {
+		return time.Time{}
+	}
+
+	hour, _ := strconv.Atoi(m[1])
+	minute := 0
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	if hour < 1 || hour > 12 || minute > 59 {
+		return time.Time{}
+	}
+	hour %= 12
+	if strings.EqualFold(m[3], "pm") {
+		hour += 12
+	}
+
+	loc := now.Location()
+	if m[4] != "" {
+		if tz, err := time.LoadLocation(m[4]); err == nil {
+			loc = tz
+		}
+	}
+
+	// The CLI reports a clock time; it refers to the next time that clock is reached
+	local := now.In(loc)
+	reset := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, loc)
+	if !reset.After(local) {
+		reset = reset.AddDate(0, 0, 1)
+	}
+	return reset
+}
+
+// detailText joins the result text and stderr for display
+func detailText(result, stderr string) string {
+	result = strings.TrimSpace(result)
+	stderr = strings.TrimSpace(stderr)
+	switch {
+	case result == "":
+		return stderr
+	case stderr == "":
+		return result
+	default:
+		return result + "\n\n" + stderr
+	}
+}
+
+// stderrTail keeps the last few KB written to the CLI's stderr for classification
+type stderrTail struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+const maxStderrTail = 8 * 1024
+
+// add appends a line, dropping the oldest output beyond maxStderrTail
+func (t *stderrTail) add(line string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.buf = append(t.buf, line...)
+	t.buf = append(t.buf, '\n')
+	if len(t.buf) > maxStderrTail {
+		t.buf = append([]byte(nil), t.buf[len(t.buf)-maxStderrTail:]...)
+	}
+}
+
+// String returns the captured output
+func (t *stderrTail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
+
+// reset clears the captured output
+func (t *stderrTail) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = nil
+}
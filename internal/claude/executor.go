@@ -1,10 +1,11 @@
 package claude
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 
+	"github.com/getfinn/finn/internal/event"
 	"github.com/getfinn/finn/internal/git"
 )
 
@@ -18,36 +19,16 @@ type TaskExecutor struct {
 	claude            *Executor
 	git               *git.Repository
 	parser            *DecisionParser
-	onEvent           EventHandler
+	onEvent           event.Handler
 	filesBeforeExec   []string // Track files changed before execution
 	requiresApproval  bool     // Whether diffs require manual approval
+	toolResults       toolResultTracker // Pairs tool results with their tool_use
+	progress          progressTracker   // TodoWrite checklist and sub-agent activity
+	sideBySide        bool              // Add side-by-side rows to diff events
 }
 
-// EventType represents different event types during execution
-type EventType string
-
-const (
-	EventTypeThinking EventType = "thinking"
-	EventTypeToolUse  EventType = "tool_use"
-	EventTypeDecision EventType = "decision"
-	EventTypeProgress EventType = "progress"
-	EventTypeDiff     EventType = "diff"
-	EventTypeComplete EventType = "complete"
-	EventTypeError    EventType = "error"
-	EventTypeUsage    EventType = "usage" // Token usage data from Claude API
-)
-
-// Event represents an event during task execution
-type Event struct {
-	Type    EventType       `json:"type"`
-	Content json.RawMessage `json:"content"`
-}
-
-// EventHandler is called for each event during execution
-type EventHandler func(Event)
-
 // NewTaskExecutor creates a new task executor
-func NewTaskExecutor(projectPath string, requiresApproval bool, onEvent EventHandler) *TaskExecutor {
+func NewTaskExecutor(projectPath string, requiresApproval bool, onEvent event.Handler) *TaskExecutor {
 	return &TaskExecutor{
 		claude:           NewExecutor(projectPath),
 		git:              git.NewRepository(projectPath),
@@ -57,6 +38,33 @@ func NewTaskExecutor(projectPath string, requiresApproval bool, onEvent EventHan
 	}
 }
 
+// SetSideBySideDiffs adds side-by-side rows to the parsed diffs of diff events
+func (e *TaskExecutor) SetSideBySideDiffs(enabled bool) {
+	e.sideBySide = enabled
+}
+
+// SetToolOutputStore sets where full tool outputs are kept for later retrieval
+func (e *TaskExecutor) SetToolOutputStore(store *ToolOutputStore) {
+	e.toolResults.store = store
+}
+
+// SetLimits sets the watchdog limits for the task
+func (e *TaskExecutor) SetLimits(limits Limits) {
+	e.claude.SetLimits(limits)
+}
+
+// SetProcessHooks sets the callbacks run around the spawned CLI process
+func (e *TaskExecutor) SetProcessHooks(hooks ProcessHooks) {
+	e.claude.SetProcessHooks(hooks)
+}
+
+// Abort stops the running task as if it had crossed a watchdog limit, e.g.
+// because the agent found its cost over budget
+func (e *TaskExecutor) Abort(reason TimeoutReason, limit string) {
+	log.Printf("⏱️  Aborting task: %s limit (%s)", reason, limit)
+	e.claude.Abort(reason, limit)
+}
+
 // ExecuteTask runs a Claude Code task with decision extraction
 func (e *TaskExecutor) ExecuteTask(prompt string) error {
 	log.Printf("🚀 Executing task: %s", prompt)
@@ -72,9 +80,24 @@ func (e *TaskExecutor) ExecuteTask(prompt string) error {
 		log.Printf("📋 Detected %d uncommitted files before execution (will be excluded from conversation diffs)", len(filesBeforeExec))
 	}
 
+	// Set once a classified failure has been sent, so the exit status doesn't report it twice
+	failureReported := false
+
 	// Execute Claude Code with streaming
 	err = e.claude.Execute(prompt, func(msg StreamMessage) error {
 		switch msg.Type {
+		case "user":
+			// Tool results come back as "user" messages
+			for _, content := range msg.Message.Content {
+				if content.Type == "tool_result" {
+					e.sendEvent(e.toolResults.buildEvent(content))
+
+					if e.progress.handleToolResult(content) {
+						e.sendEvent(event.New(event.TypeProgress, e.progress.snapshot()))
+					}
+				}
+			}
+
 		case "assistant":
 			// Process assistant message content
 			for _, content := range msg.Message.Content {
@@ -87,22 +110,14 @@ func (e *TaskExecutor) ExecuteTask(prompt string) error {
 					e.parser.AddContent(content.Text)
 
 					// Send thinking to mobile
-					thinkingJSON, _ := json.Marshal(map[string]string{"text": content.Text})
-					e.sendEvent(Event{
-						Type:    EventTypeThinking,
-						Content: thinkingJSON,
-					})
+					e.sendEvent(event.New(event.TypeThinking, event.Thinking{Text: content.Text}))
 
 					// Check if this is a decision point
 					decision, err := e.parser.ExtractDecision()
 					if err == nil && decision != nil {
 						log.Printf("❓ Decision point found: %d options", len(decision.Options))
 						// Send decision to mobile
-						decisionJSON, _ := json.Marshal(decision)
-						e.sendEvent(Event{
-							Type:    EventTypeDecision,
-							Content: decisionJSON,
-						})
+						e.sendEvent(event.New(event.TypeDecision, decision))
 
 						// Reset parser for next decision
 						e.parser.Reset()
@@ -111,23 +126,57 @@ func (e *TaskExecutor) ExecuteTask(prompt string) error {
 				case "tool_use":
 					// Claude is using a tool
 					log.Printf("🔧 Tool: %s", content.Name)
+					e.toolResults.trackToolUse(content.ID, content.Name)
 
-					toolInfo := map[string]interface{}{
-						"tool":  content.Name,
-						"input": content.Input,
-					}
-					toolJSON, _ := json.Marshal(toolInfo)
+					e.sendEvent(event.New(event.TypeToolUse, event.ToolUse{
+						ID:    content.ID,
+						Tool:  content.Name,
+						Input: content.Input,
+					}))
 
-					e.sendEvent(Event{
-						Type:    EventTypeToolUse,
-						Content: toolJSON,
-					})
+					if e.progress.handleToolUse(msg.ParentToolUseID, content) {
+						e.sendEvent(event.New(event.TypeProgress, e.progress.snapshot()))
+					}
 				}
 			}
 
+			// Per-message usage, priced from tokens until the result
+			if msg.Message.Usage != nil {
+				e.sendEvent(event.New(event.TypeUsage, event.Usage{
+					InputTokens:              msg.Message.Usage.InputTokens,
+					OutputTokens:             msg.Message.Usage.OutputTokens,
+					CacheReadInputTokens:     msg.Message.Usage.CacheReadInputTokens,
+					CacheCreationInputTokens: msg.Message.Usage.CacheCreationInputTokens,
+					Model:                    msg.Message.Model,
+					MessageID:                msg.Message.ID,
+					CostUSD:                  msg.TotalCostUSD,
+					DurationMs:               msg.DurationMs,
+				}))
+			}
+
 		case "result":
 			// Task complete - generate diffs
 			log.Printf("✅ Claude Code execution complete: %s", msg.Result)
+			if cliErr := ClassifyResult(msg, ""); cliErr != nil {
+				log.Printf("❌ Claude CLI failure (%s): %s", cliErr.Code, cliErr.Message)
+				failureReported = true
+				e.sendEvent(cliErr.Event())
+			}
+
+			// Final aggregated usage of the run, with its actual cost
+			if msg.TopLevelUsage != nil {
+				log.Printf("📊 Final usage - Input: %d, Output: %d, Cost: $%.6f",
+					msg.TopLevelUsage.InputTokens, msg.TopLevelUsage.OutputTokens, msg.TotalCostUSD)
+				e.sendEvent(event.New(event.TypeUsage, event.Usage{
+					InputTokens:              msg.TopLevelUsage.InputTokens,
+					OutputTokens:             msg.TopLevelUsage.OutputTokens,
+					CacheReadInputTokens:     msg.TopLevelUsage.CacheReadInputTokens,
+					CacheCreationInputTokens: msg.TopLevelUsage.CacheCreationInputTokens,
+					CostUSD:                  msg.TotalCostUSD,
+					DurationMs:               msg.DurationMs,
+					IsFinal:                  true,
+				}))
+			}
 			return e.handleCompletion()
 		}
 
@@ -135,10 +184,14 @@ func (e *TaskExecutor) ExecuteTask(prompt string) error {
 	})
 
 	if err != nil {
-		e.sendEvent(Event{
-			Type:    EventTypeError,
-			Content: json.RawMessage(fmt.Sprintf(`{"message":"%s"}`, err.Error())),
-		})
+		var cliErr *CLIError
+		if errors.As(err, &cliErr) {
+			if !failureReported {
+				e.sendEvent(cliErr.Event())
+			}
+		} else {
+			e.sendEvent(event.New(event.TypeError, event.Error{Message: err.Error()}))
+		}
 		return err
 	}
 
@@ -169,24 +222,15 @@ func (e *TaskExecutor) handleCompletion() error {
 	if len(newFiles) == 0 {
 		// No NEW changes from this conversation - task complete
 		log.Println("📊 No new changes made during this conversation")
-		e.sendEvent(Event{
-			Type:    EventTypeComplete,
-			Content: json.RawMessage(`{"files_changed":0}`),
-		})
+		e.sendEvent(event.New(event.TypeComplete, event.Complete{FilesChanged: 0}))
 		return nil
 	}
 
 	// Generate diffs only for NEW files
 	log.Printf("📊 Generating diffs for %d files changed in this conversation...", len(newFiles))
-	diffs := make(map[string]string)
-
-	for _, file := range newFiles {
-		diff, err := e.git.GenerateDiff(file)
-		if err != nil {
-			log.Printf("⚠️  Failed to generate diff for %s: %v", file, err)
-			continue
-		}
-		diffs[file] = diff
+	diffs, changes, err := collectDiffs(e.git, newFiles, e.sideBySide)
+	if err != nil {
+		log.Printf("⚠️  Failed to generate diffs: %v", err)
 	}
 
 	log.Printf("📊 Generated diffs for %d files", len(diffs))
@@ -202,30 +246,28 @@ func (e *TaskExecutor) handleCompletion() error {
 	}
 
 	// Send diffs to mobile
-	diffData := map[string]interface{}{
-		"diffs":             diffs,
-		"files_changed":     len(diffs),
-		"requires_approval": e.requiresApproval, // Include approval flag based on execution mode
+	requiresApproval := e.requiresApproval // Include approval flag based on execution mode
+	diffData := event.Diff{
+		Diffs:            diffs,
+		Files:            changes,
+		FilesChanged:     len(diffs),
+		RequiresApproval: &requiresApproval,
 	}
-	diffJSON, _ := json.Marshal(diffData)
 
 	if e.requiresApproval {
 		log.Println("📤 Sending diff to mobile - waiting for manual approval...")
 	} else {
 		log.Println("📤 Sending diff to mobile - auto-approved mode")
 	}
-	e.sendEvent(Event{
-		Type:    EventTypeDiff,
-		Content: diffJSON,
-	})
+	e.sendEvent(event.New(event.TypeDiff, diffData))
 
 	// If in auto-approve mode, task is complete immediately after showing diffs
 	if !e.requiresApproval {
 		log.Println("✅ Task complete - auto-approved mode")
-		e.sendEvent(Event{
-			Type:    EventTypeComplete,
-			Content: json.RawMessage(fmt.Sprintf(`{"files_changed":%d,"auto_approved":true}`, len(diffs))),
-		})
+		e.sendEvent(event.New(event.TypeComplete, event.Complete{
+			FilesChanged: len(diffs),
+			AutoApproved: true,
+		}))
 	}
 	// If manual approval mode, we wait for user to approve before completing
 	// (complete will be sent when all diffs are approved)
@@ -242,10 +284,7 @@ func (e *TaskExecutor) CommitChanges(message string) error {
 	}
 
 	// Send completion
-	e.sendEvent(Event{
-		Type:    EventTypeComplete,
-		Content: json.RawMessage(`{"committed":true,"pushed":true}`),
-	})
+	e.sendEvent(event.New(event.TypeComplete, event.Complete{Committed: true, Pushed: true}))
 
 	return nil
 }
@@ -259,17 +298,14 @@ func (e *TaskExecutor) DiscardChanges() error {
 	}
 
 	// Send completion
-	e.sendEvent(Event{
-		Type:    EventTypeComplete,
-		Content: json.RawMessage(`{"committed":false,"discarded":true}`),
-	})
+	e.sendEvent(event.New(event.TypeComplete, event.Complete{Discarded: true}))
 
 	return nil
 }
 
 // sendEvent sends an event to the handler
-func (e *TaskExecutor) sendEvent(event Event) {
+func (e *TaskExecutor) sendEvent(ev event.Event) {
 	if e.onEvent != nil {
-		e.onEvent(event)
+		e.onEvent(ev)
 	}
 }
@@ -0,0 +1,193 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuthState describes how (and whether) the Claude CLI is authenticated
+type AuthState string
+
+const (
+	AuthAPIKey    AuthState = "api_key"    // ANTHROPIC_API_KEY or a cloud provider is configured
+	AuthLoggedIn  AuthState = "logged_in"  // Subscription login found
+	AuthExpired   AuthState = "expired"    // Login found but it can't be refreshed
+	AuthLoggedOut AuthState = "logged_out" // No credentials found
+	AuthUnknown   AuthState = "unknown"    // Couldn't tell
+)
+
+// probeTimeout bounds each command the health probe runs
+const probeTimeout = 10 * time.Second
+
+// healthCacheTTL is how long CachedHealth reuses a probe before refreshing it
+const healthCacheTTL = 5 * time.Minute
+
+// healthCache holds the latest CheckHealth result
+var healthCache struct {
+	mu        sync.Mutex
+	health    Health
+	checkedAt time.Time
+	probing   bool // A background refresh is running
+}
+
+// Health is the result of probing the local Claude Code CLI
+type Health struct {
+	Installed bool      `json:"installed"`
+	Path      string    `json:"path,omitempty"`
+	Version   string    `json:"version,omitempty"`
+	Auth      AuthState `json:"auth"`
+	Error     *CLIError `json:"-"` // Set when the CLI can't be used as-is
+}
+
+// CheckHealth probes the Claude CLI: whether it is installed, its version, and
+// whether credentials are present. It doesn't call the API, so an accepted
+// login can still turn out to be revoked when a task runs.
+func CheckHealth() Health {
+	health := probeHealth()
+
+	healthCache.mu.Lock()
+	healthCache.health = health
+	healthCache.checkedAt = time.Now()
+	healthCache.mu.Unlock()
+	return health
+}
+
+// CachedHealth returns the latest probe without waiting for a new one; ok is
+// false until a probe has finished. A result older than healthCacheTTL is
+// refreshed in the background.
+func CachedHealth() (Health, bool) {
+	healthCache.mu.Lock()
+	defer healthCache.mu.Unlock()
+
+	if time.Since(healthCache.checkedAt) > healthCacheTTL && !healthCache.probing {
+		healthCache.probing = true
+		go func() {
+			CheckHealth()
+			healthCache.mu.Lock()
+			healthCache.probing = false
+			healthCache.mu.Unlock()
+		}()
+	}
+	return healthCache.health, !healthCache.checkedAt.IsZero()
+}
+
+// CheckInstalled is the cheap part of CheckHealth, a PATH lookup: nil if the
+// CLI is installed, otherwise a not_installed error
+func CheckInstalled() *CLIError {
+	if _, err := exec.LookPath("claude"); err != nil {
+		return ClassifyExit(err, "")
+	}
+	return nil
+}
+
+// probeHealth runs the probe behind CheckHealth
+func probeHealth() Health {
+	health := Health{Auth: AuthUnknown}
+
+	path, err := exec.LookPath("claude")
+	if err != nil {
+		health.Error = ClassifyExit(err, "")
+		return health
+	}
+	health.Installed = true
+	health.Path = path
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, path, "--version").CombinedOutput()
+	if err != nil {
+		health.Error = ClassifyExit(err, string(output))
+		return health
+	}
+	// "1.0.80 (Claude Code)"
+	if fields := strings.Fields(string(output)); len(fields) > 0 {
+		health.Version = fields[0]
+	}
+
+	health.Auth = detectAuth()
+	if health.Auth == AuthLoggedOut || health.Auth == AuthExpired {
+		health.Error = &CLIError{
+			Code:    ErrorNotLoggedIn,
+			Message: "Claude Code is not logged in or its credentials have expired",
+			Action:  "Run `claude` on this computer and complete /login, then retry",
+		}
+	}
+
+	return health
+}
+
+// IsInstalled checks if Claude Code CLI is installed. See CheckHealth for a full probe.
+func IsInstalled() bool {
+	_, err := exec.LookPath("claude")
+	return err == nil
+}
+
+// claudeConfigDir returns the CLI's user configuration directory (~/.claude)
+func claudeConfigDir() (string, error) {
+	if dir := os.Getenv("CLAUDE_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".claude"), nil
+}
+
+// detectAuth looks for the credentials the CLI would use, in its order of precedence
+func detectAuth() AuthState {
+	for _, name := range []string{"ANTHROPIC_API_KEY", "CLAUDE_CODE_USE_BEDROCK", "CLAUDE_CODE_USE_VERTEX"} {
+		if os.Getenv(name) != "" {
+			return AuthAPIKey
+		}
+	}
+	if os.Getenv("CLAUDE_CODE_OAUTH_TOKEN") != "" {
+		return AuthLoggedIn
+	}
+
+	configDir, err := claudeConfigDir()
+	if err != nil {
+		return AuthUnknown
+	}
+
+	// Linux and Windows keep the subscription login in a credentials file
+	data, err := os.ReadFile(filepath.Join(configDir, ".credentials.json"))
+	if err == nil {
+		var creds struct {
+			ClaudeAiOauth *struct {
+				RefreshToken string `json:"refreshToken"`
+				ExpiresAt    int64  `json:"expiresAt"` // Unix milliseconds
+			} `json:"claudeAiOauth"`
+		}
+		if err := json.Unmarshal(data, &creds); err != nil || creds.ClaudeAiOauth == nil {
+			return AuthUnknown
+		}
+		oauth := creds.ClaudeAiOauth
+		if oauth.RefreshToken == "" && oauth.ExpiresAt > 0 && time.Now().UnixMilli() > oauth.ExpiresAt {
+			return AuthExpired
+		}
+		return AuthLoggedIn
+	}
+
+	// macOS keeps it in the login keychain
+	if runtime.GOOS == "darwin" {
+		ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+		defer cancel()
+		if exec.CommandContext(ctx, "security", "find-generic-password", "-s", "Claude Code-credentials").Run() == nil {
+			return AuthLoggedIn
+		}
+	}
+
+	if os.IsNotExist(err) {
+		return AuthLoggedOut
+	}
+	return AuthUnknown
+}
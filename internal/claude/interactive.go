@@ -2,6 +2,8 @@ package claude
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,6 +15,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/getfinn/finn/internal/event"
 	"github.com/getfinn/finn/internal/git"
 )
 
@@ -24,7 +27,7 @@ type InteractiveTaskExecutor struct {
 	projectPath string
 	git         *git.Repository
 	parser      *DecisionParser
-	onEvent     EventHandler
+	onEvent     event.Handler
 
 	// Session linking callback - called when Claude's session_id is detected
 	onSessionLinked SessionLinkedHandler
@@ -34,134 +37,170 @@ type InteractiveTaskExecutor struct {
 	stdin     io.WriteCloser
 	isRunning bool
 	mutex     sync.Mutex
+	exited    chan struct{} // Closed once the current process's output has been fully handled
+	hooks     ProcessHooks  // Accounting for spawned processes (see resources.go)
 
-	// Session detection
-	existingSessionsBeforeStart map[string]bool // Session files that existed before Claude started
-	sessionDetected             bool            // Whether we've already detected and reported the session
+	// Idle tracking for process reaping (guarded by mutex)
+	turnActive   bool      // A message was sent and its result hasn't arrived
+	lastActivity time.Time // When the last turn started or finished
+	suspending   bool      // The process is being stopped by Suspend, not failing
+
+	// Failure classification (see errors.go)
+	stderr          stderrTail // Recent stderr of the current process
+	failureReported bool       // A classified error was already sent for the current process
+	watchdog        watchdog   // Enforces Limits on each task
+
+	// Transcript recording and playback (see transcript.go)
+	recorder      *Recorder         // Tees stdin/stdout/stderr and file edits when set
+	recordedFiles map[string]string // Path -> content hash last recorded (or found before the task)
+	replayer      *Replayer         // Replaces the claude process when set
+
+	// Session linking - the ID comes from the CLI's system/init and result messages
+	sessionID string // Claude session this executor is attached to (guarded by mutex)
 
 	// Tracking
 	filesBeforeExec       []string
+	reviewFiles           []string // When set, the files to review, changed before execution or not
 	lastThinkingText      string
 	sentDiffs             map[string]bool // Track which files we've sent diffs for (prevent duplicates)
 	diffMutex             sync.Mutex
-	filesModifiedThisTurn map[string]bool // Track files written in current turn (prevent re-execution)
-	turnCompleted         bool            // Track if complete event sent this turn
+	filesModifiedThisTurn map[string]bool   // Track files written in current turn (prevent re-execution)
+	turnCompleted         bool              // Track if complete event sent this turn
+	toolResults           toolResultTracker // Pairs tool results with their tool_use
+	progress              progressTracker   // TodoWrite checklist and sub-agent activity
+
+	// Attachments for the next message sent (see attachments.go)
+	attachments *Attachments
+
+	// Token streaming (see stream_delta.go)
+	partialMessages bool           // Pass --include-partial-messages to the CLI
+	deltas          deltaCoalescer // Batches text deltas into thinking_delta events
+
+	sideBySide bool // Add side-by-side rows to diff events
 }
 
 // NewInteractiveTaskExecutor creates a new interactive task executor
-func NewInteractiveTaskExecutor(projectPath string, onEvent EventHandler) *InteractiveTaskExecutor {
-	return &InteractiveTaskExecutor{
-		projectPath:                 projectPath,
-		git:                         git.NewRepository(projectPath),
-		parser:                      NewDecisionParser(),
-		onEvent:                     onEvent,
-		isRunning:                   false,
-		sentDiffs:                   make(map[string]bool),
-		filesModifiedThisTurn:       make(map[string]bool),
-		turnCompleted:               false,
-		existingSessionsBeforeStart: make(map[string]bool),
-		sessionDetected:             false,
+func NewInteractiveTaskExecutor(projectPath string, onEvent event.Handler) *InteractiveTaskExecutor {
+	e := &InteractiveTaskExecutor{
+		projectPath:           projectPath,
+		git:                   git.NewRepository(projectPath),
+		parser:                NewDecisionParser(),
+		onEvent:               onEvent,
+		isRunning:             false,
+		sentDiffs:             make(map[string]bool),
+		filesModifiedThisTurn: make(map[string]bool),
+		turnCompleted:         false,
 	}
+	e.deltas.emit = e.sendEvent
+	e.watchdog.onExpire = e.handleTimeout
+	return e
 }
 
-// SetSessionLinkedHandler sets the callback for when Claude's session_id is detected
-func (e *InteractiveTaskExecutor) SetSessionLinkedHandler(handler SessionLinkedHandler) {
-	e.onSessionLinked = handler
+// NewReplayTaskExecutor creates an interactive executor that plays back a recorded
+// transcript instead of spawning the Claude CLI
+func NewReplayTaskExecutor(projectPath string, replayer *Replayer, onEvent event.Handler) *InteractiveTaskExecutor {
+	e := NewInteractiveTaskExecutor(projectPath, onEvent)
+	e.replayer = replayer
+	return e
 }
 
-// getClaudeSessionDir returns the path to Claude's session directory for this project
-func (e *InteractiveTaskExecutor) getClaudeSessionDir() string {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return ""
-	}
-	// Claude encodes paths by replacing / with -
-	encoded := strings.ReplaceAll(e.projectPath, "/", "-")
-	return filepath.Join(home, ".claude", "projects", encoded)
+// SetRecorder enables transcript recording for this executor
+func (e *InteractiveTaskExecutor) SetRecorder(recorder *Recorder) {
+	e.recorder = recorder
 }
 
-// captureExistingSessions records all session files that exist before Claude starts
-func (e *InteractiveTaskExecutor) captureExistingSessions() {
-	e.existingSessionsBeforeStart = make(map[string]bool)
-	sessionDir := e.getClaudeSessionDir()
-	if sessionDir == "" {
-		return
-	}
+// SetLimits sets the watchdog limits applied to each task from now on
+func (e *InteractiveTaskExecutor) SetLimits(limits Limits) {
+	e.watchdog.mu.Lock()
+	defer e.watchdog.mu.Unlock()
+	e.watchdog.limits = limits
+}
 
-	entries, err := os.ReadDir(sessionDir)
-	if err != nil {
-		log.Printf("⚠️  Could not read session dir %s: %v", sessionDir, err)
-		return
-	}
+// SetProcessHooks sets the callbacks run around each spawned CLI process
+func (e *InteractiveTaskExecutor) SetProcessHooks(hooks ProcessHooks) {
+	e.hooks = hooks
+}
 
-	for _, entry := range entries {
-		if strings.HasSuffix(entry.Name(), ".jsonl") {
-			sessionID := strings.TrimSuffix(entry.Name(), ".jsonl")
-			e.existingSessionsBeforeStart[sessionID] = true
-		}
-	}
+// SetAttachments attaches files to the next message sent to Claude
+func (e *InteractiveTaskExecutor) SetAttachments(attachments *Attachments) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.attachments = attachments
+}
 
-	log.Printf("📂 Captured %d existing sessions before task execution", len(e.existingSessionsBeforeStart))
+// SetReviewFiles limits the diffs sent on completion to files, including
+// them even if they were changed before the task started (e.g. conflicted files)
+func (e *InteractiveTaskExecutor) SetReviewFiles(files []string) {
+	e.reviewFiles = files
 }
 
-// detectNewSession polls for a new session file and calls the callback when found
-func (e *InteractiveTaskExecutor) detectNewSession() {
-	if e.onSessionLinked == nil {
-		return
-	}
+// SetPartialMessages enables token-level streaming of assistant text. Deltas
+// are forwarded as thinking_delta events ahead of the usual thinking event.
+func (e *InteractiveTaskExecutor) SetPartialMessages(enabled bool) {
+	e.partialMessages = enabled
+}
 
-	sessionDir := e.getClaudeSessionDir()
-	if sessionDir == "" {
-		return
-	}
+// SetSideBySideDiffs adds side-by-side rows to the parsed diffs of diff events
+func (e *InteractiveTaskExecutor) SetSideBySideDiffs(enabled bool) {
+	e.sideBySide = enabled
+}
 
-	// Poll for new session file (check every 100ms for up to 10 seconds)
-	ticker := time.NewTicker(100 * time.Millisecond)
-	defer ticker.Stop()
+// SetToolOutputStore sets where full tool outputs are kept for later retrieval
+func (e *InteractiveTaskExecutor) SetToolOutputStore(store *ToolOutputStore) {
+	e.toolResults.store = store
+}
 
-	timeout := time.After(10 * time.Second)
+// SetSessionLinkedHandler sets the callback for when Claude's session_id is detected
+func (e *InteractiveTaskExecutor) SetSessionLinkedHandler(handler SessionLinkedHandler) {
+	e.onSessionLinked = handler
+}
 
-	for {
-		select {
-		case <-timeout:
-			log.Printf("⚠️  Timed out waiting for new session file")
-			return
-		case <-ticker.C:
-			if e.sessionDetected {
-				return // Already detected
-			}
+// setSessionID records the Claude session this executor is attached to
+func (e *InteractiveTaskExecutor) setSessionID(sessionID string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.sessionID = sessionID
+}
 
-			entries, err := os.ReadDir(sessionDir)
-			if err != nil {
-				continue
-			}
+// observeSessionID links the session ID reported by the CLI. A different ID
+// than the one already linked means Claude Code forked the session (e.g. on
+// resume), so the conversation is re-linked to the new one.
+func (e *InteractiveTaskExecutor) observeSessionID(sessionID string) {
+	if sessionID == "" {
+		return
+	}
 
-			for _, entry := range entries {
-				if !strings.HasSuffix(entry.Name(), ".jsonl") {
-					continue
-				}
+	e.mutex.Lock()
+	previous := e.sessionID
+	e.sessionID = sessionID
+	e.mutex.Unlock()
+
+	if previous == sessionID {
+		return
+	}
 
-				sessionID := strings.TrimSuffix(entry.Name(), ".jsonl")
+	if previous != "" {
+		log.Printf("🔀 Session changed: %s → %s", previous, sessionID)
+	} else {
+		log.Printf("🔗 Session reported by Claude: %s", sessionID)
+	}
 
-				// Check if this is a new session
-				if !e.existingSessionsBeforeStart[sessionID] {
-					e.sessionDetected = true
-					log.Printf("🔗 Detected new session: %s", sessionID)
-					e.onSessionLinked(sessionID)
-					return
-				}
-			}
-		}
+	if e.onSessionLinked != nil {
+		e.onSessionLinked(sessionID)
 	}
 }
 
+// SessionID returns the Claude session ID, or "" if not yet known
+func (e *InteractiveTaskExecutor) SessionID() string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.sessionID
+}
+
 // ExecuteTask starts an interactive conversation with an initial prompt
 func (e *InteractiveTaskExecutor) ExecuteTask(prompt string) error {
 	log.Printf("🚀 Starting interactive task: %s", prompt)
 
-	// Capture existing sessions before starting Claude
-	e.captureExistingSessions()
-
 	// Start new turn
 	e.startNewTurn()
 
@@ -172,20 +211,13 @@ func (e *InteractiveTaskExecutor) ExecuteTask(prompt string) error {
 		filesBeforeExec = []string{} // Continue anyway
 	}
 	e.filesBeforeExec = filesBeforeExec
+	e.baselineRecordedFiles()
 	if len(filesBeforeExec) > 0 {
 		log.Printf("📋 Detected %d uncommitted files before execution (will be excluded from conversation diffs)", len(filesBeforeExec))
 	}
 
 	// Prepend security instructions
-	securityInstructions := fmt.Sprintf(`CRITICAL SECURITY RULES:
-1. You are RESTRICTED to working ONLY within the approved project folder: %s
-2. DO NOT access, read, or modify ANY files outside this directory under any circumstances
-3. If the user requests access to files outside this folder, politely decline and explain the restriction
-4. DO NOT commit any changes to git - just make the file changes and stop
-5. DO NOT use commands like 'cd ..' or absolute paths that go outside the approved folder
-
-User request: `, e.projectPath)
-	fullPrompt := securityInstructions + prompt
+	fullPrompt := securityRules(e.projectPath) + "\nUser request: " + prompt
 
 	// Build interactive command
 	// Interactive mode with auto-execute
@@ -197,56 +229,55 @@ User request: `, e.projectPath)
 	//
 	// We send prompts via stdin in JSON format (requires --input-format flag)
 	// Output format is stream-json so we can parse events
-	cmd := exec.Command("claude",
+	if err := e.startProcess([]string{
 		"--input-format", "stream-json",
 		"--output-format", "stream-json",
 		"--verbose",
-		"--dangerously-skip-permissions")
+		"--dangerously-skip-permissions"}); err != nil {
+		return err
+	}
 
-	cmd.Dir = e.projectPath
-	cmd.Env = os.Environ()
+	// Send initial message via stdin
+	return e.SendMessage(fullPrompt)
+}
 
-	// Get stdin, stdout, stderr pipes
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return fmt.Errorf("failed to get stdin pipe: %w", err)
-	}
-	e.stdin = stdin
+// ExecuteCommand starts an interactive conversation with a slash command
+// (e.g. "/review" or a custom command). The command must be the first thing
+// in the message, so the security rules go into the system prompt instead.
+func (e *InteractiveTaskExecutor) ExecuteCommand(command string) error {
+	log.Printf("🚀 Starting interactive task with command: %s", command)
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("failed to get stdout pipe: %w", err)
-	}
+	e.startNewTurn()
 
-	stderr, err := cmd.StderrPipe()
+	filesBeforeExec, err := e.git.DetectChangedFiles()
 	if err != nil {
-		return fmt.Errorf("failed to get stderr pipe: %w", err)
+		log.Printf("⚠️  Failed to detect files before execution: %v", err)
+		filesBeforeExec = []string{} // Continue anyway
 	}
+	e.filesBeforeExec = filesBeforeExec
+	e.baselineRecordedFiles()
 
-	// Start command
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start claude: %w", err)
+	if err := e.startProcess([]string{
+		"--input-format", "stream-json",
+		"--output-format", "stream-json",
+		"--verbose",
+		"--append-system-prompt", securityRules(e.projectPath),
+		"--dangerously-skip-permissions"}); err != nil {
+		return err
 	}
 
-	e.cmd = cmd
-	e.isRunning = true
-
-	// Detect new session file in background (for linking with conversation_id)
-	go e.detectNewSession()
-
-	// Stream stdout in goroutine
-	go e.streamOutput(stdout)
-
-	// Stream stderr in goroutine
-	go func() {
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			log.Printf("Claude stderr: %s", scanner.Text())
-		}
-	}()
+	return e.SendMessage(command)
+}
 
-	// Send initial message via stdin
-	return e.SendMessage(fullPrompt)
+// securityRules are the folder restrictions given to Claude with every new conversation
+func securityRules(projectPath string) string {
+	return fmt.Sprintf(`CRITICAL SECURITY RULES:
+1. You are RESTRICTED to working ONLY within the approved project folder: %s
+2. DO NOT access, read, or modify ANY files outside this directory under any circumstances
+3. If the user requests access to files outside this folder, politely decline and explain the restriction
+4. DO NOT commit any changes to git - just make the file changes and stop
+5. DO NOT use commands like 'cd ..' or absolute paths that go outside the approved folder
+`, projectPath)
 }
 
 // SendMessage sends a message to the ongoing conversation
@@ -260,13 +291,26 @@ func (e *InteractiveTaskExecutor) SendMessage(message string) error {
 
 	log.Printf("📤 Sending message to Claude: %s", message)
 
+	// Each message starts a task the watchdog times
+	e.failureReported = false
+	e.watchdog.arm()
+	e.turnActive = true
+	e.lastActivity = time.Now()
+
+	// Attached images become content blocks alongside the text
+	var content interface{} = message
+	if e.attachments != nil {
+		content = e.attachments.contentBlocks(message)
+		e.attachments = nil
+	}
+
 	// Build message in Claude CLI's expected format for --input-format stream-json
 	// Format: {"type": "user", "message": {"role": "user", "content": "..."}}
 	msg := map[string]interface{}{
 		"type": "user",
 		"message": map[string]interface{}{
 			"role":    "user",
-			"content": message,
+			"content": content,
 		},
 	}
 
@@ -278,6 +322,7 @@ func (e *InteractiveTaskExecutor) SendMessage(message string) error {
 
 	// Append newline for line-delimited JSON
 	msgJSON = append(msgJSON, '\n')
+	e.recorder.RecordStdin(msgJSON)
 
 	_, err = e.stdin.Write(msgJSON)
 	if err != nil {
@@ -321,7 +366,8 @@ func isCompletionText(text string) bool {
 }
 
 // streamOutput handles streaming output from Claude
-func (e *InteractiveTaskExecutor) streamOutput(stdout io.Reader) {
+// wait, if set, reaps the process once stdout is drained and reports how it exited.
+func (e *InteractiveTaskExecutor) streamOutput(stdout io.Reader, wait func() error, exited chan struct{}) {
 	scanner := bufio.NewScanner(stdout)
 	// Increase buffer size for large outputs
 	const maxCapacity = 1024 * 1024 // 1MB
@@ -330,20 +376,26 @@ func (e *InteractiveTaskExecutor) streamOutput(stdout io.Reader) {
 
 	for scanner.Scan() {
 		line := scanner.Text()
+		e.watchdog.touch()
 
 		var msg StreamMessage
 		if err := json.Unmarshal([]byte(line), &msg); err != nil {
 			log.Printf("⚠️  Failed to parse stream message: %v", err)
+			e.recorder.RecordStdout(line)
 			continue
 		}
 
+		// Snapshot edited files ahead of the result line so a replay
+		// has them on disk before it generates diffs
+		if msg.Type == "result" {
+			e.recordChangedFiles()
+		}
+		e.recorder.RecordStdout(line)
+
 		// Handle the stream message
 		if err := e.handleStreamMessage(msg); err != nil {
 			log.Printf("❌ Error handling stream message: %v", err)
-			e.sendEvent(Event{
-				Type:    EventTypeError,
-				Content: json.RawMessage(fmt.Sprintf(`{"message":"%s"}`, err.Error())),
-			})
+			e.sendEvent(event.New(event.TypeError, event.Error{Message: err.Error()}))
 		}
 	}
 
@@ -353,26 +405,57 @@ func (e *InteractiveTaskExecutor) streamOutput(stdout io.Reader) {
 
 	// Process exited
 	log.Println("🏁 Claude process exited")
+	e.watchdog.disarm()
+	e.deltas.flush()
+
+	e.mutex.Lock()
+	suspended := e.suspending
+	e.mutex.Unlock()
+
+	if wait != nil {
+		exitErr := wait()
+		// Report abnormal exits that weren't already explained by a result/error message
+		if !e.failureReported && !suspended {
+			e.reportFailure(ClassifyExit(exitErr, e.stderr.String()))
+		}
+	}
+
 	e.mutex.Lock()
 	e.isRunning = false
+	e.turnActive = false
+	e.suspending = false
 	e.mutex.Unlock()
 
-	// Handle completion
-	e.handleCompletion()
+	// Handle completion (a suspended process already completed its turn)
+	if !suspended {
+		e.handleCompletion()
+	}
+
+	// Don't hold the transcript open while no process runs
+	e.recorder.RecordExit()
+	e.recorder.Close()
+	close(exited)
 }
 
 // handleStreamMessage processes a streaming message from Claude
 func (e *InteractiveTaskExecutor) handleStreamMessage(msg StreamMessage) error {
 	switch msg.Type {
+	case "stream_event":
+		// Partial message (--include-partial-messages). Sub-agent text isn't
+		// streamed; it arrives as complete blocks like before.
+		if msg.ParentToolUseID == "" {
+			e.deltas.handle(msg.Event)
+		}
+
 	case "user":
 		// Claude Code CLI sends tool_result messages as "user" type
 		for _, content := range msg.Message.Content {
 			if content.Type == "tool_result" {
-				// Log tool execution results
-				if content.Text != "" {
-					log.Printf("🔧 Tool result: %s", content.Text)
-				} else {
-					log.Printf("🔧 Tool execution completed")
+				log.Printf("🔧 Tool result for %s (error=%v)", content.ToolUseID, content.IsError)
+				e.sendEvent(e.toolResults.buildEvent(content))
+
+				if e.progress.handleToolResult(content) {
+					e.sendEvent(event.New(event.TypeProgress, e.progress.snapshot()))
 				}
 			}
 		}
@@ -381,7 +464,17 @@ func (e *InteractiveTaskExecutor) handleStreamMessage(msg StreamMessage) error {
 		// System messages (init, etc.)
 		log.Printf("⚙️  System: %s", msg.Subtype)
 
+		// init is the first message of every process and names its session
+		if msg.Subtype == "init" {
+			e.observeSessionID(msg.SessionID)
+		}
+
 	case "assistant":
+		e.watchdog.countTurn()
+
+		// Deliver any buffered deltas before the complete block supersedes them
+		e.deltas.flush()
+
 		// Process assistant message content
 		for _, content := range msg.Message.Content {
 			switch content.Type {
@@ -396,22 +489,14 @@ func (e *InteractiveTaskExecutor) handleStreamMessage(msg StreamMessage) error {
 				e.parser.AddContent(content.Text)
 
 				// Send thinking to mobile
-				thinkingJSON, _ := json.Marshal(map[string]string{"text": content.Text})
-				e.sendEvent(Event{
-					Type:    EventTypeThinking,
-					Content: thinkingJSON,
-				})
+				e.sendEvent(event.New(event.TypeThinking, event.Thinking{Text: content.Text}))
 
 				// Check if this is a decision point
 				decision, err := e.parser.ExtractDecision()
 				if err == nil && decision != nil {
 					log.Printf("❓ Decision point found: %d options", len(decision.Options))
 					// Send decision to mobile
-					decisionJSON, _ := json.Marshal(decision)
-					e.sendEvent(Event{
-						Type:    EventTypeDecision,
-						Content: decisionJSON,
-					})
+					e.sendEvent(event.New(event.TypeDecision, decision))
 
 					// Reset parser for next decision
 					e.parser.Reset()
@@ -420,17 +505,19 @@ func (e *InteractiveTaskExecutor) handleStreamMessage(msg StreamMessage) error {
 			case "tool_use":
 				// Claude is using a tool
 				log.Printf("🔧 Tool: %s", content.Name)
+				e.toolResults.trackToolUse(content.ID, content.Name)
 
 				// Send tool use event to mobile (for "Tools Used" display)
-				toolInfo := map[string]interface{}{
-					"tool":  content.Name,
-					"input": content.Input,
+				e.sendEvent(event.New(event.TypeToolUse, event.ToolUse{
+					ID:    content.ID,
+					Tool:  content.Name,
+					Input: content.Input,
+				}))
+
+				// TodoWrite and Task calls feed the structured progress view
+				if e.progress.handleToolUse(msg.ParentToolUseID, content) {
+					e.sendEvent(event.New(event.TypeProgress, e.progress.snapshot()))
 				}
-				toolJSON, _ := json.Marshal(toolInfo)
-				e.sendEvent(Event{
-					Type:    EventTypeToolUse,
-					Content: toolJSON,
-				})
 
 				// Note: Tools execute automatically with --dangerously-skip-permissions
 				// Diffs are generated AFTER execution in handleCompletion()
@@ -456,27 +543,21 @@ func (e *InteractiveTaskExecutor) handleStreamMessage(msg StreamMessage) error {
 						q := askInput.Questions[0]
 
 						// Convert to our decision format (all questions are strategic now)
-						options := []map[string]string{}
+						options := []event.Option{}
 						for i, opt := range q.Options {
-							options = append(options, map[string]string{
-								"id":          fmt.Sprintf("%d", i+1),
-								"label":       opt.Label,
-								"description": opt.Description,
+							options = append(options, event.Option{
+								ID:          fmt.Sprintf("%d", i+1),
+								Label:       opt.Label,
+								Description: opt.Description,
 							})
 						}
 
-						decision := map[string]interface{}{
-							"question":      q.Question,
-							"options":       options,
-							"context":       q.Header,
-							"decision_type": "question",
-						}
-
-						decisionJSON, _ := json.Marshal(decision)
-						e.sendEvent(Event{
-							Type:    EventTypeDecision,
-							Content: decisionJSON,
-						})
+						e.sendEvent(event.New(event.TypeDecision, event.Decision{
+							Question:     q.Question,
+							Options:      options,
+							Context:      q.Header,
+							DecisionType: "question",
+						}))
 
 						// Don't send as tool_use - we converted it to decision
 						continue
@@ -494,28 +575,22 @@ func (e *InteractiveTaskExecutor) handleStreamMessage(msg StreamMessage) error {
 
 					if err := json.Unmarshal(content.Input, &planInput); err == nil && planInput.Plan != "" {
 						// Send as a special "plan" event with approve/reject options
-						decision := map[string]interface{}{
-							"question": "Ready to execute this plan?",
-							"context":  planInput.Plan,
-							"options": []map[string]string{
+						e.sendEvent(event.New(event.TypeDecision, event.Decision{
+							Question: "Ready to execute this plan?",
+							Context:  planInput.Plan,
+							Options: []event.Option{
 								{
-									"id":          "approve",
-									"label":       "Approve & Execute",
-									"description": "Start executing the plan",
+									ID:          "approve",
+									Label:       "Approve & Execute",
+									Description: "Start executing the plan",
 								},
 								{
-									"id":          "revise",
-									"label":       "Ask for Changes",
-									"description": "Tell Claude what to change",
+									ID:          "revise",
+									Label:       "Ask for Changes",
+									Description: "Tell Claude what to change",
 								},
 							},
-						}
-
-						decisionJSON, _ := json.Marshal(decision)
-						e.sendEvent(Event{
-							Type:    EventTypeDecision,
-							Content: decisionJSON,
-						})
+						}))
 
 						// Don't send as tool_use - we converted it to plan approval
 						continue
@@ -545,31 +620,32 @@ func (e *InteractiveTaskExecutor) handleStreamMessage(msg StreamMessage) error {
 				msg.Message.Usage.CacheReadInputTokens,
 				msg.Message.Usage.CacheCreationInputTokens)
 
-			usageData := map[string]interface{}{
-				"input_tokens":                msg.Message.Usage.InputTokens,
-				"output_tokens":               msg.Message.Usage.OutputTokens,
-				"cache_read_input_tokens":     msg.Message.Usage.CacheReadInputTokens,
-				"cache_creation_input_tokens": msg.Message.Usage.CacheCreationInputTokens,
-				"model":                       msg.Message.Model,
-			}
-			// Add cost if available at top level
-			if msg.TotalCostUSD > 0 {
-				usageData["cost_usd"] = msg.TotalCostUSD
-			}
-			if msg.DurationMs > 0 {
-				usageData["duration_ms"] = msg.DurationMs
-			}
-
-			usageJSON, _ := json.Marshal(usageData)
-			e.sendEvent(Event{
-				Type:    EventTypeUsage,
-				Content: usageJSON,
-			})
+			e.sendEvent(event.New(event.TypeUsage, event.Usage{
+				InputTokens:              msg.Message.Usage.InputTokens,
+				OutputTokens:             msg.Message.Usage.OutputTokens,
+				CacheReadInputTokens:     msg.Message.Usage.CacheReadInputTokens,
+				CacheCreationInputTokens: msg.Message.Usage.CacheCreationInputTokens,
+				Model:                    msg.Message.Model,
+				MessageID:                msg.Message.ID,
+				CostUSD:                  msg.TotalCostUSD, // Only present on some messages
+				DurationMs:               msg.DurationMs,
+			}))
 		}
 
 	case "result":
 		// Task complete - all tools have executed, files are written
 		log.Printf("✅ Claude Code execution complete: %s", msg.Result)
+		e.watchdog.disarm()
+		e.mutex.Lock()
+		e.turnActive = false
+		e.lastActivity = time.Now()
+		e.mutex.Unlock()
+
+		// Confirms the session for -p runs, where init may be the only other report
+		e.observeSessionID(msg.SessionID)
+
+		// Failed results (usage limit, auth, context window...) become typed errors
+		e.reportFailure(ClassifyResult(msg, e.stderr.String()))
 
 		// Extract final usage data from result message (aggregated totals)
 		if msg.TopLevelUsage != nil {
@@ -580,21 +656,15 @@ func (e *InteractiveTaskExecutor) handleStreamMessage(msg StreamMessage) error {
 				msg.TopLevelUsage.CacheCreationInputTokens,
 				msg.TotalCostUSD)
 
-			usageData := map[string]interface{}{
-				"input_tokens":                msg.TopLevelUsage.InputTokens,
-				"output_tokens":               msg.TopLevelUsage.OutputTokens,
-				"cache_read_input_tokens":     msg.TopLevelUsage.CacheReadInputTokens,
-				"cache_creation_input_tokens": msg.TopLevelUsage.CacheCreationInputTokens,
-				"cost_usd":                    msg.TotalCostUSD,
-				"duration_ms":                 msg.DurationMs,
-				"is_final":                    true, // Mark as final aggregated usage
-			}
-
-			usageJSON, _ := json.Marshal(usageData)
-			e.sendEvent(Event{
-				Type:    EventTypeUsage,
-				Content: usageJSON,
-			})
+			e.sendEvent(event.New(event.TypeUsage, event.Usage{
+				InputTokens:              msg.TopLevelUsage.InputTokens,
+				OutputTokens:             msg.TopLevelUsage.OutputTokens,
+				CacheReadInputTokens:     msg.TopLevelUsage.CacheReadInputTokens,
+				CacheCreationInputTokens: msg.TopLevelUsage.CacheCreationInputTokens,
+				CostUSD:                  msg.TotalCostUSD,
+				DurationMs:               msg.DurationMs,
+				IsFinal:                  true, // Mark as final aggregated usage
+			}))
 		}
 
 		// NOW generate diffs (files exist on disk)
@@ -604,22 +674,45 @@ func (e *InteractiveTaskExecutor) handleStreamMessage(msg StreamMessage) error {
 
 	case "error":
 		log.Printf("❌ Claude error: %s", msg.Result)
-		return fmt.Errorf("claude error: %s", msg.Result)
+		msg.IsError = true
+		e.reportFailure(ClassifyResult(msg, e.stderr.String()))
 	}
 
 	return nil
 }
 
+// reportFailure sends a classified CLI failure to the client, once per task
+func (e *InteractiveTaskExecutor) reportFailure(cliErr *CLIError) {
+	if cliErr == nil || e.failureReported {
+		return
+	}
+	log.Printf("❌ Claude CLI failure (%s): %s", cliErr.Code, cliErr.Message)
+	e.failureReported = true
+	e.sendEvent(cliErr.Event())
+}
+
+// handleTimeout stops a task that crossed one of its limits
+func (e *InteractiveTaskExecutor) handleTimeout(stats event.Timeout) {
+	e.reportFailure(timeoutError(stats))
+
+	e.mutex.Lock()
+	cmd := e.cmd
+	exited := e.exited
+	running := e.isRunning
+	e.mutex.Unlock()
+
+	if running {
+		terminateProcess(cmd, exited)
+	}
+}
+
 // handleCompletion handles task completion (generate diffs, etc.)
 // Called when Claude Code sends "result" message - all tools have executed
 func (e *InteractiveTaskExecutor) handleCompletion() error {
 	// Get all changed files after execution (tools have finished, files exist)
 	filesAfterExec, err := e.git.DetectChangedFiles()
 	if err != nil {
-		e.sendEvent(Event{
-			Type:    EventTypeError,
-			Content: json.RawMessage(fmt.Sprintf(`{"message":"Failed to detect changes: %s"}`, err.Error())),
-		})
+		e.sendEvent(event.Errorf("Failed to detect changes: %v", err))
 		return fmt.Errorf("failed to detect changes: %w", err)
 	}
 
@@ -635,68 +728,81 @@ func (e *InteractiveTaskExecutor) handleCompletion() error {
 			conversationFiles = append(conversationFiles, f)
 		}
 	}
+	if len(e.reviewFiles) > 0 {
+		conversationFiles = e.reviewFiles
+	}
 
 	if len(conversationFiles) == 0 {
 		log.Println("✅ No new files changed by this conversation")
-		e.sendEvent(Event{
-			Type:    EventTypeComplete,
-			Content: json.RawMessage(`{"files_changed":0}`),
-		})
+		e.sendEvent(event.New(event.TypeComplete, event.Complete{FilesChanged: 0}))
 		return nil
 	}
 
 	log.Printf("🔍 Generating diffs for %d conversation files (after execution complete)", len(conversationFiles))
 
-	// Generate diffs for conversation files
-	diffs := make(map[string]string)
-	for _, filePath := range conversationFiles {
-		log.Printf("  📄 Generating diff for: %s", filePath)
-		diff, err := e.git.GenerateDiff(filePath)
-		if err != nil {
-			log.Printf("  ❌ Failed to generate diff for %s: %v", filePath, err)
-			continue
-		}
-		if diff == "" || len(diff) == 0 {
-			log.Printf("  ⚠️  Diff is empty for %s - skipping", filePath)
-			continue
-		}
-		log.Printf("  ✅ Generated diff (%d bytes)", len(diff))
-		diffs[filePath] = diff
+	// Generate diffs for conversation files in one batch
+	diffs, changes, err := collectDiffs(e.git, conversationFiles, e.sideBySide)
+	if err != nil {
+		log.Printf("  ❌ Failed to generate diffs: %v", err)
 	}
 
 	if len(diffs) == 0 {
 		log.Println("⚠️  No valid diffs generated (all empty)")
-		e.sendEvent(Event{
-			Type:    EventTypeComplete,
-			Content: json.RawMessage(`{"files_changed":0}`),
-		})
+		e.sendEvent(event.New(event.TypeComplete, event.Complete{FilesChanged: 0}))
 		return nil
 	}
 
 	// Send ALL diffs in one batch (not incremental)
-	diffData := map[string]interface{}{
-		"files_changed": len(diffs),
-		"diffs":         diffs,
-	}
-	diffJSON, _ := json.Marshal(diffData)
-	e.sendEvent(Event{
-		Type:    EventTypeDiff,
-		Content: diffJSON,
-	})
+	e.sendEvent(event.New(event.TypeDiff, event.Diff{
+		FilesChanged: len(diffs),
+		Diffs:        diffs,
+		Files:        changes,
+	}))
 
 	log.Printf("✅ Sent %d diffs to mobile - conversation complete", len(diffs))
 
 	// Send complete event immediately - don't block conversation
 	// User can review and commit at their leisure
-	e.sendCompleteEvent(fmt.Sprintf("%d files changed", len(diffs)))
+	e.sendCompleteEvent(event.Complete{
+		Message:      fmt.Sprintf("%d files changed", len(diffs)),
+		FilesChanged: len(diffs),
+	})
 
 	return nil
 }
 
+// collectDiffs diffs files in a single git run and returns the non-empty
+// diffs with their parsed form (renames and copies keyed by new path), with
+// intra-line spans and, with sideBySide, side-by-side rows. Parsed diffs of
+// oversized files are truncated; the raw text is kept whole.
+func collectDiffs(repo *git.Repository, files []string, sideBySide bool) (map[string]string, map[string]git.FileDiff, error) {
+	diffs := make(map[string]string)
+	changes := make(map[string]git.FileDiff)
+
+	fileDiffs, err := repo.GenerateFileDiffs(files)
+	if err != nil {
+		return diffs, changes, err
+	}
+	for _, file := range fileDiffs {
+		if file.Text == "" {
+			continue
+		}
+		log.Printf("  📄 %s (%s, %d bytes)", file.Path, file.Kind, len(file.Text))
+		diffs[file.Path] = file.Text
+		file.Truncate(git.MaxDiffLines)
+		file.Highlight()
+		if sideBySide {
+			file.AlignSideBySide()
+		}
+		changes[file.Path] = file
+	}
+	return diffs, changes, nil
+}
+
 // sendEvent sends an event to the mobile app via the event handler
-func (e *InteractiveTaskExecutor) sendEvent(event Event) {
+func (e *InteractiveTaskExecutor) sendEvent(ev event.Event) {
 	if e.onEvent != nil {
-		e.onEvent(event)
+		e.onEvent(ev)
 	}
 }
 
@@ -716,20 +822,18 @@ func (e *InteractiveTaskExecutor) DiscardChanges() error {
 func (e *InteractiveTaskExecutor) startNewTurn() {
 	e.filesModifiedThisTurn = make(map[string]bool)
 	e.turnCompleted = false
+	e.progress.resetActivity()
 	log.Println("🔄 Started new turn")
 }
 
 // sendCompleteEvent sends complete event (only once per turn)
-func (e *InteractiveTaskExecutor) sendCompleteEvent(message string) {
+func (e *InteractiveTaskExecutor) sendCompleteEvent(complete event.Complete) {
 	if e.turnCompleted {
 		log.Println("⏭️  Complete event already sent this turn")
 		return
 	}
 	e.turnCompleted = true
-	e.sendEvent(Event{
-		Type:    EventTypeComplete,
-		Content: json.RawMessage(fmt.Sprintf(`{"message":"%s"}`, message)),
-	})
+	e.sendEvent(event.New(event.TypeComplete, complete))
 	log.Println("✅ Sent complete event")
 }
 
@@ -741,10 +845,7 @@ func (e *InteractiveTaskExecutor) ContinueAfterApproval() error {
 	// Commit the changes
 	if err := e.CommitChanges("Apply changes via PocketVibe"); err != nil {
 		log.Printf("❌ Failed to commit changes: %v", err)
-		e.sendEvent(Event{
-			Type:    EventTypeError,
-			Content: json.RawMessage(fmt.Sprintf(`{"message":"Failed to commit: %s"}`, err.Error())),
-		})
+		e.sendEvent(event.Errorf("Failed to commit: %v", err))
 		return fmt.Errorf("failed to commit changes: %w", err)
 	}
 
@@ -759,9 +860,8 @@ func (e *InteractiveTaskExecutor) ContinueAfterApproval() error {
 // Stop stops the interactive executor and cleans up
 func (e *InteractiveTaskExecutor) Stop() error {
 	e.mutex.Lock()
-	defer e.mutex.Unlock()
-
 	if !e.isRunning {
+		e.mutex.Unlock()
 		return nil
 	}
 
@@ -771,55 +871,227 @@ func (e *InteractiveTaskExecutor) Stop() error {
 	if e.stdin != nil {
 		e.stdin.Close()
 	}
+	cmd := e.cmd
+	exited := e.exited
+	e.mutex.Unlock()
+	e.watchdog.disarm()
 
-	// Wait for process to exit (with timeout would be better in production)
-	if e.cmd != nil {
-		if err := e.cmd.Wait(); err != nil {
-			log.Printf("⚠️  Process exited with error: %v", err)
-		}
-	}
+	// Wait for the process to exit; streamOutput reaps it and reports failures
+	waitForExit(cmd, exited)
 
+	e.mutex.Lock()
 	e.isRunning = false
+	e.recorder.Close()
+	e.mutex.Unlock()
 	log.Println("✅ Interactive executor stopped")
 	return nil
 }
 
+// Abort stops the running task as if it had crossed a watchdog limit, e.g.
+// because the agent found its cost over budget
+func (e *InteractiveTaskExecutor) Abort(reason TimeoutReason, limit string) {
+	log.Printf("⏱️  Aborting task: %s limit (%s)", reason, limit)
+	e.watchdog.trip(reason, limit)
+}
+
+// IdleSince reports whether the CLI process is alive between turns, and since when
+func (e *InteractiveTaskExecutor) IdleSince() (time.Time, bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if !e.isRunning || e.turnActive || e.replayer != nil {
+		return time.Time{}, false
+	}
+	return e.lastActivity, true
+}
+
+// Busy reports whether a turn is in progress
+func (e *InteractiveTaskExecutor) Busy() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.isRunning && e.turnActive
+}
+
+// Suspend stops an idle CLI process to free its resources. The session is
+// kept, so the next Continue re-attaches to it with --resume. Returns false
+// if the process is busy or not running.
+func (e *InteractiveTaskExecutor) Suspend() bool {
+	e.mutex.Lock()
+	if !e.isRunning || e.turnActive || e.replayer != nil {
+		e.mutex.Unlock()
+		return false
+	}
+
+	log.Printf("💤 Suspending idle Claude process (session %s)", e.sessionID)
+	e.suspending = true
+	if e.stdin != nil {
+		e.stdin.Close()
+	}
+	cmd := e.cmd
+	exited := e.exited
+	e.mutex.Unlock()
+
+	waitForExit(cmd, exited)
+	return true
+}
+
+// waitForExit waits for the process to exit after its stdin was closed.
+// A CLI that ignores EOF is interrupted, then killed.
+func waitForExit(cmd *exec.Cmd, exited <-chan struct{}) {
+	if exited == nil {
+		return
+	}
+	select {
+	case <-exited:
+	case <-time.After(terminateGrace):
+		log.Println("⚠️  Claude didn't exit after stdin closed, interrupting...")
+		terminateProcess(cmd, exited)
+		<-exited
+	}
+}
+
+// Wait blocks until the current Claude process has exited and all of its
+// output has been handled. Returns immediately if nothing was started.
+func (e *InteractiveTaskExecutor) Wait() {
+	e.mutex.Lock()
+	exited := e.exited
+	e.mutex.Unlock()
+
+	if exited != nil {
+		<-exited
+	}
+}
+
 // ResumeSession resumes an existing Claude Code session by ID
 func (e *InteractiveTaskExecutor) ResumeSession(sessionID string, continuationPrompt string) error {
 	log.Printf("🔄 Resuming session: %s", sessionID)
 
+	e.setSessionID(sessionID)
 	e.startNewTurn()
+	e.failureReported = false
+	e.watchdog.arm()
 
 	// Capture files before resuming
 	filesBeforeExec, _ := e.git.DetectChangedFiles()
 	e.filesBeforeExec = filesBeforeExec
+	e.baselineRecordedFiles()
 
 	// Build resume command
 	// If we have a continuation prompt, use -p mode with --resume
 	// Otherwise use interactive mode with --continue
-	var cmd *exec.Cmd
-	if continuationPrompt != "" {
+	// Attachments need a stream-json message, so they keep stdin open
+	// instead, and so does a replay, which plays on when a message is sent
+	e.mutex.Lock()
+	hasAttachments := e.attachments != nil
+	e.mutex.Unlock()
+
+	// A print-mode run is one turn from its start; without a turn in
+	// progress the governor would take it for an idle process and suspend it
+	printMode := continuationPrompt != "" && !hasAttachments && e.replayer == nil
+	if printMode {
+		e.mutex.Lock()
+		e.turnActive = true
+		e.lastActivity = time.Now()
+		e.mutex.Unlock()
+	}
+
+	var args []string
+	if printMode {
 		// Print mode with resume - run the continuation prompt in the existing session
-		cmd = exec.Command("claude",
+		args = []string{
 			"-p", continuationPrompt,
 			"--resume", sessionID,
 			"--output-format", "stream-json",
 			"--verbose",
-			"--dangerously-skip-permissions")
+			"--dangerously-skip-permissions"}
 	} else {
 		// Interactive mode to continue the session
-		cmd = exec.Command("claude",
+		args = []string{
+			"--resume", sessionID,
+			"--input-format", "stream-json",
+			"--output-format", "stream-json",
+			"--verbose",
+			"--dangerously-skip-permissions"}
+	}
+
+	if err := e.startProcess(args); err != nil {
+		e.mutex.Lock()
+		e.turnActive = false
+		e.mutex.Unlock()
+		return fmt.Errorf("failed to resume session: %w", err)
+	}
+
+	if printMode {
+		// Recorded as a message, so a replay of the transcript waits for it
+		e.recorder.RecordStdin([]byte(continuationPrompt))
+	} else if continuationPrompt != "" {
+		if err := e.SendMessage(continuationPrompt); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("✅ Session %s resumed, waiting for output", sessionID)
+	return nil
+}
+
+// Continue sends a follow-up prompt as a new turn of the same Claude session.
+// The prompt goes to the live process when it is still running; otherwise the
+// session is resumed first. Files changed earlier in the conversation stay part
+// of its diffs, so a revision is reviewed together with what it revises.
+func (e *InteractiveTaskExecutor) Continue(prompt string) error {
+	e.mutex.Lock()
+	running := e.isRunning
+	sessionID := e.sessionID
+	e.mutex.Unlock()
+
+	e.startNewTurn()
+
+	if !running {
+		if sessionID == "" {
+			return fmt.Errorf("no Claude session to continue")
+		}
+
+		log.Printf("🔄 Claude process has exited - resuming session %s", sessionID)
+		if err := e.startProcess([]string{
 			"--resume", sessionID,
 			"--input-format", "stream-json",
 			"--output-format", "stream-json",
 			"--verbose",
-			"--dangerously-skip-permissions")
+			"--dangerously-skip-permissions"}); err != nil {
+			return fmt.Errorf("failed to resume session: %w", err)
+		}
+	}
+
+	return e.SendMessage(prompt)
+}
+
+// startProcess launches the Claude CLI with the given arguments (or starts the
+// replayer in its place) and begins streaming its output
+func (e *InteractiveTaskExecutor) startProcess(args []string) error {
+	if e.replayer != nil {
+		stdin, stdout, err := e.replayer.Start(e.projectPath)
+		if err != nil {
+			return fmt.Errorf("failed to start replay: %w", err)
+		}
+		e.stdin = stdin
+		e.isRunning = true
+		e.exited = make(chan struct{})
+		e.failureReported = false
+		go e.streamOutput(stdout, nil, e.exited)
+		return nil
+	}
+
+	if e.partialMessages {
+		args = append(args, "--include-partial-messages")
 	}
 
+	cmd := exec.Command("claude", args...)
 	cmd.Dir = e.projectPath
-	cmd.Env = os.Environ()
+	setProcessGroup(cmd)   // Lets the watchdog stop the CLI together with the tools it runs
+	cmd.Env = os.Environ() // Use existing environment (Claude Code subscription)
 
-	// Get pipes
+	// Get stdin, stdout, stderr pipes
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		return fmt.Errorf("failed to get stdin pipe: %w", err)
@@ -836,24 +1108,104 @@ func (e *InteractiveTaskExecutor) ResumeSession(sessionID string, continuationPr
 		return fmt.Errorf("failed to get stderr pipe: %w", err)
 	}
 
-	// Start command
+	// Start command, if the governor has room for it
+	if err := e.hooks.acquire(); err != nil {
+		return err
+	}
+	e.recorder.reopen()
 	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to resume session: %w", err)
+		e.hooks.release()
+		return ClassifyExit(err, "")
 	}
+	e.hooks.started(cmd.Process.Pid)
 
 	e.cmd = cmd
 	e.isRunning = true
+	e.exited = make(chan struct{})
+	e.failureReported = false
+	e.stderr.reset()
 
-	// Stream output (reuse existing methods)
-	go e.streamOutput(stdout)
-
+	// Stream stderr in goroutine, keeping the tail for failure classification
+	stderrDone := make(chan struct{})
 	go func() {
+		defer close(stderrDone)
 		scanner := bufio.NewScanner(stderr)
 		for scanner.Scan() {
 			log.Printf("Claude stderr: %s", scanner.Text())
+			e.stderr.add(scanner.Text())
+			e.recorder.RecordStderr(scanner.Text())
 		}
 	}()
 
-	log.Printf("✅ Session %s resumed, waiting for output", sessionID)
+	// Stream stdout in goroutine; it reaps the process once output ends
+	go e.streamOutput(stdout, func() error {
+		<-stderrDone // Wait must not be called before pipe reads finish
+		err := cmd.Wait()
+		e.hooks.release()
+		return err
+	}, e.exited)
+
 	return nil
 }
+
+// recordChangedFiles writes the current contents of every changed file
+// whose contents differ from when they were last recorded (or from before
+// the task, for files already dirty then) into the transcript
+func (e *InteractiveTaskExecutor) recordChangedFiles() {
+	if e.recorder == nil {
+		return
+	}
+
+	files, err := e.git.DetectChangedFiles()
+	if err != nil {
+		log.Printf("⚠️  Failed to detect files for transcript: %v", err)
+		return
+	}
+
+	if e.recordedFiles == nil {
+		e.recordedFiles = make(map[string]string)
+	}
+	for _, f := range files {
+		data, hash, err := e.readForTranscript(f)
+		if err != nil {
+			log.Printf("⚠️  Failed to read %s for transcript: %v", f, err)
+			continue
+		}
+		if recorded, ok := e.recordedFiles[f]; ok && recorded == hash {
+			continue
+		}
+		e.recordedFiles[f] = hash
+		e.recorder.RecordFile(f, data, data == nil)
+	}
+}
+
+// baselineRecordedFiles notes the contents of the files dirty before a task,
+// so recordChangedFiles only records them once Claude changes them
+func (e *InteractiveTaskExecutor) baselineRecordedFiles() {
+	if e.recorder == nil {
+		return
+	}
+	e.recordedFiles = make(map[string]string)
+	for _, f := range e.filesBeforeExec {
+		if _, hash, err := e.readForTranscript(f); err == nil {
+			e.recordedFiles[f] = hash
+		}
+	}
+}
+
+// readForTranscript reads a project file and hashes its contents; data is
+// nil and hash empty for a deleted file
+func (e *InteractiveTaskExecutor) readForTranscript(path string) ([]byte, string, error) {
+	data, err := os.ReadFile(filepath.Join(e.projectPath, path))
+	if os.IsNotExist(err) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if data == nil {
+		data = []byte{} // Empty, not deleted
+	}
+	sum := sha256.Sum256(data)
+	return data, hex.EncodeToString(sum[:]), nil
+}
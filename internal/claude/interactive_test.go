@@ -0,0 +1,46 @@
+package claude
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/getfinn/finn/internal/event"
+)
+
+// fakeCLI puts a claude executable running script first on PATH
+func fakeCLI(t *testing.T, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake CLI is a shell script")
+	}
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "claude"), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestResumeSessionPrintModeIsBusy(t *testing.T) {
+	fakeCLI(t, "exec sleep 30")
+
+	e := NewInteractiveTaskExecutor(t.TempDir(), func(event.Event) {})
+	if err := e.ResumeSession("session-1", "carry on"); err != nil {
+		t.Fatalf("ResumeSession: %v", err)
+	}
+	t.Cleanup(func() {
+		e.cmd.Process.Kill()
+		e.Wait()
+	})
+
+	if since, idle := e.IdleSince(); idle {
+		t.Errorf("IdleSince = %v, true; want a running -p resume to be busy", since)
+	}
+	if !e.Busy() {
+		t.Error("Busy = false during a -p resume")
+	}
+	if e.Suspend() {
+		t.Error("Suspend stopped a running -p resume")
+	}
+}
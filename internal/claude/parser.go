@@ -4,21 +4,15 @@ import (
 	"encoding/json"
 	"regexp"
 	"strings"
+
+	"github.com/getfinn/finn/internal/event"
 )
 
 // Decision represents a choice Claude is asking the user to make
-type Decision struct {
-	Question string   `json:"question"`
-	Context  string   `json:"context"`
-	Options  []Option `json:"options"`
-}
+type Decision = event.Decision
 
 // Option represents a single choice
-type Option struct {
-	ID          string `json:"id"`
-	Label       string `json:"label"`
-	Description string `json:"description"`
-}
+type Option = event.Option
 
 // DecisionParser extracts decisions from Claude's streaming output
 type DecisionParser struct {
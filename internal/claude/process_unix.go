@@ -0,0 +1,31 @@
+//go:build !windows
+
+package claude
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the CLI in its own process group so it can be
+// stopped together with the tools (shells, test runners) it spawned
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// interruptProcessGroup asks the CLI and its children to stop
+func interruptProcessGroup(cmd *exec.Cmd) {
+	if pgid, err := syscall.Getpgid(cmd.Process.Pid); err == nil {
+		syscall.Kill(-pgid, syscall.SIGINT)
+	} else {
+		cmd.Process.Signal(syscall.SIGINT)
+	}
+}
+
+// killProcessGroup kills the CLI and its children
+func killProcessGroup(cmd *exec.Cmd) {
+	if pgid, err := syscall.Getpgid(cmd.Process.Pid); err == nil {
+		syscall.Kill(-pgid, syscall.SIGKILL)
+	}
+	cmd.Process.Kill()
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package claude
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on Windows
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// interruptProcessGroup stops the CLI. Windows has no SIGINT for other
+// processes, so this kills it outright.
+func interruptProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
+
+// killProcessGroup kills the CLI
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
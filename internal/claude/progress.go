@@ -0,0 +1,181 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/getfinn/finn/internal/event"
+)
+
+// progressTracker builds structured progress from Claude's TodoWrite calls and
+// the sub-agents it spawns with the Task tool.
+type progressTracker struct {
+	mu         sync.Mutex
+	todos      []event.TodoItem
+	activities map[string]*activityNode // Task tool_use ID -> node
+	roots      []string                 // Top-level Task IDs in spawn order
+}
+
+// activityNode is a sub-agent in the activity tree
+type activityNode struct {
+	activity event.Activity
+	children []string
+}
+
+// handleToolUse records a tool_use block. parentID is the Task tool_use ID the
+// message belongs to ("" for the main agent). Returns true if progress changed.
+func (p *progressTracker) handleToolUse(parentID string, block MessageContentBlock) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	changed := false
+
+	// Count the tool against the sub-agent running it
+	if parent := p.activities[parentID]; parent != nil {
+		parent.activity.ToolCount++
+		parent.activity.LastTool = block.Name
+		changed = true
+	}
+
+	switch block.Name {
+	case "TodoWrite":
+		// Sub-agents keep their own checklists; only the main agent's is the task's progress
+		if parentID != "" {
+			return changed
+		}
+
+		var input struct {
+			Todos []struct {
+				Content    string `json:"content"`
+				Status     string `json:"status"`
+				ActiveForm string `json:"activeForm"`
+			} `json:"todos"`
+		}
+		if err := json.Unmarshal(block.Input, &input); err != nil {
+			return changed
+		}
+
+		p.todos = make([]event.TodoItem, 0, len(input.Todos))
+		for _, todo := range input.Todos {
+			p.todos = append(p.todos, event.TodoItem{
+				Content:    todo.Content,
+				Status:     todo.Status,
+				ActiveForm: todo.ActiveForm,
+			})
+		}
+		return true
+
+	case "Task":
+		if block.ID == "" {
+			return changed
+		}
+
+		var input struct {
+			Description  string `json:"description"`
+			SubagentType string `json:"subagent_type"`
+		}
+		_ = json.Unmarshal(block.Input, &input) // Missing fields just leave the node unlabelled
+
+		if p.activities == nil {
+			p.activities = make(map[string]*activityNode)
+		}
+		p.activities[block.ID] = &activityNode{
+			activity: event.Activity{
+				ID:          block.ID,
+				Description: input.Description,
+				AgentType:   input.SubagentType,
+				Status:      "running",
+			},
+		}
+
+		if parent := p.activities[parentID]; parent != nil {
+			parent.children = append(parent.children, block.ID)
+		} else {
+			p.roots = append(p.roots, block.ID)
+		}
+		return true
+	}
+
+	return changed
+}
+
+// handleToolResult marks a sub-agent finished when its Task call returns.
+// Returns true if progress changed.
+func (p *progressTracker) handleToolResult(block MessageContentBlock) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	node := p.activities[block.ToolUseID]
+	if node == nil {
+		return false
+	}
+
+	if block.IsError {
+		node.activity.Status = "failed"
+	} else {
+		node.activity.Status = "completed"
+	}
+	return true
+}
+
+// resetActivity clears the sub-agent tree (the checklist carries across turns)
+func (p *progressTracker) resetActivity() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.activities = nil
+	p.roots = nil
+}
+
+// snapshot returns the current progress as an event payload
+func (p *progressTracker) snapshot() event.Progress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	progress := event.Progress{
+		Items: append([]event.TodoItem(nil), p.todos...),
+		Total: len(p.todos),
+	}
+
+	for _, todo := range p.todos {
+		switch todo.Status {
+		case "completed":
+			progress.Completed++
+		case "in_progress":
+			if progress.Message == "" {
+				progress.Message = todo.ActiveForm
+				if progress.Message == "" {
+					progress.Message = todo.Content
+				}
+			}
+		}
+	}
+	if progress.Total > 0 {
+		progress.Percent = float64(progress.Completed) / float64(progress.Total) * 100
+	}
+
+	running := 0
+	for _, id := range p.roots {
+		progress.Activity = append(progress.Activity, p.buildActivity(id, &running))
+	}
+	if progress.Message == "" && running > 0 {
+		progress.Message = fmt.Sprintf("Running %d sub-agent(s)", running)
+	}
+
+	return progress
+}
+
+// buildActivity copies a node and its children into the event tree
+func (p *progressTracker) buildActivity(id string, running *int) event.Activity {
+	node := p.activities[id]
+	activity := node.activity
+	if activity.Status == "running" {
+		*running++
+	}
+
+	for _, childID := range node.children {
+		activity.Children = append(activity.Children, p.buildActivity(childID, running))
+	}
+	return activity
+}
@@ -0,0 +1,43 @@
+package claude
+
+// ProcessHooks let the owner of an executor account for the CLI processes it
+// spawns (see the agent's process governor). Every field is optional.
+type ProcessHooks struct {
+	Acquire func() error  // Called before spawning; an error refuses the spawn
+	Started func(pid int) // Called once the process is running
+	Release func()        // Called after the process exited (or failed to start)
+}
+
+// acquire reserves a process slot
+func (h ProcessHooks) acquire() error {
+	if h.Acquire == nil {
+		return nil
+	}
+	return h.Acquire()
+}
+
+// started reports the spawned process
+func (h ProcessHooks) started(pid int) {
+	if h.Started != nil {
+		h.Started(pid)
+	}
+}
+
+// release frees the slot taken by acquire
+func (h ProcessHooks) release() {
+	if h.Release != nil {
+		h.Release()
+	}
+}
+
+// ResourceCaps bound the memory and CPU of a CLI process and the tools it
+// runs. Zero disables a cap. Only enforced on Linux.
+type ResourceCaps struct {
+	MemoryMB   int // Memory limit in megabytes
+	CPUPercent int // CPU time as a percentage of one core (200 = two cores)
+}
+
+// IsZero reports whether no cap is set
+func (c ResourceCaps) IsZero() bool {
+	return c.MemoryMB <= 0 && c.CPUPercent <= 0
+}
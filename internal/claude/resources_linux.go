@@ -0,0 +1,179 @@
+//go:build linux
+
+package claude
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	cgroupRoot    = "/sys/fs/cgroup"
+	cpuMaxPeriod  = 100000 // cpu.max period in microseconds
+	bytesPerMB    = 1024 * 1024
+	cgroupPrefix  = "finn-claude-"
+	procCgroupV2  = "0::"
+	procStatusRSS = "VmRSS:"
+)
+
+// ApplyResourceCaps confines a running CLI process. It prefers a cgroup v2
+// group (memory.max and cpu.max, covering the tools Claude spawns later) next
+// to the daemon's own cgroup, which works under a systemd user session. When
+// that isn't possible the memory cap falls back to RLIMIT_DATA on the process
+// itself and the CPU cap is dropped. cleanup removes the cgroup once the
+// process has exited.
+func ApplyResourceCaps(pid int, caps ResourceCaps) (cleanup func(), err error) {
+	cleanup = func() {}
+	if caps.IsZero() {
+		return cleanup, nil
+	}
+
+	dir, cgErr := createCgroup(pid, caps)
+	if cgErr == nil {
+		return func() {
+			if err := os.Remove(dir); err != nil && !os.IsNotExist(err) {
+				log.Printf("⚠️  Failed to remove cgroup %s: %v", dir, err)
+			}
+		}, nil
+	}
+
+	if caps.MemoryMB <= 0 {
+		return cleanup, fmt.Errorf("cgroup v2 unavailable, CPU cap not applied: %w", cgErr)
+	}
+	limit := uint64(caps.MemoryMB) * bytesPerMB
+	if err := setRlimit(pid, syscall.RLIMIT_DATA, limit); err != nil {
+		return cleanup, fmt.Errorf("cgroup v2 unavailable (%v) and rlimit failed: %w", cgErr, err)
+	}
+	if caps.CPUPercent > 0 {
+		return cleanup, fmt.Errorf("cgroup v2 unavailable, only the memory rlimit was applied: %w", cgErr)
+	}
+	return cleanup, nil
+}
+
+// createCgroup moves pid into a new cgroup with the caps applied
+func createCgroup(pid int, caps ResourceCaps) (string, error) {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("cgroup v2 not mounted at %s", cgroupRoot)
+	}
+
+	own, err := ownCgroup()
+	if err != nil {
+		return "", err
+	}
+
+	// A sibling of our own group: a group holding processes can't delegate
+	// controllers to its children
+	parent := filepath.Join(cgroupRoot, filepath.Dir(own))
+	controllers, err := os.ReadFile(filepath.Join(parent, "cgroup.subtree_control"))
+	if err != nil {
+		return "", err
+	}
+	enabled := strings.Fields(string(controllers))
+	if caps.MemoryMB > 0 && !contains(enabled, "memory") {
+		return "", fmt.Errorf("memory controller not delegated to %s", parent)
+	}
+	if caps.CPUPercent > 0 && !contains(enabled, "cpu") {
+		return "", fmt.Errorf("cpu controller not delegated to %s", parent)
+	}
+
+	dir := filepath.Join(parent, cgroupPrefix+strconv.Itoa(pid))
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return "", err
+	}
+
+	fail := func(err error) (string, error) {
+		os.Remove(dir)
+		return "", err
+	}
+	if caps.MemoryMB > 0 {
+		limit := strconv.FormatInt(int64(caps.MemoryMB)*bytesPerMB, 10)
+		if err := os.WriteFile(filepath.Join(dir, "memory.max"), []byte(limit), 0644); err != nil {
+			return fail(err)
+		}
+	}
+	if caps.CPUPercent > 0 {
+		quota := fmt.Sprintf("%d %d", caps.CPUPercent*cpuMaxPeriod/100, cpuMaxPeriod)
+		if err := os.WriteFile(filepath.Join(dir, "cpu.max"), []byte(quota), 0644); err != nil {
+			return fail(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		return fail(err)
+	}
+
+	return dir, nil
+}
+
+// ownCgroup returns the daemon's cgroup v2 path, e.g. "/user.slice/.../app.slice/finn.scope"
+func ownCgroup() (string, error) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, procCgroupV2) {
+			path := strings.TrimPrefix(line, procCgroupV2)
+			if path == "/" {
+				return "", fmt.Errorf("daemon runs in the root cgroup")
+			}
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no cgroup v2 entry in /proc/self/cgroup")
+}
+
+// setRlimit sets both the soft and hard limit of resource for another process
+func setRlimit(pid int, resource int, limit uint64) error {
+	rlim := syscall.Rlimit{Cur: limit, Max: limit}
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64,
+		uintptr(pid), uintptr(resource), uintptr(unsafe.Pointer(&rlim)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// ProcessMemory returns the resident memory of a process in bytes (0 if unknown)
+func ProcessMemory(pid int) int64 {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, procStatusRSS) {
+			continue
+		}
+		// "VmRSS:	  123456 kB"
+		fields := strings.Fields(strings.TrimPrefix(line, procStatusRSS))
+		if len(fields) == 0 {
+			return 0
+		}
+		kb, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return 0
+		}
+		return kb * 1024
+	}
+	return 0
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package claude
+
+import "fmt"
+
+// ApplyResourceCaps is only supported on Linux
+func ApplyResourceCaps(pid int, caps ResourceCaps) (cleanup func(), err error) {
+	if caps.IsZero() {
+		return func() {}, nil
+	}
+	return func() {}, fmt.Errorf("resource caps are only supported on Linux")
+}
+
+// ProcessMemory is only supported on Linux
+func ProcessMemory(pid int) int64 {
+	return 0
+}
@@ -55,6 +55,11 @@ type MessageContentBlock struct {
 	Name  string          `json:"name,omitempty"`
 	ID    string          `json:"id,omitempty"`
 	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result blocks only
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   json.RawMessage `json:"content,omitempty"` // String or array of content blocks
+	IsError   bool            `json:"is_error,omitempty"`
 }
 
 // UsageInfo represents token usage information
@@ -75,14 +80,8 @@ func (sm *StoredMessage) ToStreamMessage() (*StreamMessage, error) {
 	// Parse the message field into our existing struct
 	if len(sm.Message) > 0 {
 		var parsed struct {
-			Content []struct {
-				Type  string          `json:"type"`
-				Text  string          `json:"text,omitempty"`
-				Name  string          `json:"name,omitempty"`
-				Input json.RawMessage `json:"input,omitempty"`
-				ID    string          `json:"id,omitempty"`
-			} `json:"content"`
-			StopReason string `json:"stop_reason,omitempty"`
+			Content    []MessageContentBlock `json:"content"`
+			StopReason string                `json:"stop_reason,omitempty"`
 		}
 
 		if err := json.Unmarshal(sm.Message, &parsed); err != nil {
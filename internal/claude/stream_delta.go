@@ -0,0 +1,127 @@
+package claude
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getfinn/finn/internal/event"
+)
+
+const (
+	// Text deltas are batched so mobile gets a few updates per second rather
+	// than one relay message per token.
+	deltaFlushInterval = 150 * time.Millisecond
+
+	// Flush early once this much text is buffered. Far below the relay's 512KB
+	// message limit even after JSON escaping.
+	deltaFlushBytes = 16 * 1024
+)
+
+// partialStreamEvent is the subset of an Anthropic API streaming event that
+// Claude Code forwards in "stream_event" messages
+type partialStreamEvent struct {
+	Type  string `json:"type"` // "message_start", "content_block_delta", "content_block_stop", "message_stop", ...
+	Index int    `json:"index"`
+	Delta struct {
+		Type string `json:"type"` // "text_delta", "input_json_delta", ...
+		Text string `json:"text"`
+	} `json:"delta"`
+}
+
+// deltaCoalescer batches streamed text deltas into thinking_delta events
+type deltaCoalescer struct {
+	mu    sync.Mutex
+	buf   strings.Builder
+	index int // Content block index of the buffered text
+	seq   int // Sequence number of the next event in this message
+	timer *time.Timer
+	emit  func(event.Event)
+}
+
+// handle processes a stream_event message
+func (c *deltaCoalescer) handle(raw json.RawMessage) {
+	var ev partialStreamEvent
+	if err := json.Unmarshal(raw, &ev); err != nil {
+		return
+	}
+
+	switch ev.Type {
+	case "message_start":
+		c.mu.Lock()
+		c.flushLocked()
+		c.seq = 0
+		c.mu.Unlock()
+
+	case "content_block_delta":
+		if ev.Delta.Type == "text_delta" && ev.Delta.Text != "" {
+			c.add(ev.Index, ev.Delta.Text)
+		}
+
+	case "content_block_stop", "message_stop":
+		c.flush()
+	}
+}
+
+// add buffers text for a content block, flushing when the block changes,
+// the buffer is full, or the flush interval elapses
+func (c *deltaCoalescer) add(index int, text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.buf.Len() > 0 && index != c.index {
+		c.flushLocked()
+	}
+	c.index = index
+
+	for text != "" {
+		chunk := truncateUTF8(text, deltaFlushBytes-c.buf.Len())
+		if chunk == "" {
+			// Not enough room left for the next rune
+			c.flushLocked()
+			continue
+		}
+		c.buf.WriteString(chunk)
+		text = text[len(chunk):]
+
+		if c.buf.Len() >= deltaFlushBytes {
+			c.flushLocked()
+		}
+	}
+
+	if c.buf.Len() > 0 && c.timer == nil {
+		c.timer = time.AfterFunc(deltaFlushInterval, c.flush)
+	}
+}
+
+// flush emits any buffered text
+func (c *deltaCoalescer) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushLocked()
+}
+
+// flushLocked emits buffered text; the caller holds c.mu. Emitting under the
+// lock keeps timer and stream flushes in order.
+func (c *deltaCoalescer) flushLocked() {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	if c.buf.Len() == 0 {
+		return
+	}
+
+	text := c.buf.String()
+	c.buf.Reset()
+
+	if c.emit != nil {
+		c.emit(event.New(event.TypeThinkingDelta, event.ThinkingDelta{
+			Index: c.index,
+			Seq:   c.seq,
+			Text:  text,
+		}))
+	}
+	c.seq++
+}
@@ -0,0 +1,242 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/getfinn/finn/internal/event"
+)
+
+const (
+	// Preview sent inline with tool_result events. Most of it is taken from the
+	// end of the output, where test failures and build errors usually are.
+	toolResultPreviewHead = 1024
+	toolResultPreviewTail = 3 * 1024
+
+	// Upper bound for a stored full output, kept well under the relay's
+	// message size limit so get_tool_output always fits in one message.
+	maxStoredToolOutput = 256 * 1024
+
+	// Number of full outputs kept for get_tool_output before the oldest are evicted
+	defaultToolOutputCapacity = 200
+)
+
+// exitCodePattern matches the exit status prefix Claude Code puts on failed shell commands
+var exitCodePattern = regexp.MustCompile(`^(?:Error: )?Exit code (\d+)`)
+
+// shellTools are tools whose results carry a process exit status
+var shellTools = map[string]bool{
+	"Bash": true,
+}
+
+// StoredToolOutput is the full output of a tool call, as kept by ToolOutputStore
+type StoredToolOutput struct {
+	Tool      string
+	Output    string
+	IsError   bool
+	Truncated bool // Output exceeded maxStoredToolOutput and was cut
+}
+
+// ToolOutputStore keeps the full output of recent tool calls so clients can
+// fetch what was truncated in the tool_result event. Entries are keyed by
+// tool_use ID, which is unique across sessions. Safe for concurrent use.
+type ToolOutputStore struct {
+	mu       sync.Mutex
+	outputs  map[string]StoredToolOutput
+	order    []string // Insertion order for eviction
+	capacity int
+}
+
+// NewToolOutputStore creates a store holding up to capacity outputs
+func NewToolOutputStore(capacity int) *ToolOutputStore {
+	if capacity <= 0 {
+		capacity = defaultToolOutputCapacity
+	}
+	return &ToolOutputStore{
+		outputs:  make(map[string]StoredToolOutput),
+		capacity: capacity,
+	}
+}
+
+// Put stores the full output for a tool call, evicting the oldest entry when full
+func (s *ToolOutputStore) Put(toolUseID string, output StoredToolOutput) {
+	if s == nil || toolUseID == "" {
+		return
+	}
+
+	if len(output.Output) > maxStoredToolOutput {
+		output.Output = truncateUTF8(output.Output, maxStoredToolOutput)
+		output.Truncated = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.outputs[toolUseID]; !exists {
+		s.order = append(s.order, toolUseID)
+	}
+	s.outputs[toolUseID] = output
+
+	for len(s.order) > s.capacity {
+		delete(s.outputs, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// Get returns the stored output for a tool call
+func (s *ToolOutputStore) Get(toolUseID string) (StoredToolOutput, bool) {
+	if s == nil {
+		return StoredToolOutput{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	output, ok := s.outputs[toolUseID]
+	return output, ok
+}
+
+// toolResultTracker pairs tool_result blocks with the tool_use that produced them
+type toolResultTracker struct {
+	mu        sync.Mutex
+	toolNames map[string]string // tool_use ID -> tool name
+	store     *ToolOutputStore
+}
+
+// trackToolUse remembers the tool name for a tool_use block
+func (t *toolResultTracker) trackToolUse(id, name string) {
+	if id == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.toolNames == nil {
+		t.toolNames = make(map[string]string)
+	}
+	t.toolNames[id] = name
+}
+
+// buildEvent converts a tool_result content block into a tool_result event,
+// storing the full output so it can be fetched later
+func (t *toolResultTracker) buildEvent(block MessageContentBlock) event.Event {
+	t.mu.Lock()
+	tool := t.toolNames[block.ToolUseID]
+	delete(t.toolNames, block.ToolUseID)
+	store := t.store
+	t.mu.Unlock()
+
+	output := toolResultText(block)
+	result := event.ToolResult{
+		ToolUseID:  block.ToolUseID,
+		Tool:       tool,
+		Output:     output,
+		IsError:    block.IsError,
+		ExitCode:   toolExitCode(tool, output, block.IsError),
+		TotalBytes: len(output),
+	}
+
+	if preview, truncated := previewToolOutput(output); truncated {
+		result.Output = preview
+		result.Truncated = true
+	}
+
+	if store != nil && block.ToolUseID != "" {
+		store.Put(block.ToolUseID, StoredToolOutput{
+			Tool:    tool,
+			Output:  output,
+			IsError: block.IsError,
+		})
+		result.OutputHandle = block.ToolUseID
+	}
+
+	return event.New(event.TypeToolResult, result)
+}
+
+// toolResultText extracts the text of a tool_result block. Content is either a
+// plain string or an array of content blocks; non-text blocks are summarised.
+func toolResultText(block MessageContentBlock) string {
+	if len(block.Content) == 0 {
+		return block.Text
+	}
+
+	var text string
+	if err := json.Unmarshal(block.Content, &text); err == nil {
+		return text
+	}
+
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(block.Content, &parts); err != nil {
+		return string(block.Content)
+	}
+
+	var sb strings.Builder
+	for i, part := range parts {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		if part.Type == "text" {
+			sb.WriteString(part.Text)
+		} else {
+			fmt.Fprintf(&sb, "[%s]", part.Type)
+		}
+	}
+	return sb.String()
+}
+
+// toolExitCode derives the exit status of a shell command from its result
+func toolExitCode(tool, output string, isError bool) *int {
+	if !shellTools[tool] {
+		return nil
+	}
+
+	if m := exitCodePattern.FindStringSubmatch(output); m != nil {
+		if code, err := strconv.Atoi(m[1]); err == nil {
+			return &code
+		}
+	}
+
+	if isError {
+		// Failed without a reported status (e.g. interrupted or timed out)
+		return nil
+	}
+
+	code := 0
+	return &code
+}
+
+// previewToolOutput shortens output to its head and tail for inline display
+func previewToolOutput(output string) (string, bool) {
+	if len(output) <= toolResultPreviewHead+toolResultPreviewTail {
+		return output, false
+	}
+
+	head := truncateUTF8(output, toolResultPreviewHead)
+	tail := output[len(output)-toolResultPreviewTail:]
+	for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+		tail = tail[1:]
+	}
+
+	omitted := len(output) - len(head) - len(tail)
+	return fmt.Sprintf("%s\n... [%d bytes omitted] ...\n%s", head, omitted, tail), true
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a rune
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
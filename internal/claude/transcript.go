@@ -0,0 +1,415 @@
+package claude
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TranscriptKind identifies what a transcript entry captured
+type TranscriptKind string
+
+const (
+	TranscriptStdout TranscriptKind = "stdout" // Raw stream-json line emitted by Claude
+	TranscriptStdin  TranscriptKind = "stdin"  // Message we wrote to Claude's stdin
+	TranscriptStderr TranscriptKind = "stderr" // Diagnostic output from the CLI
+	TranscriptFile   TranscriptKind = "file"   // File contents after Claude edited it
+	TranscriptExit   TranscriptKind = "exit"   // The CLI process exited; the next entries are a new process
+)
+
+// TranscriptEntry is a single line in a recorded transcript file
+type TranscriptEntry struct {
+	Offset  int64          `json:"t"` // Milliseconds since recording started
+	Kind    TranscriptKind `json:"kind"`
+	Line    string         `json:"line,omitempty"`    // stdout/stdin/stderr payload
+	Path    string         `json:"path,omitempty"`    // File path relative to the project (file entries)
+	Data    []byte         `json:"data,omitempty"`    // File contents (file entries)
+	Deleted bool           `json:"deleted,omitempty"` // File was removed (file entries)
+}
+
+// Recorder tees everything exchanged with the Claude CLI into a transcript file.
+// All methods are safe to call on a nil Recorder so executors can record unconditionally.
+type Recorder struct {
+	mu    sync.Mutex
+	path  string
+	file  *os.File // Nil while closed between processes
+	enc   *json.Encoder
+	start time.Time
+}
+
+// NewRecorder creates a transcript file at path (parent directories are
+// created). An existing transcript is never overwritten: a conversation
+// recorded again (resumed, started over, parked and resumed) goes to the
+// first free numbered variant, e.g. <conversation_id>-2.jsonl.
+func NewRecorder(path string) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create transcript dir: %w", err)
+	}
+
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for n := 2; ; n++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			path = fmt.Sprintf("%s-%d%s", base, n, ext)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create transcript: %w", err)
+		}
+
+		log.Printf("🎙️  Recording Claude transcript to %s", path)
+		return &Recorder{
+			path:  path,
+			file:  f,
+			enc:   json.NewEncoder(f),
+			start: time.Now(),
+		}, nil
+	}
+}
+
+// reopen resumes recording after Close, appending to the same transcript,
+// when the executor starts another process
+func (r *Recorder) reopen() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file != nil {
+		return
+	}
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		log.Printf("⚠️  Failed to reopen transcript %s: %v", r.path, err)
+		return
+	}
+	r.file = f
+	r.enc = json.NewEncoder(f)
+}
+
+// RecordStdout records a raw stdout line
+func (r *Recorder) RecordStdout(line string) {
+	r.write(TranscriptEntry{Kind: TranscriptStdout, Line: line})
+}
+
+// RecordStdin records a message written to Claude's stdin
+func (r *Recorder) RecordStdin(data []byte) {
+	r.write(TranscriptEntry{Kind: TranscriptStdin, Line: strings.TrimRight(string(data), "\n")})
+}
+
+// RecordStderr records a stderr line
+func (r *Recorder) RecordStderr(line string) {
+	r.write(TranscriptEntry{Kind: TranscriptStderr, Line: line})
+}
+
+// RecordFile records the current contents of a file Claude changed
+func (r *Recorder) RecordFile(path string, data []byte, deleted bool) {
+	r.write(TranscriptEntry{Kind: TranscriptFile, Path: path, Data: data, Deleted: deleted})
+}
+
+// RecordExit marks the end of a CLI process, so a replay of a conversation
+// that restarted or resumed the CLI ends each process where it ended
+func (r *Recorder) RecordExit() {
+	r.write(TranscriptEntry{Kind: TranscriptExit})
+}
+
+// Close flushes and closes the transcript file. Entries are dropped until
+// the recorder is reopened.
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// write appends an entry with the current offset
+func (r *Recorder) write(entry TranscriptEntry) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return
+	}
+	entry.Offset = time.Since(r.start).Milliseconds()
+	if err := r.enc.Encode(entry); err != nil {
+		log.Printf("⚠️  Failed to write transcript entry: %v", err)
+	}
+}
+
+// LoadTranscript reads all entries from a transcript file
+func LoadTranscript(path string) ([]TranscriptEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transcript: %w", err)
+	}
+	defer f.Close()
+
+	var entries []TranscriptEntry
+	scanner := bufio.NewScanner(f)
+	const maxCapacity = 16 * 1024 * 1024 // File snapshots can be large
+	scanner.Buffer(make([]byte, 0, 64*1024), maxCapacity)
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var entry TranscriptEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid transcript entry on line %d: %w", lineNum, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read transcript: %w", err)
+	}
+
+	return entries, nil
+}
+
+// Replayer plays a recorded transcript back in place of a live Claude process.
+// Stdout lines are emitted with their original spacing divided by speed, file
+// entries are written into the project folder, and playback pauses at each
+// recorded stdin entry until the executor actually sends a message, so
+// decisions and approvals flow exactly as they did during recording.
+//
+// Each Start plays one recorded process, up to its exit entry; the next
+// Start (a restart or resume of the session) continues with the process
+// recorded after it.
+type Replayer struct {
+	entries []TranscriptEntry
+	speed   float64 // 1 = original timing, 2 = twice as fast, 0 = no delays
+
+	mu   sync.Mutex
+	next int        // First entry not played yet
+	run  *replayRun // Current or last playback
+}
+
+// replayRun is the playback of one recorded process
+type replayRun struct {
+	input     chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// NewReplayer loads a transcript for playback
+func NewReplayer(path string, speed float64) (*Replayer, error) {
+	entries, err := LoadTranscript(path)
+	if err != nil {
+		return nil, err
+	}
+	if speed < 0 {
+		speed = 0
+	}
+
+	return &Replayer{
+		entries: entries,
+		speed:   speed,
+	}, nil
+}
+
+// Start plays the next recorded process and returns the pipes that stand in
+// for its stdin and stdout. Fails while a playback is running and once the
+// transcript has no more processes.
+func (r *Replayer) Start(projectPath string) (io.WriteCloser, io.Reader, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.run != nil {
+		select {
+		case <-r.run.done:
+		default:
+			return nil, nil, fmt.Errorf("transcript replay already running")
+		}
+	}
+	if r.next >= len(r.entries) {
+		return nil, nil, fmt.Errorf("the transcript has no more recorded Claude runs to replay")
+	}
+
+	run := &replayRun{
+		input:  make(chan struct{}, 64),
+		done:   make(chan struct{}),
+		closed: make(chan struct{}),
+	}
+	r.run = run
+	stdoutReader, stdoutWriter := io.Pipe()
+	go r.play(run, projectPath, stdoutWriter)
+
+	log.Printf("▶️  Replaying transcript (from entry %d of %d, speed %.1fx)", r.next+1, len(r.entries), r.speed)
+	return &replayStdin{run: run}, stdoutReader, nil
+}
+
+// Wait blocks until the current playback has finished
+func (r *Replayer) Wait() {
+	r.mu.Lock()
+	run := r.run
+	r.mu.Unlock()
+
+	if run != nil {
+		<-run.done
+	}
+}
+
+// play emits the entries of the next recorded process in order
+func (r *Replayer) play(run *replayRun, projectPath string, stdout *io.PipeWriter) {
+	defer close(run.done)
+	defer stdout.Close()
+
+	base := time.Now()
+	var baseOffset int64
+
+	r.mu.Lock()
+	i := r.next
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.next = i
+		r.mu.Unlock()
+	}()
+
+	for ; i < len(r.entries); i++ {
+		entry := r.entries[i]
+		if entry.Kind == TranscriptExit {
+			i++
+			log.Println("⏹️  Replayed process exited")
+			return
+		}
+
+		if entry.Kind == TranscriptStdin {
+			// Wait for the executor to send its message, then restart the clock
+			// so think time on the phone doesn't count against playback timing
+			select {
+			case <-run.input:
+			case <-run.closed:
+				i = r.skipProcess(i)
+				return
+			}
+			base = time.Now()
+			baseOffset = entry.Offset
+			continue
+		}
+
+		if !r.sleepUntil(run, base, entry.Offset-baseOffset) {
+			i = r.skipProcess(i)
+			return
+		}
+
+		switch entry.Kind {
+		case TranscriptStdout:
+			if _, err := io.WriteString(stdout, entry.Line+"\n"); err != nil {
+				return
+			}
+		case TranscriptStderr:
+			log.Printf("Claude stderr (replay): %s", entry.Line)
+		case TranscriptFile:
+			if err := applyTranscriptFile(projectPath, entry); err != nil {
+				log.Printf("⚠️  Failed to apply replayed file %s: %v", entry.Path, err)
+			}
+		}
+	}
+
+	log.Println("⏹️  Transcript replay finished")
+}
+
+// skipProcess returns the index of the first entry after the process that
+// entry i belongs to, where playback stopped early because the executor
+// closed stdin
+func (r *Replayer) skipProcess(i int) int {
+	for ; i < len(r.entries); i++ {
+		if r.entries[i].Kind == TranscriptExit {
+			return i + 1
+		}
+	}
+	return i
+}
+
+// sleepUntil waits until offset milliseconds (scaled by speed) have elapsed
+// since base. Returns false if playback was stopped.
+func (r *Replayer) sleepUntil(run *replayRun, base time.Time, offset int64) bool {
+	if r.speed == 0 || offset <= 0 {
+		return true
+	}
+
+	target := base.Add(time.Duration(float64(offset)/r.speed) * time.Millisecond)
+	wait := time.Until(target)
+	if wait <= 0 {
+		return true
+	}
+
+	select {
+	case <-time.After(wait):
+		return true
+	case <-run.closed:
+		return false
+	}
+}
+
+// applyTranscriptFile writes (or deletes) a recorded file inside the project
+func applyTranscriptFile(projectPath string, entry TranscriptEntry) error {
+	target := filepath.Join(projectPath, filepath.FromSlash(entry.Path))
+	rel, err := filepath.Rel(projectPath, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("path escapes project folder")
+	}
+
+	if entry.Deleted {
+		if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(target, entry.Data, 0644)
+}
+
+// replayStdin receives messages the executor would have written to Claude
+type replayStdin struct {
+	run *replayRun
+}
+
+// Write releases the replayer past the next recorded stdin entry
+func (s *replayStdin) Write(p []byte) (int, error) {
+	select {
+	case <-s.run.closed:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+
+	select {
+	case s.run.input <- struct{}{}:
+	case <-s.run.closed:
+		return 0, io.ErrClosedPipe
+	}
+	return len(p), nil
+}
+
+// Close stops playback of this process
+func (s *replayStdin) Close() error {
+	s.run.closeOnce.Do(func() { close(s.run.closed) })
+	return nil
+}
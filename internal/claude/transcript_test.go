@@ -0,0 +1,139 @@
+package claude
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/getfinn/finn/internal/event"
+)
+
+// recordingCLI answers each run with a short stream-json turn that creates
+// a file, like Claude editing the project. Interactive runs read their
+// prompt from stdin first; -p runs have it as an argument.
+const recordingCLI = `if [ "$1" != "-p" ]; then read -r line; fi
+n=$(ls turn*.txt 2>/dev/null | wc -l | tr -d ' ')
+echo "turn $n" > "turn$n.txt"
+echo '{"type":"system","subtype":"init","session_id":"session-1"}'
+echo '{"type":"assistant","message":{"id":"msg-'$n'","role":"assistant","content":[{"type":"text","text":"Writing turn'$n'.txt"}],"stop_reason":"end_turn"}}'
+echo '{"type":"result","subtype":"success","result":"done","session_id":"session-1","total_cost_usd":0.01,"usage":{"input_tokens":10,"output_tokens":5}}'`
+
+// newProject creates a git repository with one commit for a task to run in
+func newProject(t *testing.T) string {
+	t.Helper()
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "Finn Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Finn Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"init", "-q"}, {"add", "-A"}, {"commit", "-q", "-m", "Initial commit"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+		}
+	}
+	return dir
+}
+
+// eventLog collects the events an executor emits
+type eventLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *eventLog) handle(ev event.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, string(ev.Type)+" "+string(ev.Content))
+}
+
+func (l *eventLog) snapshot() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.events...)
+}
+
+// runConversation starts a task, then resumes its session with a -p
+// prompt, waiting for each process to exit
+func runConversation(t *testing.T, e *InteractiveTaskExecutor) {
+	t.Helper()
+	if err := e.ExecuteTask("first"); err != nil {
+		t.Fatalf("ExecuteTask: %v", err)
+	}
+	e.Wait()
+	if err := e.ResumeSession("session-1", "second"); err != nil {
+		t.Fatalf("ResumeSession: %v", err)
+	}
+	e.Wait()
+}
+
+func TestRecordReplayRoundTrip(t *testing.T) {
+	fakeCLI(t, recordingCLI)
+	transcript := filepath.Join(t.TempDir(), "conversation.jsonl")
+
+	// Record a live (fake) conversation
+	recordDir := newProject(t)
+	var recorded eventLog
+	recorder, err := NewRecorder(transcript)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	live := NewInteractiveTaskExecutor(recordDir, recorded.handle)
+	live.SetRecorder(recorder)
+	runConversation(t, live)
+
+	entries, err := LoadTranscript(transcript)
+	if err != nil {
+		t.Fatalf("LoadTranscript: %v", err)
+	}
+	kinds := map[TranscriptKind]int{}
+	for _, entry := range entries {
+		kinds[entry.Kind]++
+	}
+	if kinds[TranscriptStdin] != 2 || kinds[TranscriptExit] != 2 || kinds[TranscriptFile] < 2 {
+		t.Fatalf("transcript entries by kind = %v, want 2 stdin, 2 exit and the edited files", kinds)
+	}
+
+	// Replay it into a fresh copy of the project
+	replayDir := newProject(t)
+	var replayed eventLog
+	replayer, err := NewReplayer(transcript, 0)
+	if err != nil {
+		t.Fatalf("NewReplayer: %v", err)
+	}
+	replay := NewReplayTaskExecutor(replayDir, replayer, replayed.handle)
+	runConversation(t, replay)
+
+	want, got := recorded.snapshot(), replayed.snapshot()
+	if len(want) == 0 {
+		t.Fatal("the recorded conversation emitted no events")
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("replayed events differ from the recording\nrecorded:\n%s\nreplayed:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	for _, name := range []string{"turn0.txt", "turn1.txt"} {
+		data, err := os.ReadFile(filepath.Join(replayDir, name))
+		if err != nil {
+			t.Errorf("replay didn't write %s: %v", name, err)
+			continue
+		}
+		if want, _ := os.ReadFile(filepath.Join(recordDir, name)); string(data) != string(want) {
+			t.Errorf("replayed %s = %q, want %q", name, data, want)
+		}
+	}
+
+	// Nothing more was recorded, so a further turn can't be replayed
+	if err := replay.Continue("third"); err == nil || !strings.Contains(err.Error(), "no more recorded") {
+		t.Errorf("Continue after the transcript = %v, want a no more recorded runs error", err)
+	}
+}
@@ -0,0 +1,187 @@
+package claude
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/getfinn/finn/internal/event"
+)
+
+const (
+	// How often the watchdog checks its limits
+	watchdogInterval = time.Second
+
+	// How long an interrupted CLI gets to exit before its process group is killed
+	terminateGrace = 5 * time.Second
+)
+
+// Limits bound a single task (one prompt until its result). Zero disables a limit.
+type Limits struct {
+	MaxDuration time.Duration // Wall-clock time for the task
+	IdleTimeout time.Duration // Time without any output from the CLI
+	MaxTurns    int           // Assistant messages (model round trips) in the task
+}
+
+// TimeoutReason says which limit stopped a task
+type TimeoutReason string
+
+const (
+	TimeoutDuration TimeoutReason = "duration"
+	TimeoutIdle     TimeoutReason = "idle"
+	TimeoutMaxTurns TimeoutReason = "max_turns"
+	TimeoutBudget   TimeoutReason = "budget" // Tripped by the agent through an executor's Abort
+)
+
+// watchdog enforces Limits on a running task. It is armed when a task starts,
+// fed as output arrives, and calls onExpire (once) if a limit is crossed.
+type watchdog struct {
+	mu         sync.Mutex
+	limits     Limits
+	started    time.Time
+	lastOutput time.Time
+	turns      int
+	armed      bool
+	generation int // Bumped on every arm so a stale checker goroutine exits
+	onExpire   func(stats event.Timeout)
+}
+
+// arm starts watching a new task
+func (w *watchdog) arm() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.limits.MaxDuration == 0 && w.limits.IdleTimeout == 0 && w.limits.MaxTurns == 0 {
+		return
+	}
+
+	now := time.Now()
+	w.started = now
+	w.lastOutput = now
+	w.turns = 0
+	w.armed = true
+	w.generation++
+	go w.run(w.generation)
+}
+
+// disarm stops watching (task finished or process exited)
+func (w *watchdog) disarm() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.armed = false
+}
+
+// touch records output from the CLI
+func (w *watchdog) touch() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastOutput = time.Now()
+}
+
+// countTurn records an assistant message
+func (w *watchdog) countTurn() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.turns++
+}
+
+// trip stops the task for a reason decided outside the watchdog (e.g. a budget)
+func (w *watchdog) trip(reason TimeoutReason, limit string) {
+	w.mu.Lock()
+	stats := w.statsLocked(reason, limit)
+	w.armed = false
+	onExpire := w.onExpire
+	w.mu.Unlock()
+
+	if onExpire != nil {
+		onExpire(stats)
+	}
+}
+
+// run checks the limits until the watchdog is disarmed or re-armed
+func (w *watchdog) run(generation int) {
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		w.mu.Lock()
+		if !w.armed || w.generation != generation {
+			w.mu.Unlock()
+			return
+		}
+
+		now := time.Now()
+		var stats *event.Timeout
+		switch {
+		case w.limits.MaxDuration > 0 && now.Sub(w.started) >= w.limits.MaxDuration:
+			s := w.statsLocked(TimeoutDuration, w.limits.MaxDuration.String())
+			stats = &s
+		case w.limits.IdleTimeout > 0 && now.Sub(w.lastOutput) >= w.limits.IdleTimeout:
+			s := w.statsLocked(TimeoutIdle, w.limits.IdleTimeout.String())
+			stats = &s
+		case w.limits.MaxTurns > 0 && w.turns > w.limits.MaxTurns:
+			s := w.statsLocked(TimeoutMaxTurns, fmt.Sprintf("%d turns", w.limits.MaxTurns))
+			stats = &s
+		}
+
+		if stats == nil {
+			w.mu.Unlock()
+			continue
+		}
+
+		w.armed = false
+		onExpire := w.onExpire
+		w.mu.Unlock()
+
+		log.Printf("⏱️  Watchdog expired: %s limit (%s)", stats.Reason, stats.Limit)
+		if onExpire != nil {
+			onExpire(*stats)
+		}
+		return
+	}
+}
+
+// statsLocked snapshots the task's progress; the caller holds w.mu
+func (w *watchdog) statsLocked(reason TimeoutReason, limit string) event.Timeout {
+	now := time.Now()
+	stats := event.Timeout{
+		Reason: string(reason),
+		Limit:  limit,
+		Turns:  w.turns,
+	}
+	if !w.started.IsZero() {
+		stats.ElapsedMs = now.Sub(w.started).Milliseconds()
+		stats.IdleMs = now.Sub(w.lastOutput).Milliseconds()
+	}
+	return stats
+}
+
+// timeoutError describes a task the watchdog stopped
+func timeoutError(stats event.Timeout) *CLIError {
+	return &CLIError{
+		Code:    ErrorTimeout,
+		Message: fmt.Sprintf("Task stopped: exceeded the %s limit (%s)", stats.Reason, stats.Limit),
+		Action:  "Continue the conversation to pick up where it stopped, or raise the folder's limits",
+		Timeout: &stats,
+	}
+}
+
+// terminateProcess interrupts the CLI's process group and escalates to
+// SIGKILL if it hasn't exited within terminateGrace
+func terminateProcess(cmd *exec.Cmd, exited <-chan struct{}) {
+	if cmd == nil || cmd.Process == nil {
+		return
+	}
+
+	interruptProcessGroup(cmd)
+
+	select {
+	case <-exited:
+		log.Println("✅ Claude process exited after interrupt")
+	case <-time.After(terminateGrace):
+		log.Println("⚠️  Claude process didn't exit after interrupt, killing process group...")
+		killProcessGroup(cmd)
+	}
+}
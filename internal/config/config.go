@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	"github.com/google/uuid"
 	"github.com/getfinn/finn/internal/subscription"
@@ -14,6 +15,7 @@ import (
 type ExecutionMode struct {
 	InteractiveMode  bool   `json:"interactiveMode"`     // Enable interactive mode with decisions
 	DiffApprovalMode string `json:"diff_approval_mode"` // "show-all", "show-on-error", "auto-approve"
+	SideBySideDiffs  bool   `json:"side_by_side_diffs,omitempty"` // Add side-by-side rows to parsed diffs
 }
 
 // Config holds the daemon's configuration
@@ -27,15 +29,69 @@ type Config struct {
 	SelectedFolderID string                      `json:"selected_folder_id"`
 	Subscription     *subscription.Subscription  `json:"subscription"`
 	ExecutionMode    ExecutionMode               `json:"execution_mode"`
+
+	// DisableTokenStreaming turns off partial-message streaming (thinking_delta
+	// events), for Claude Code versions without --include-partial-messages
+	DisableTokenStreaming bool `json:"disable_token_streaming,omitempty"`
+
+	// TaskLimits bounds every task; a folder's own Limits take precedence
+	TaskLimits TaskLimits `json:"task_limits,omitempty"`
+
+	// ProcessLimits bounds the Claude CLI processes the daemon keeps running
+	ProcessLimits ProcessLimits `json:"process_limits,omitempty"`
+
+	// CommitTrailers configures the trailers added to commits made on approval
+	CommitTrailers CommitTrailers `json:"commit_trailers,omitempty"`
+
+	// AllowNoVerify lets a commit blocked by a hook be retried with --no-verify
+	AllowNoVerify bool `json:"allow_no_verify,omitempty"`
+
+	// Transcript record/replay (not saved: determined at runtime from env vars)
+	RecordDir        string  `json:"-"` // FINN_RECORD_DIR: record every Claude conversation here
+	ReplayTranscript string  `json:"-"` // FINN_REPLAY_TRANSCRIPT: replay this transcript instead of running Claude
+	ReplaySpeed      float64 `json:"-"` // FINN_REPLAY_SPEED: playback speed multiplier (0 = no delays)
 }
 
 // Folder represents an approved project folder
 type Folder struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-	Path string `json:"path"`
+	ID     string      `json:"id"`
+	Name   string      `json:"name"`
+	Path   string      `json:"path"`
+	Limits *TaskLimits `json:"limits,omitempty"` // Overrides Config.TaskLimits for this folder
+	Budget *Budget     `json:"budget,omitempty"` // Cost caps for each conversation in this folder
 }
 
+// Budget caps the cumulative Claude cost of a conversation. Zero disables a cap.
+type Budget struct {
+	SoftUSD float64 `json:"soft_usd,omitempty"` // Warn once the conversation costs this much
+	HardUSD float64 `json:"hard_usd,omitempty"` // Stop the running task once it costs this much
+}
+
+// CommitTrailers configures the trailers linking approval commits to the
+// conversation and Claude session that produced them. Both links are on by
+// default.
+type CommitTrailers struct {
+	Disabled            bool     `json:"disabled,omitempty"`             // Add no trailers at all
+	DisableConversation bool     `json:"disable_conversation,omitempty"` // Omit Finn-Conversation
+	DisableSession      bool     `json:"disable_session,omitempty"`      // Omit Claude-Session
+	CoAuthors           []string `json:"co_authors,omitempty"`           // Co-authored-by values, "Name <email>"
+}
+
+// TaskLimits bounds a single Claude task. Zero means "use the default",
+// a negative value disables the limit.
+type TaskLimits struct {
+	MaxDurationMinutes int `json:"max_duration_minutes,omitempty"` // Wall-clock time per task
+	IdleTimeoutMinutes int `json:"idle_timeout_minutes,omitempty"` // Time without output from Claude
+	MaxTurns           int `json:"max_turns,omitempty"`            // Assistant turns per task
+}
+
+// Default task limits. Idle covers long silent tool runs (Bash caps at 10 minutes).
+const (
+	DefaultMaxDurationMinutes = 60
+	DefaultIdleTimeoutMinutes = 15
+	DefaultMaxTurns           = -1 // Unlimited
+)
+
 // GetToken retrieves the authentication token for the given relay URL
 // Returns empty string if no token exists for this relay
 func (c *Config) GetToken(relayURL string) string {
@@ -160,6 +216,18 @@ func (c *Config) migrateAuthToken() bool {
 func (c *Config) applyEnvironmentOverrides(dev bool) {
 	// Always determine relay URL from environment (never use saved value)
 	c.RelayURL = getDefaultRelayURL(dev)
+
+	// Transcript recording and replay for offline testing
+	c.RecordDir = os.Getenv("FINN_RECORD_DIR")
+	c.ReplayTranscript = os.Getenv("FINN_REPLAY_TRANSCRIPT")
+	c.ReplaySpeed = 1
+	if speed := os.Getenv("FINN_REPLAY_SPEED"); speed != "" {
+		if parsed, err := strconv.ParseFloat(speed, 64); err == nil {
+			c.ReplaySpeed = parsed
+		} else {
+			fmt.Printf("Warning: invalid FINN_REPLAY_SPEED %q, using 1\n", speed)
+		}
+	}
 }
 
 // Save saves the configuration to disk
@@ -205,13 +273,20 @@ func createDefaultConfig(dev bool) (*Config, error) {
 		return nil, err
 	}
 
+	cfg.applyEnvironmentOverrides(dev)
 	return cfg, nil
 }
 
 // getConfigPath returns the path to the config file
 func getConfigPath() string {
+	return filepath.Join(Dir(), "config.json")
+}
+
+// Dir returns the daemon's data directory (~/.finn), which holds the config
+// and other state that must survive restarts
+func Dir() string {
 	homeDir, _ := os.UserHomeDir()
-	return filepath.Join(homeDir, ".finn", "config.json")
+	return filepath.Join(homeDir, ".finn")
 }
 
 // generateDeviceID generates a unique device ID
@@ -343,6 +418,86 @@ func (c *Config) GetFolderByID(id string) *Folder {
 	return nil
 }
 
+// ProcessLimits bounds the Claude CLI processes across all conversations.
+// Zero means "use the default", a negative value disables the limit.
+type ProcessLimits struct {
+	MaxConcurrent   int `json:"max_concurrent,omitempty"`    // CLI processes alive at once
+	IdleReapMinutes int `json:"idle_reap_minutes,omitempty"` // Stop a finished conversation's process after this long
+	MemoryMB        int `json:"memory_mb,omitempty"`         // Per-process memory cap (Linux only, off by default)
+	CPUPercent      int `json:"cpu_percent,omitempty"`       // Per-process CPU cap in % of a core (Linux only, off by default)
+}
+
+// Default process limits
+const (
+	DefaultMaxConcurrent   = 4
+	DefaultIdleReapMinutes = 10
+)
+
+// EffectiveProcessLimits returns the process limits with defaults filled in.
+// Disabled limits are returned as 0.
+func (c *Config) EffectiveProcessLimits() ProcessLimits {
+	limits := c.ProcessLimits
+	if limits.MaxConcurrent == 0 {
+		limits.MaxConcurrent = DefaultMaxConcurrent
+	}
+	if limits.IdleReapMinutes == 0 {
+		limits.IdleReapMinutes = DefaultIdleReapMinutes
+	}
+
+	for _, v := range []*int{&limits.MaxConcurrent, &limits.IdleReapMinutes, &limits.MemoryMB, &limits.CPUPercent} {
+		if *v < 0 {
+			*v = 0
+		}
+	}
+	return limits
+}
+
+// LimitsForFolder returns the effective task limits for the folder at path:
+// the folder's own limits, then the global ones, then the defaults.
+// Disabled limits are returned as 0.
+func (c *Config) LimitsForFolder(path string) TaskLimits {
+	limits := TaskLimits{
+		MaxDurationMinutes: DefaultMaxDurationMinutes,
+		IdleTimeoutMinutes: DefaultIdleTimeoutMinutes,
+		MaxTurns:           DefaultMaxTurns,
+	}
+	limits.merge(c.TaskLimits)
+	for _, f := range c.ApprovedFolders {
+		if f.Path == path && f.Limits != nil {
+			limits.merge(*f.Limits)
+			break
+		}
+	}
+
+	for _, v := range []*int{&limits.MaxDurationMinutes, &limits.IdleTimeoutMinutes, &limits.MaxTurns} {
+		if *v < 0 {
+			*v = 0
+		}
+	}
+	return limits
+}
+
+// merge overrides the limits that other sets
+func (l *TaskLimits) merge(other TaskLimits) {
+	if other.MaxDurationMinutes != 0 {
+		l.MaxDurationMinutes = other.MaxDurationMinutes
+	}
+	if other.IdleTimeoutMinutes != 0 {
+		l.IdleTimeoutMinutes = other.IdleTimeoutMinutes
+	}
+	if other.MaxTurns != 0 {
+		l.MaxTurns = other.MaxTurns
+	}
+}
+
+// BudgetForFolder returns the budget of a folder (zero if none is set)
+func (c *Config) BudgetForFolder(id string) Budget {
+	if folder := c.GetFolderByID(id); folder != nil && folder.Budget != nil {
+		return *folder.Budget
+	}
+	return Budget{}
+}
+
 // IsFolderApproved checks if a folder is approved
 func (c *Config) IsFolderApproved(path string) bool {
 	for _, f := range c.ApprovedFolders {
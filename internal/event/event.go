@@ -0,0 +1,205 @@
+// Package event defines the event model shared by every LLM provider.
+//
+// Executors report progress by emitting Events. Each event carries a Type and
+// a JSON-encoded payload whose shape is fixed per type (see the payload structs
+// below). The agent forwards events to mobile/web clients verbatim, so these
+// structs are also the wire contract for the "data" field of execution messages.
+//
+// Version is bumped whenever a payload changes incompatibly so clients can
+// detect events they don't understand.
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/getfinn/finn/internal/git"
+)
+
+// Version is the current event schema version
+const Version = 1
+
+// Type identifies the kind of event
+type Type string
+
+const (
+	TypeThinking      Type = "thinking"       // Assistant text (complete block)
+	TypeThinkingDelta Type = "thinking_delta" // Partial assistant text while it streams
+	TypeToolUse       Type = "tool_use"       // Claude invoked a tool
+	TypeToolResult    Type = "tool_result"    // A tool finished and returned output
+	TypeDecision      Type = "decision"       // User input required (question or plan approval)
+	TypeDiff          Type = "diff"           // File changes ready for review
+	TypeUsage         Type = "usage"          // Token usage and cost
+	TypeProgress      Type = "progress"       // Structured task progress
+	TypeComplete      Type = "complete"       // Turn or task finished
+	TypeError         Type = "error"          // Something went wrong
+)
+
+// Event is a single typed event emitted during task execution
+type Event struct {
+	Version int             `json:"version"`
+	Type    Type            `json:"type"`
+	Content json.RawMessage `json:"content"`
+}
+
+// Handler is called for each event during execution
+type Handler func(Event)
+
+// New builds an event of the given type from a payload struct
+func New(t Type, payload interface{}) Event {
+	content, err := json.Marshal(payload)
+	if err != nil {
+		// Payloads are plain structs, so this only happens on programmer error.
+		// Degrade to an error event rather than emitting invalid JSON.
+		content, _ = json.Marshal(Error{Message: fmt.Sprintf("failed to encode %s event: %v", t, err)})
+		t = TypeError
+	}
+
+	return Event{
+		Version: Version,
+		Type:    t,
+		Content: content,
+	}
+}
+
+// Decode unmarshals the event content into the payload struct for its type
+func (e Event) Decode(payload interface{}) error {
+	if err := json.Unmarshal(e.Content, payload); err != nil {
+		return fmt.Errorf("failed to decode %s event: %w", e.Type, err)
+	}
+	return nil
+}
+
+// Errorf builds an error event with a formatted message
+func Errorf(format string, args ...interface{}) Event {
+	return New(TypeError, Error{Message: fmt.Sprintf(format, args...)})
+}
+
+// Thinking is the payload for TypeThinking
+type Thinking struct {
+	Text string `json:"text"`
+}
+
+// ThinkingDelta is the payload for TypeThinkingDelta. Deltas for a block are
+// followed by a Thinking event with the full text, which supersedes them.
+type ThinkingDelta struct {
+	Index int    `json:"index"` // Content block index within the assistant message
+	Seq   int    `json:"seq"`   // Increments per delta within a message, for ordering
+	Text  string `json:"text"`
+}
+
+// ToolUse is the payload for TypeToolUse
+type ToolUse struct {
+	ID    string          `json:"id,omitempty"` // tool_use block ID (pairs with ToolResult.ToolUseID)
+	Tool  string          `json:"tool"`
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+// ToolResult is the payload for TypeToolResult
+type ToolResult struct {
+	ToolUseID    string `json:"tool_use_id"`
+	Tool         string `json:"tool,omitempty"`
+	Output       string `json:"output"` // Possibly truncated; see Truncated
+	IsError      bool   `json:"is_error"`
+	ExitCode     *int   `json:"exit_code,omitempty"` // Set for shell commands when known
+	Truncated    bool   `json:"truncated,omitempty"`
+	TotalBytes   int    `json:"total_bytes"`
+	OutputHandle string `json:"output_handle,omitempty"` // Pass to get_tool_output to fetch the full text
+}
+
+// Option is a single choice in a Decision
+type Option struct {
+	ID          string `json:"id"`
+	Label       string `json:"label"`
+	Description string `json:"description"`
+}
+
+// Decision is the payload for TypeDecision
+type Decision struct {
+	Question     string   `json:"question"`
+	Context      string   `json:"context"`
+	Options      []Option `json:"options"`
+	DecisionType string   `json:"decision_type,omitempty"` // "question" for AskUserQuestion
+}
+
+// Diff is the payload for TypeDiff
+type Diff struct {
+	Diffs            map[string]string       `json:"diffs"`           // file path -> unified diff
+	Files            map[string]git.FileDiff `json:"files,omitempty"` // file path -> parsed diff (status, hunks, line counts)
+	FilesChanged     int                     `json:"files_changed"`
+	RequiresApproval *bool                   `json:"requires_approval,omitempty"` // Set by one-shot executors
+}
+
+// Usage is the payload for TypeUsage
+type Usage struct {
+	InputTokens              int     `json:"input_tokens"`
+	OutputTokens             int     `json:"output_tokens"`
+	CacheReadInputTokens     int     `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int     `json:"cache_creation_input_tokens"`
+	Model                    string  `json:"model,omitempty"`
+	MessageID                string  `json:"message_id,omitempty"` // Same for every block of one API message
+	CostUSD                  float64 `json:"cost_usd,omitempty"`
+	DurationMs               int64   `json:"duration_ms,omitempty"`
+	IsFinal                  bool    `json:"is_final,omitempty"` // Aggregated totals from the result message
+}
+
+// Progress is the payload for TypeProgress. Each event is a full snapshot of
+// the task checklist and sub-agent activity, so clients can simply replace
+// their previous state.
+type Progress struct {
+	Message   string     `json:"message,omitempty"`
+	Percent   float64    `json:"percent"`
+	Items     []TodoItem `json:"items,omitempty"`    // Checklist from Claude's TodoWrite tool
+	Completed int        `json:"completed"`          // Items with status "completed"
+	Total     int        `json:"total"`              // Number of items
+	Activity  []Activity `json:"activity,omitempty"` // Sub-agents spawned with the Task tool
+}
+
+// TodoItem is a single checklist entry in a Progress event
+type TodoItem struct {
+	Content    string `json:"content"`
+	Status     string `json:"status"` // "pending", "in_progress", "completed"
+	ActiveForm string `json:"active_form,omitempty"`
+}
+
+// Activity is a sub-agent in a Progress event's activity tree
+type Activity struct {
+	ID          string     `json:"id"` // tool_use ID of the Task call
+	Description string     `json:"description"`
+	AgentType   string     `json:"agent_type,omitempty"`
+	Status      string     `json:"status"` // "running", "completed", "failed"
+	LastTool    string     `json:"last_tool,omitempty"`
+	ToolCount   int        `json:"tool_count"`
+	Children    []Activity `json:"children,omitempty"` // Sub-agents spawned by this one
+}
+
+// Complete is the payload for TypeComplete
+type Complete struct {
+	Message      string `json:"message,omitempty"`
+	FilesChanged int    `json:"files_changed"`
+	AutoApproved bool   `json:"auto_approved,omitempty"`
+	Committed    bool   `json:"committed,omitempty"`
+	Pushed       bool   `json:"pushed,omitempty"`
+	Discarded    bool   `json:"discarded,omitempty"`
+}
+
+// Error is the payload for TypeError. Failures of the Claude CLI are
+// classified with a Code and a suggested Action; other errors carry only Message.
+type Error struct {
+	Message  string     `json:"message"`
+	Code     string     `json:"code,omitempty"`      // e.g. "not_logged_in", "usage_limit"
+	Action   string     `json:"action,omitempty"`    // Suggested remediation for the user
+	ResetsAt *time.Time `json:"resets_at,omitempty"` // When a usage limit resets
+	Detail   string     `json:"detail,omitempty"`    // Raw CLI output behind the classification
+	Timeout  *Timeout   `json:"timeout,omitempty"`   // Set when the watchdog stopped the task
+}
+
+// Timeout describes a task stopped by the watchdog, in an Error event
+type Timeout struct {
+	Reason    string `json:"reason"` // "duration", "idle", "max_turns", "budget"
+	Limit     string `json:"limit"`  // The limit that was crossed, human-readable
+	ElapsedMs int64  `json:"elapsed_ms"`
+	IdleMs    int64  `json:"idle_ms"` // Time since the CLI last produced output
+	Turns     int    `json:"turns"`
+}
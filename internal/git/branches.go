@@ -0,0 +1,211 @@
+package git
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Branch is a local or remote-tracking branch
+type Branch struct {
+	Name       string       `json:"name"`               // e.g. "main" or "origin/main"
+	Remote     bool         `json:"remote"`             // Remote-tracking branch
+	Current    bool         `json:"current"`            // Checked out
+	Upstream   string       `json:"upstream,omitempty"` // Tracked branch of a local branch
+	Ahead      int          `json:"ahead"`              // Commits not on the upstream
+	Behind     int          `json:"behind"`             // Upstream commits not on the branch
+	Gone       bool         `json:"gone,omitempty"`     // Upstream was deleted
+	LastCommit BranchCommit `json:"last_commit"`
+}
+
+// BranchCommit is the commit a branch points at
+type BranchCommit struct {
+	Hash      string `json:"hash"`
+	FullHash  string `json:"full_hash"`
+	Message   string `json:"message"`
+	Author    string `json:"author"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// DirtyTreeError is returned when an operation would lose uncommitted changes
+type DirtyTreeError struct {
+	Files []string
+}
+
+func (e *DirtyTreeError) Error() string {
+	return fmt.Sprintf("%d file(s) have uncommitted changes", len(e.Files))
+}
+
+// branchFormat is the for-each-ref format parsed by ListBranches
+const branchFormat = "%(HEAD)%00%(refname)%00%(refname:short)%00%(upstream:short)%00%(upstream:track)%00%(objectname:short)%00%(objectname)%00%(contents:subject)%00%(authorname)%00%(committerdate:unix)"
+
+// ListBranches returns the local branches followed by the remote-tracking
+// ones, each sorted by name
+func (r *Repository) ListBranches() ([]Branch, error) {
+	output, err := r.run("for-each-ref", "--format="+branchFormat, "refs/heads", "refs/remotes")
+	if err != nil {
+		return nil, err
+	}
+
+	branches := []Branch{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 10 {
+			continue
+		}
+		// origin/HEAD only points at another remote branch
+		if strings.HasPrefix(fields[1], "refs/remotes/") && strings.HasSuffix(fields[1], "/HEAD") {
+			continue
+		}
+
+		timestamp, _ := strconv.ParseInt(fields[9], 10, 64)
+		branch := Branch{
+			Name:     fields[2],
+			Remote:   strings.HasPrefix(fields[1], "refs/remotes/"),
+			Current:  fields[0] == "*",
+			Upstream: fields[3],
+			LastCommit: BranchCommit{
+				Hash:      fields[5],
+				FullHash:  fields[6],
+				Message:   fields[7],
+				Author:    fields[8],
+				Timestamp: timestamp,
+			},
+		}
+		branch.Ahead, branch.Behind, branch.Gone = parseTrack(fields[4])
+		branches = append(branches, branch)
+	}
+
+	return branches, nil
+}
+
+// parseTrack parses an upstream:track value like "[ahead 2, behind 1]" or "[gone]"
+func parseTrack(track string) (ahead, behind int, gone bool) {
+	track = strings.Trim(track, "[]")
+	for _, part := range strings.Split(track, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), " ")
+		switch key {
+		case "ahead":
+			ahead, _ = strconv.Atoi(value)
+		case "behind":
+			behind, _ = strconv.Atoi(value)
+		case "gone":
+			gone = true
+		}
+	}
+	return ahead, behind, gone
+}
+
+// ValidateBranchName checks name is a valid new branch name
+func (r *Repository) ValidateBranchName(name string) error {
+	if name == "" || strings.HasPrefix(name, "-") {
+		return fmt.Errorf("invalid branch name %q", name)
+	}
+	if _, err := r.run("check-ref-format", "--branch", name); err != nil {
+		return fmt.Errorf("invalid branch name %q", name)
+	}
+	return nil
+}
+
+// BranchExists reports whether a local branch called name exists
+func (r *Repository) BranchExists(name string) bool {
+	_, err := r.run("show-ref", "--verify", "--quiet", "refs/heads/"+name)
+	return err == nil
+}
+
+// CreateBranch creates a branch at startPoint (HEAD if empty), checking it
+// out if checkout is true. Checking out keeps uncommitted changes, which
+// carry over to a new branch without loss.
+func (r *Repository) CreateBranch(name, startPoint string, checkout bool) error {
+	if err := r.ValidateBranchName(name); err != nil {
+		return err
+	}
+	if r.BranchExists(name) {
+		return fmt.Errorf("branch %q already exists", name)
+	}
+	if strings.HasPrefix(startPoint, "-") {
+		return fmt.Errorf("invalid start point %q", startPoint)
+	}
+
+	args := []string{"branch", name}
+	if checkout {
+		args = []string{"switch", "-c", name}
+	}
+	if startPoint != "" {
+		args = append(args, startPoint)
+	}
+	_, err := r.run(args...)
+	return err
+}
+
+// SwitchBranch checks out a branch. A remote-only branch ("feature" or
+// "origin/feature") gets a local tracking branch. With uncommitted changes it
+// returns a *DirtyTreeError, unless stash is true: then the changes are
+// stashed first and the stash message is returned.
+func (r *Repository) SwitchBranch(name string, stash bool) (string, error) {
+	if name == "" || strings.HasPrefix(name, "-") {
+		return "", fmt.Errorf("invalid branch name %q", name)
+	}
+
+	files, err := r.DetectChangedFiles()
+	if err != nil {
+		return "", err
+	}
+
+	stashMessage := ""
+	if len(files) > 0 {
+		if !stash {
+			return "", &DirtyTreeError{Files: files}
+		}
+		current, _ := r.GetCurrentBranch()
+		stashMessage = fmt.Sprintf("finn: switching from %s to %s", current, name)
+		if _, err := r.run("stash", "push", "--include-untracked", "-m", stashMessage); err != nil {
+			return "", err
+		}
+	}
+
+	args := []string{"switch", name}
+	if !r.BranchExists(name) && r.isRemoteBranch(name) {
+		args = []string{"switch", "--track", name}
+	}
+	if _, err := r.run(args...); err != nil {
+		if stashMessage != "" {
+			// Put the changes back where they were
+			if _, popErr := r.run("stash", "pop"); popErr != nil {
+				return "", fmt.Errorf("%v (changes are kept in stash %q)", err, stashMessage)
+			}
+		}
+		return "", err
+	}
+
+	return stashMessage, nil
+}
+
+// isRemoteBranch reports whether name is a remote-tracking branch like "origin/main"
+func (r *Repository) isRemoteBranch(name string) bool {
+	_, err := r.run("show-ref", "--verify", "--quiet", "refs/remotes/"+name)
+	return err == nil
+}
+
+// DeleteBranch deletes a local branch. Commits not merged into HEAD or the
+// branch's upstream would be lost, so such a branch is only deleted with force.
+func (r *Repository) DeleteBranch(name string, force bool) error {
+	if !r.BranchExists(name) {
+		return fmt.Errorf("branch %q not found", name)
+	}
+	if current, _ := r.GetCurrentBranch(); current == name {
+		return fmt.Errorf("can't delete the checked-out branch %q", name)
+	}
+
+	flag := "-d"
+	if force {
+		flag = "-D"
+	}
+	if _, err := r.run("branch", flag, name); err != nil {
+		if strings.Contains(err.Error(), "not fully merged") {
+			return fmt.Errorf("branch %q has unmerged commits that would be lost", name)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Operation is a multi-step git operation that can stop on conflicts
+type Operation string
+
+const (
+	OperationNone       Operation = ""
+	OperationMerge      Operation = "merge"
+	OperationRebase     Operation = "rebase"
+	OperationCherryPick Operation = "cherry-pick"
+	OperationRevert     Operation = "revert"
+)
+
+// Conflict kinds, from which sides of the merge have the file
+const (
+	ConflictBothModified  = "both_modified"
+	ConflictBothAdded     = "both_added"
+	ConflictDeletedByUs   = "deleted_by_us"
+	ConflictDeletedByThem = "deleted_by_them"
+)
+
+// ConflictState is the operation in progress and its conflicted files
+type ConflictState struct {
+	Operation Operation      `json:"operation"`
+	Files     []FileConflict `json:"files"`
+}
+
+// FileConflict is a file with unmerged entries
+type FileConflict struct {
+	Path   string         `json:"path"`
+	Kind   string         `json:"kind"`
+	Binary bool           `json:"binary,omitempty"`
+	Hunks  []ConflictHunk `json:"hunks"`
+}
+
+// ConflictHunk is one region between conflict markers
+type ConflictHunk struct {
+	StartLine   int    `json:"start_line"` // 1-based line of "<<<<<<<"
+	EndLine     int    `json:"end_line"`   // Line of ">>>>>>>"
+	OursLabel   string `json:"ours_label,omitempty"`
+	TheirsLabel string `json:"theirs_label,omitempty"`
+	Ours        string `json:"ours"`
+	Base        string `json:"base,omitempty"` // With diff3 conflict style
+	Theirs      string `json:"theirs"`
+}
+
+// maxConflictFileBytes is the largest file whose hunks are parsed
+const maxConflictFileBytes = 2 * 1024 * 1024
+
+// gitPathExists reports whether a path inside the git directory exists
+func (r *Repository) gitPathExists(name string) bool {
+	output, err := r.run("rev-parse", "--git-path", name)
+	if err != nil {
+		return false
+	}
+	path := strings.TrimSpace(output)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.path, path)
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// OperationInProgress returns the merge, rebase, cherry-pick or revert
+// waiting to be continued or aborted, if any
+func (r *Repository) OperationInProgress() Operation {
+	switch {
+	case r.gitPathExists("rebase-merge") || r.gitPathExists("rebase-apply"):
+		return OperationRebase
+	case r.gitPathExists("MERGE_HEAD"):
+		return OperationMerge
+	case r.gitPathExists("CHERRY_PICK_HEAD"):
+		return OperationCherryPick
+	case r.gitPathExists("REVERT_HEAD"):
+		return OperationRevert
+	}
+	return OperationNone
+}
+
+// conflictedStages lists the paths with unmerged index entries and which
+// stages (1 base, 2 ours, 3 theirs) each has
+func (r *Repository) conflictedStages() ([]string, map[string][4]bool, error) {
+	output, err := r.run("ls-files", "--unmerged", "-z")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var paths []string
+	stages := make(map[string][4]bool)
+	for _, entry := range strings.Split(output, "\x00") {
+		// "<mode> <object> <stage>\t<path>"
+		info, path, ok := strings.Cut(entry, "\t")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(info)
+		if len(fields) != 3 {
+			continue
+		}
+		s, seen := stages[path]
+		if !seen {
+			paths = append(paths, path)
+		}
+		switch fields[2] {
+		case "1":
+			s[1] = true
+		case "2":
+			s[2] = true
+		case "3":
+			s[3] = true
+		}
+		stages[path] = s
+	}
+	return paths, stages, nil
+}
+
+// ConflictedFiles lists the paths with unmerged index entries
+func (r *Repository) ConflictedFiles() ([]string, error) {
+	paths, _, err := r.conflictedStages()
+	return paths, err
+}
+
+// GetConflicts returns the operation in progress with its conflicted files
+// and their hunks. Nil when nothing is conflicted.
+func (r *Repository) GetConflicts() (*ConflictState, error) {
+	paths, stages, err := r.conflictedStages()
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	state := &ConflictState{Operation: r.OperationInProgress()}
+	for _, path := range paths {
+		s := stages[path]
+		conflict := FileConflict{Path: path, Kind: ConflictBothModified, Hunks: []ConflictHunk{}}
+		switch {
+		case !s[2]:
+			conflict.Kind = ConflictDeletedByUs
+		case !s[3]:
+			conflict.Kind = ConflictDeletedByThem
+		case !s[1]:
+			conflict.Kind = ConflictBothAdded
+		}
+
+		if data, err := os.ReadFile(filepath.Join(r.path, path)); err == nil && len(data) <= maxConflictFileBytes {
+			if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+				conflict.Binary = true
+			} else {
+				conflict.Hunks = ParseConflictHunks(string(data))
+			}
+		}
+		state.Files = append(state.Files, conflict)
+	}
+	return state, nil
+}
+
+// ParseConflictHunks finds the conflict marker regions of a file
+func ParseConflictHunks(content string) []ConflictHunk {
+	hunks := []ConflictHunk{}
+
+	var hunk *ConflictHunk
+	var section *strings.Builder
+	var ours, base, theirs strings.Builder
+	for i, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.HasPrefix(trimmed, "<<<<<<<") && hunk == nil:
+			hunk = &ConflictHunk{StartLine: i + 1, OursLabel: strings.TrimSpace(trimmed[7:])}
+			ours.Reset()
+			base.Reset()
+			theirs.Reset()
+			section = &ours
+		case strings.HasPrefix(trimmed, "|||||||") && hunk != nil:
+			section = &base
+		case trimmed == "=======" && hunk != nil:
+			section = &theirs
+		case strings.HasPrefix(trimmed, ">>>>>>>") && hunk != nil:
+			hunk.EndLine = i + 1
+			hunk.TheirsLabel = strings.TrimSpace(trimmed[7:])
+			hunk.Ours = ours.String()
+			hunk.Base = base.String()
+			hunk.Theirs = theirs.String()
+			hunks = append(hunks, *hunk)
+			hunk = nil
+		case hunk != nil:
+			section.WriteString(line)
+		}
+	}
+	return hunks
+}
+
+// MarkResolved stages resolved files, refusing any that still contain
+// conflict markers
+func (r *Repository) MarkResolved(files []string) error {
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(r.path, file))
+		if os.IsNotExist(err) {
+			// Resolved by deleting the file
+			if _, err := r.run("rm", "--cached", "--quiet", "--", file); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		if len(ParseConflictHunks(string(data))) > 0 {
+			return fmt.Errorf("%s still has conflict markers", file)
+		}
+		if _, err := r.run("add", "--", file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ContinueOperation continues the operation in progress once its conflicts
+// are resolved and staged. A rebase may stop again on the next commit;
+// the returned state then holds the new conflicts (nil when done).
+func (r *Repository) ContinueOperation() (*ConflictState, error) {
+	op := r.OperationInProgress()
+	if op == OperationNone {
+		return nil, fmt.Errorf("no merge, rebase, cherry-pick or revert in progress")
+	}
+	if remaining, err := r.ConflictedFiles(); err != nil {
+		return nil, err
+	} else if len(remaining) > 0 {
+		return nil, fmt.Errorf("%d file(s) still have conflicts", len(remaining))
+	}
+
+	var args []string
+	switch op {
+	case OperationMerge:
+		args = []string{"commit", "--no-edit"}
+	default:
+		args = []string{string(op), "--continue"}
+	}
+
+	// Keep the prepared commit messages instead of opening an editor
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.path
+	cmd.Env = append(os.Environ(), "GIT_EDITOR=true")
+	var stderr bytes.Buffer
+	cmd.Stdout = &stderr
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if conflicts, _ := r.GetConflicts(); conflicts != nil {
+			return conflicts, nil
+		}
+		return nil, fmt.Errorf("failed to continue %s: %s", op, strings.TrimSpace(stderr.String()))
+	}
+
+	return r.GetConflicts()
+}
+
+// AbortOperation abandons the operation in progress, restoring the branch
+// and working tree to where they were before it
+func (r *Repository) AbortOperation() error {
+	op := r.OperationInProgress()
+	if op == OperationNone {
+		return fmt.Errorf("no merge, rebase, cherry-pick or revert in progress")
+	}
+	if _, err := r.run(string(op), "--abort"); err != nil {
+		return fmt.Errorf("failed to abort %s: %w", op, err)
+	}
+	return nil
+}
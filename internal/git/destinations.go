@@ -0,0 +1,183 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// unsafePatchChars are replaced in patch file names
+var unsafePatchChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// CommitToNewBranch creates branch from HEAD, checks it out (the uncommitted
+// changes come along) and commits all changes on it, skipping the pre-commit
+// and commit-msg hooks with noVerify. The repository is left on the new
+// branch; if the commit fails it is switched back and the branch removed.
+func (r *Repository) CommitToNewBranch(branch, message string, noVerify bool) error {
+	if err := r.CreateBranch(branch, "", true); err != nil {
+		return err
+	}
+	if err := r.commit(message, noVerify); err != nil {
+		if _, switchErr := r.run("switch", "-"); switchErr == nil {
+			r.run("branch", "-D", branch)
+		}
+		return err
+	}
+	return nil
+}
+
+// changedAmong returns those of paths with uncommitted changes (untracked
+// included). A pathspec naming a path git knows nothing of is an error, so
+// paths created and removed again must be left out.
+func (r *Repository) changedAmong(paths []string) ([]string, error) {
+	changed, err := r.DetectChangedFiles()
+	if err != nil {
+		return nil, err
+	}
+	isChanged := make(map[string]bool, len(changed))
+	for _, path := range changed {
+		isChanged[path] = true
+	}
+
+	var result []string
+	seen := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		if isChanged[path] && !seen[path] {
+			seen[path] = true
+			result = append(result, path)
+		}
+	}
+	return result, nil
+}
+
+// StageFiles stages the changes to paths, including untracked files, without
+// committing. Other changes are left as they are.
+func (r *Repository) StageFiles(paths []string) error {
+	paths, err := r.changedAmong(paths)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no changes to stage")
+	}
+
+	if _, err := r.run(append([]string{"add", "-A", "--"}, paths...)...); err != nil {
+		return fmt.Errorf("failed to stage changes: %w", err)
+	}
+	return nil
+}
+
+// StashFiles saves the changes to paths, including untracked files, as a
+// stash with the given message and removes them from the working tree.
+// Other changes are left as they are. Returns the stash's ref.
+func (r *Repository) StashFiles(paths []string, message string) (string, error) {
+	// With nothing to save, git succeeds without creating a stash
+	paths, err := r.changedAmong(paths)
+	if err != nil {
+		return "", err
+	}
+	if len(paths) == 0 {
+		return "", fmt.Errorf("no changes to stash")
+	}
+
+	args := append([]string{"stash", "push", "--include-untracked", "-m", message, "--"}, paths...)
+	if _, err := r.run(args...); err != nil {
+		return "", fmt.Errorf("failed to stash changes: %w", err)
+	}
+	ref, err := r.run("rev-parse", "--short", "stash@{0}")
+	if err != nil {
+		return "stash@{0}", nil
+	}
+	return "stash@{0} (" + strings.TrimSpace(ref) + ")", nil
+}
+
+// ExportPatch writes the uncommitted changes to paths, including untracked
+// files, to a patch file in dir and returns its path. The working tree,
+// index and object store are left untouched. With formatPatch the file is a
+// git format-patch email (message and author included, for git am);
+// otherwise a plain diff for git apply.
+func (r *Repository) ExportPatch(dir, name, message string, paths []string, formatPatch bool) (string, error) {
+	paths, err := r.changedAmong(paths)
+	if err != nil {
+		return "", err
+	}
+	if len(paths) == 0 {
+		return "", fmt.Errorf("no changes to export")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create patch directory: %w", err)
+	}
+
+	// Stage the changes into a throwaway index, so untracked files are
+	// included. The blobs, tree and commit this writes go to a private
+	// object directory removed with the index.
+	index, err := r.newTempIndex()
+	if err != nil {
+		return "", err
+	}
+	defer index.Remove()
+	if err := index.isolateObjects(); err != nil {
+		return "", err
+	}
+
+	head, headErr := r.GetHeadHash()
+	withIndex := func(args ...string) (string, error) {
+		return index.run("", args...)
+	}
+	if headErr == nil {
+		if _, err := withIndex("read-tree", head); err != nil {
+			return "", err
+		}
+	}
+	if _, err := withIndex(append([]string{"add", "-A", "--"}, paths...)...); err != nil {
+		return "", err
+	}
+
+	var patch string
+	if formatPatch {
+		tree, err := withIndex("write-tree")
+		if err != nil {
+			return "", err
+		}
+		args := []string{"commit-tree", "-m", message}
+		if headErr == nil {
+			args = append(args, "-p", head)
+		}
+		args = append(args, strings.TrimSpace(tree))
+		commit, err := withIndex(args...)
+		if err != nil {
+			return "", err
+		}
+		if headErr == nil {
+			patch, err = withIndex("format-patch", "-1", "--binary", "--stdout", strings.TrimSpace(commit))
+		} else {
+			patch, err = withIndex("format-patch", "--root", "--binary", "--stdout", strings.TrimSpace(commit))
+		}
+		if err != nil {
+			return "", err
+		}
+	} else {
+		patch, err = withIndex("diff", "--cached", "--binary")
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if strings.TrimSpace(patch) == "" {
+		return "", fmt.Errorf("no changes to export")
+	}
+
+	name = strings.Trim(unsafePatchChars.ReplaceAllString(name, "-"), "-.")
+	if name == "" {
+		name = "changes"
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.patch", time.Now().Format("20060102-150405"), name))
+	if err := os.WriteFile(path, []byte(patch), 0600); err != nil {
+		return "", fmt.Errorf("failed to write patch: %w", err)
+	}
+
+	return path, nil
+}
@@ -0,0 +1,312 @@
+package git
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MaxDiffLines is how many hunk lines of a file a structured diff keeps
+// before it is truncated
+const MaxDiffLines = 3000
+
+// Kinds of diff lines
+const (
+	LineContext = "context"
+	LineAdded   = "added"
+	LineDeleted = "deleted"
+)
+
+// FileDiff is the part of a multi-file diff about one file, parsed
+type FileDiff struct {
+	Path         string     `json:"path"`                 // New path; the old one for a deleted file
+	OldPath      string     `json:"old_path,omitempty"`   // Source of a rename or copy
+	Kind         ChangeKind `json:"status"`               // added, modified, deleted, renamed or copied
+	OldMode      string     `json:"old_mode,omitempty"`   // Set with NewMode when the file mode changed
+	NewMode      string     `json:"new_mode,omitempty"`   // e.g. "100755"
+	Similarity   int        `json:"similarity,omitempty"` // Rename or copy score, in percent
+	Binary       bool       `json:"binary,omitempty"`     // No hunks: git shows binary files as changed only
+	Additions    int        `json:"additions"`
+	Deletions    int        `json:"deletions"`
+	Hunks        []DiffHunk `json:"hunks"`
+	Truncated    bool       `json:"truncated,omitempty"`     // Hunks were cut short by Truncate
+	OmittedLines int        `json:"omitted_lines,omitempty"` // Hunk lines dropped by truncation
+	Text         string     `json:"-"`                       // The unified diff
+}
+
+// DiffHunk is one "@@ -a,b +c,d @@" block of a file diff
+type DiffHunk struct {
+	OldStart int             `json:"old_start"`
+	OldLines int             `json:"old_lines"`
+	NewStart int             `json:"new_start"`
+	NewLines int             `json:"new_lines"`
+	Section  string          `json:"section,omitempty"` // Function context git shows after the range
+	Lines    []DiffLine      `json:"lines"`
+	Rows     []SideBySideRow `json:"rows,omitempty"` // Side-by-side alignment of Lines, set by AlignSideBySide
+}
+
+// DiffLine is one line of a hunk
+type DiffLine struct {
+	Kind      string `json:"kind"`                 // context, added or deleted
+	Content   string `json:"content"`              // Without the +, - or space prefix
+	OldLine   int    `json:"old_line,omitempty"`   // Line number in the old file (context and deleted)
+	NewLine   int    `json:"new_line,omitempty"`   // Line number in the new file (context and added)
+	NoNewline bool   `json:"no_newline,omitempty"` // Last line of its file, without a newline
+	Spans     []Span `json:"spans,omitempty"`      // Changed parts of the content, set by Highlight
+}
+
+// ParseDiff parses git diff (or git show) output into its files. Anything
+// before the first "diff --git" line, such as a commit header, is skipped.
+func ParseDiff(output string) []FileDiff {
+	var files []FileDiff
+	var current *FileDiff
+	var hunk *DiffHunk
+	var text strings.Builder
+	var oldLine, newLine, oldLeft, newLeft int
+
+	flush := func() {
+		if current != nil {
+			current.Text = text.String()
+			files = append(files, *current)
+		}
+		text.Reset()
+		hunk = nil
+	}
+
+	inHeader := false
+	for _, line := range strings.SplitAfter(output, "\n") {
+		trimmed := strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.HasPrefix(trimmed, "diff --git "):
+			flush()
+			oldPath, newPath := parseDiffGitLine(trimmed)
+			current = &FileDiff{OldPath: oldPath, Path: newPath, Kind: ChangeModified, Hunks: []DiffHunk{}}
+			inHeader = true
+
+		case current == nil:
+			continue
+
+		case strings.HasPrefix(trimmed, "@@ "):
+			inHeader = false
+			h, ok := parseHunkHeader(trimmed)
+			if !ok {
+				break
+			}
+			current.Hunks = append(current.Hunks, h)
+			hunk = &current.Hunks[len(current.Hunks)-1]
+			oldLine, newLine = h.OldStart, h.NewStart
+			oldLeft, newLeft = h.OldLines, h.NewLines
+
+		case inHeader:
+			// Extended header lines name paths exactly, unlike "diff --git"
+			switch {
+			case strings.HasPrefix(trimmed, "Binary files "), trimmed == "GIT binary patch":
+				current.Binary = true
+				inHeader = false
+			case strings.HasPrefix(trimmed, "--- "):
+				if p := diffHeaderPath(trimmed[4:], "a/"); p != "" {
+					current.OldPath = p
+				}
+			case strings.HasPrefix(trimmed, "+++ "):
+				if p := diffHeaderPath(trimmed[4:], "b/"); p != "" {
+					current.Path = p
+				}
+			case strings.HasPrefix(trimmed, "rename from "), strings.HasPrefix(trimmed, "copy from "):
+				current.OldPath = unquotePath(trimmed[strings.Index(trimmed, " from ")+6:])
+			case strings.HasPrefix(trimmed, "rename to "), strings.HasPrefix(trimmed, "copy to "):
+				current.Path = unquotePath(trimmed[strings.Index(trimmed, " to ")+4:])
+				current.Kind = ChangeRenamed
+				if strings.HasPrefix(trimmed, "copy") {
+					current.Kind = ChangeCopied
+				}
+			case strings.HasPrefix(trimmed, "similarity index "):
+				current.Similarity, _ = strconv.Atoi(strings.TrimSuffix(trimmed[17:], "%"))
+			case strings.HasPrefix(trimmed, "new file mode"):
+				current.OldPath = ""
+				current.Kind = ChangeAdded
+			case strings.HasPrefix(trimmed, "deleted file mode"):
+				current.Kind = ChangeDeleted
+			case strings.HasPrefix(trimmed, "old mode "):
+				current.OldMode = trimmed[9:]
+			case strings.HasPrefix(trimmed, "new mode "):
+				current.NewMode = trimmed[9:]
+			}
+
+		case hunk != nil && strings.HasPrefix(trimmed, `\`):
+			// "\ No newline at end of file" marks the line before it
+			if n := len(hunk.Lines); n > 0 {
+				hunk.Lines[n-1].NoNewline = true
+			}
+
+		case hunk != nil && (oldLeft > 0 || newLeft > 0):
+			content := strings.TrimSuffix(line, "\n")
+			prefix := byte(' ')
+			if content != "" {
+				prefix, content = content[0], content[1:]
+			}
+			switch {
+			case prefix == '+' && newLeft > 0:
+				hunk.Lines = append(hunk.Lines, DiffLine{Kind: LineAdded, Content: content, NewLine: newLine})
+				current.Additions++
+				newLine++
+				newLeft--
+			case prefix == '-' && oldLeft > 0:
+				hunk.Lines = append(hunk.Lines, DiffLine{Kind: LineDeleted, Content: content, OldLine: oldLine})
+				current.Deletions++
+				oldLine++
+				oldLeft--
+			case prefix == ' ':
+				hunk.Lines = append(hunk.Lines, DiffLine{Kind: LineContext, Content: content, OldLine: oldLine, NewLine: newLine})
+				oldLine++
+				newLine++
+				oldLeft--
+				newLeft--
+			}
+		}
+		if current != nil {
+			text.WriteString(line)
+		}
+	}
+	flush()
+
+	for i := range files {
+		if files[i].Path == "" {
+			files[i].Path = files[i].OldPath
+		}
+		if files[i].Kind != ChangeRenamed && files[i].Kind != ChangeCopied {
+			files[i].OldPath = ""
+		}
+	}
+	return files
+}
+
+// parseHunkHeader parses "@@ -<start>[,<lines>] +<start>[,<lines>] @@ <section>"
+func parseHunkHeader(line string) (DiffHunk, bool) {
+	var hunk DiffHunk
+	end := strings.Index(line[3:], " @@")
+	if end < 0 {
+		return hunk, false
+	}
+	ranges := strings.Fields(line[3 : 3+end])
+	if len(ranges) != 2 || ranges[0][0] != '-' || ranges[1][0] != '+' {
+		return hunk, false
+	}
+
+	var ok1, ok2 bool
+	hunk.OldStart, hunk.OldLines, ok1 = parseHunkRange(ranges[0][1:])
+	hunk.NewStart, hunk.NewLines, ok2 = parseHunkRange(ranges[1][1:])
+	hunk.Section = strings.TrimSpace(line[3+end+3:])
+	hunk.Lines = []DiffLine{}
+	return hunk, ok1 && ok2
+}
+
+// parseHunkRange parses "<start>[,<lines>]"; lines defaults to 1
+func parseHunkRange(r string) (int, int, bool) {
+	startText, linesText, hasLines := strings.Cut(r, ",")
+	start, err := strconv.Atoi(startText)
+	if err != nil {
+		return 0, 0, false
+	}
+	lines := 1
+	if hasLines {
+		if lines, err = strconv.Atoi(linesText); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, lines, true
+}
+
+// Truncate keeps at most maxLines hunk lines, marking the diff truncated and
+// counting what was dropped. Additions and deletions still cover the whole
+// file; the raw Text is left as is.
+func (f *FileDiff) Truncate(maxLines int) {
+	kept := 0
+	for i := range f.Hunks {
+		hunk := &f.Hunks[i]
+		if kept+len(hunk.Lines) <= maxLines {
+			kept += len(hunk.Lines)
+			continue
+		}
+
+		keep := maxLines - kept
+		f.OmittedLines += len(hunk.Lines) - keep
+		hunk.Lines = hunk.Lines[:keep]
+		for _, rest := range f.Hunks[i+1:] {
+			f.OmittedLines += len(rest.Lines)
+		}
+		if keep == 0 {
+			f.Hunks = f.Hunks[:i]
+		} else {
+			f.Hunks = f.Hunks[:i+1]
+		}
+		f.Truncated = true
+		return
+	}
+}
+
+// parseDiffGitLine extracts the paths of a "diff --git a/<old> b/<new>"
+// line. Unquoted paths containing " b/" are ambiguous there; the extended
+// header lines that follow settle them.
+func parseDiffGitLine(line string) (string, string) {
+	rest := strings.TrimPrefix(line, "diff --git ")
+
+	if strings.HasPrefix(rest, `"`) {
+		// Quoted old path, then a quoted or plain new path
+		if end := closingQuote(rest); end > 0 {
+			oldPath := unquotePath(rest[:end+1])
+			return strings.TrimPrefix(oldPath, "a/"), strings.TrimPrefix(unquotePath(strings.TrimSpace(rest[end+1:])), "b/")
+		}
+	}
+	if strings.HasSuffix(rest, `"`) {
+		if start := strings.LastIndex(rest, ` "`); start > 0 {
+			return strings.TrimPrefix(rest[:start], "a/"), strings.TrimPrefix(unquotePath(rest[start+1:]), "b/")
+		}
+	}
+
+	// Same path on both sides (the usual case): "a/<p> b/<p>"
+	if n := len(rest); n > 5 && (n-5)%2 == 0 {
+		half := (n - 5) / 2
+		if rest[:2] == "a/" && rest[2+half:2+half+3] == " b/" && rest[2:2+half] == rest[5+half:] {
+			return rest[2 : 2+half], rest[2 : 2+half]
+		}
+	}
+	if i := strings.Index(rest, " b/"); i > 0 {
+		return strings.TrimPrefix(rest[:i], "a/"), rest[i+3:]
+	}
+	return "", ""
+}
+
+// diffHeaderPath returns the path of a ---/+++ line, "" for /dev/null
+func diffHeaderPath(value, prefix string) string {
+	value = strings.TrimSuffix(value, "\t") // Follows paths containing spaces
+	if value == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(unquotePath(value), prefix)
+}
+
+// closingQuote returns the index of the quote closing the quoted string at
+// the start of s, -1 without one
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// unquotePath decodes a path git quoted C-style (for non-ASCII bytes,
+// quotes, backslashes and control characters); other paths are returned as is
+func unquotePath(path string) string {
+	if len(path) < 2 || path[0] != '"' || path[len(path)-1] != '"' {
+		return path
+	}
+	// Go's escapes (\t, \n, \", \\ and \ooo octal bytes) cover git's
+	if unquoted, err := strconv.Unquote(path); err == nil {
+		return unquoted
+	}
+	return path
+}
@@ -2,12 +2,12 @@ package git
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
 	"strings"
 )
 
@@ -21,46 +21,41 @@ func NewRepository(path string) *Repository {
 	return &Repository{path: path}
 }
 
-// DetectChangedFiles returns a list of files that have changed (modified or untracked)
-func (r *Repository) DetectChangedFiles() ([]string, error) {
-	filesMap := make(map[string]bool)
+// run runs a git command in the repository and returns its output.
+// Failures carry git's error output.
+func (r *Repository) run(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.path
 
-	// Get modified files (git diff)
-	diffCmd := exec.Command("git", "diff", "--name-only", "HEAD")
-	diffCmd.Dir = r.path
-	diffOutput, err := diffCmd.Output()
-	if err != nil {
-		// This can fail if there's no HEAD yet (new repo with no commits)
-		// Log but continue to check for untracked files
-		log.Printf("git diff warning (may be expected for new repos): %v", err)
-	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
 
-	for _, line := range strings.Split(string(diffOutput), "\n") {
-		line = strings.TrimSpace(line)
-		if line != "" {
-			filesMap[line] = true
+	output, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
 		}
+		return string(output), fmt.Errorf("git %s failed: %s", args[0], msg)
 	}
 
-	// Get untracked files (git ls-files --others --exclude-standard)
-	untrackedCmd := exec.Command("git", "ls-files", "--others", "--exclude-standard")
-	untrackedCmd.Dir = r.path
-	untrackedOutput, err := untrackedCmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to list untracked files: %w", err)
-	}
+	return string(output), nil
+}
 
-	for _, line := range strings.Split(string(untrackedOutput), "\n") {
-		line = strings.TrimSpace(line)
-		if line != "" {
-			filesMap[line] = true
-		}
+// DetectChangedFiles returns a list of files that have changed (modified,
+// added, deleted or untracked). Both paths of a rename are listed.
+func (r *Repository) DetectChangedFiles() ([]string, error) {
+	statuses, err := r.Status()
+	if err != nil {
+		return nil, err
 	}
 
-	// Convert map to slice
 	files := []string{}
-	for file := range filesMap {
-		files = append(files, file)
+	for _, status := range statuses {
+		files = append(files, status.Path)
+		if status.Kind == ChangeRenamed {
+			files = append(files, status.OrigPath)
+		}
 	}
 
 	return files, nil
@@ -68,77 +63,11 @@ func (r *Repository) DetectChangedFiles() ([]string, error) {
 
 // GenerateDiff generates a diff for a specific file
 func (r *Repository) GenerateDiff(filePath string) (string, error) {
-	log.Printf("🔍 GenerateDiff called for: %s", filePath)
-
-	// First check if file is tracked by git
-	lsFilesCmd := exec.Command("git", "ls-files", filePath)
-	lsFilesCmd.Dir = r.path
-	lsFilesOutput, err := lsFilesCmd.Output()
+	diffs, err := r.GenerateDiffs([]string{filePath})
 	if err != nil {
-		log.Printf("  ⚠️  ls-files error (continuing): %v", err)
+		return "", err
 	}
-	isTracked := len(bytes.TrimSpace(lsFilesOutput)) > 0
-	log.Printf("  📋 Is tracked: %v", isTracked)
-
-	if !isTracked {
-		// File is untracked - check if it exists on disk
-		statusCmd := exec.Command("git", "ls-files", "--others", "--exclude-standard", filePath)
-		statusCmd.Dir = r.path
-		statusOutput, err := statusCmd.Output()
-		if err != nil {
-			log.Printf("  ⚠️  ls-files --others error (continuing): %v", err)
-		}
-
-		if strings.TrimSpace(string(statusOutput)) != "" {
-			log.Printf("  🆕 File is new/untracked - generating new file diff")
-			// File exists but is untracked - generate a "new file" diff
-			// Use platform-appropriate null device
-			devNull := "/dev/null"
-			if runtime.GOOS == "windows" {
-				devNull = "NUL"
-			}
-			diffCmd := exec.Command("git", "diff", "--no-index", "--", devNull, filePath)
-			diffCmd.Dir = r.path
-			// git diff --no-index exits with code 1 when files differ, which is expected
-			// So we intentionally ignore the error here
-			diffOutput, _ := diffCmd.Output()
-
-			log.Printf("  ✅ Generated diff length: %d bytes", len(diffOutput))
-			return string(diffOutput), nil
-		}
-
-		log.Printf("  ⚠️  File not tracked and not found in untracked list")
-		return "", nil
-	}
-
-	// File is tracked - try to get diff
-	// Use 'git diff' without HEAD to show unstaged changes
-	cmd := exec.Command("git", "diff", filePath)
-	cmd.Dir = r.path
-	output, err := cmd.Output()
-	if err != nil {
-		log.Printf("  ⚠️  git diff error (trying HEAD): %v", err)
-	}
-
-	log.Printf("  📊 Diff from 'git diff': %d bytes", len(output))
-
-	// If still empty, try diff against HEAD (for staged changes)
-	if len(output) == 0 {
-		headCmd := exec.Command("git", "diff", "HEAD", filePath)
-		headCmd.Dir = r.path
-		headOutput, err := headCmd.Output()
-		if err != nil {
-			log.Printf("  ⚠️  git diff HEAD error: %v", err)
-		}
-		log.Printf("  📊 Diff from 'git diff HEAD': %d bytes", len(headOutput))
-		output = headOutput
-	}
-
-	if len(output) == 0 {
-		log.Printf("  ⚠️  No diff found for tracked file (may be unchanged)")
-	}
-
-	return string(output), nil
+	return diffs[filePath], nil
 }
 
 // GenerateAllDiffs generates diffs for all changed files
@@ -148,21 +77,22 @@ func (r *Repository) GenerateAllDiffs() (map[string]string, error) {
 		return nil, err
 	}
 
-	diffs := make(map[string]string)
+	return r.GenerateDiffs(files)
+}
 
-	for _, file := range files {
-		diff, err := r.GenerateDiff(file)
-		if err != nil {
-			return nil, err
-		}
-		diffs[file] = diff
-	}
+// Commit creates a git commit with the given message. A commit refused by
+// a hook returns a *HookFailedError with the hook's output.
+func (r *Repository) Commit(message string) error {
+	return r.commit(message, false)
+}
 
-	return diffs, nil
+// CommitNoVerify commits like Commit, skipping the pre-commit and
+// commit-msg hooks
+func (r *Repository) CommitNoVerify(message string) error {
+	return r.commit(message, true)
 }
 
-// Commit creates a git commit with the given message
-func (r *Repository) Commit(message string) error {
+func (r *Repository) commit(message string, noVerify bool) error {
 	// Add all changes
 	addCmd := exec.Command("git", "add", "-A")
 	addCmd.Dir = r.path
@@ -171,50 +101,62 @@ func (r *Repository) Commit(message string) error {
 	}
 
 	// Commit
-	commitCmd := exec.Command("git", "commit", "-m", message)
+	args := []string{"commit", "-m", message}
+	if noVerify {
+		args = append(args, "--no-verify")
+	}
+	commitCmd := exec.Command("git", args...)
 	commitCmd.Dir = r.path
 
-	var stderr bytes.Buffer
-	commitCmd.Stderr = &stderr
+	// Hooks print to both streams; keep all of it, in order
+	var output bytes.Buffer
+	commitCmd.Stdout = &output
+	commitCmd.Stderr = &output
 
 	if err := commitCmd.Run(); err != nil {
-		return fmt.Errorf("failed to commit: %s", stderr.String())
+		if hookErr := r.hookFailure(output.String(), noVerify); hookErr != nil {
+			return hookErr
+		}
+		return fmt.Errorf("failed to commit: %s", strings.TrimSpace(output.String()))
 	}
 
 	return nil
 }
 
-// Push pushes commits to remote
+// Push pushes the current branch. A branch without an upstream is pushed
+// to origin (or the only remote) and set to track it. Returns ErrNoRemote
+// without remotes and a *PushRejectedError when the remote has diverged.
 func (r *Repository) Push() error {
-	cmd := exec.Command("git", "push")
-	cmd.Dir = r.path
-
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to push: %s", stderr.String())
+	if r.upstream() != "" {
+		if _, err := r.run("push"); err != nil {
+			branch, _ := r.GetCurrentBranch()
+			return pushError(branch, err)
+		}
+		return nil
 	}
 
-	return nil
+	branch, err := r.GetCurrentBranch()
+	if err != nil {
+		return err
+	}
+	if branch == "" {
+		return fmt.Errorf("failed to push: HEAD is detached")
+	}
+	return r.PushUpstream(branch)
 }
 
-// CommitAndPush commits changes and pushes to remote (if configured)
+// CommitAndPush commits changes and pushes them. Having no remote is fine:
+// the changes are committed locally only.
 func (r *Repository) CommitAndPush(message string) error {
 	if err := r.Commit(message); err != nil {
 		return err
 	}
 
-	// Try to push, but don't fail if no remote is configured
 	if err := r.Push(); err != nil {
-		// Check if it's a "no remote" error
-		if strings.Contains(err.Error(), "No configured push destination") ||
-			strings.Contains(err.Error(), "no upstream branch") {
-			// No remote configured - that's OK, just committed locally
+		if errors.Is(err, ErrNoRemote) {
 			log.Printf("ℹ️  No remote repository configured - changes committed locally only")
 			return nil
 		}
-		// Other push errors are real errors
 		return err
 	}
 
@@ -346,14 +288,21 @@ type CommitInfo struct {
 		Deletions    int `json:"deletions"`
 		FilesChanged int `json:"files_changed"`
 	} `json:"stats"`
+
+	// Trailers at the end of the message, and the Finn conversation and
+	// Claude session they link the commit to
+	Trailers       []Trailer `json:"trailers,omitempty"`
+	ConversationID string    `json:"conversation_id,omitempty"`
+	SessionID      string    `json:"session_id,omitempty"`
 }
 
 // FileChange represents changes to a single file in a commit
 type FileChange struct {
-	Path      string `json:"path"`
-	Additions int    `json:"additions"`
-	Deletions int    `json:"deletions"`
-	Diff      string `json:"diff"`
+	Path      string    `json:"path"`
+	Additions int       `json:"additions"`
+	Deletions int       `json:"deletions"`
+	Diff      string    `json:"diff"`
+	Parsed    *FileDiff `json:"parsed,omitempty"` // Diff parsed into hunks, truncated past MaxDiffLines
 }
 
 // CommitDetails extends CommitInfo with file-level details
@@ -362,13 +311,17 @@ type CommitDetails struct {
 	Files []FileChange `json:"files"`
 }
 
+// logFormat is the git log format parsed by parseGitLog: each commit starts
+// with a record separator, its fields are separated by unit separators, and
+// the raw message is last so multi-line bodies and trailers survive intact.
+// Fields: hash, full hash, subject, author name, author email, unix
+// timestamp, raw message; --shortstat output follows the final separator.
+const logFormat = "--format=%x1e%h%x1f%H%x1f%s%x1f%an%x1f%ae%x1f%at%x1f%B%x1f"
+
 // GetCommits retrieves recent git commits
 func (r *Repository) GetCommits(limit int) ([]CommitInfo, error) {
-	// Format: hash|full_hash|subject|body|author name|author email|unix timestamp
-	format := "%h|%H|%s|%b|%an|%ae|%at"
-
 	// Use --no-merges to avoid duplicate commits from merges
-	cmd := exec.Command("git", "log", fmt.Sprintf("-%d", limit), fmt.Sprintf("--format=%s", format), "--shortstat", "--no-merges")
+	cmd := exec.Command("git", "log", fmt.Sprintf("-%d", limit), logFormat, "--shortstat", "--no-merges")
 	cmd.Dir = r.path
 
 	output, err := cmd.Output()
@@ -397,8 +350,7 @@ func (r *Repository) GetCommits(limit int) ([]CommitInfo, error) {
 // GetCommitDetails retrieves detailed information about a specific commit
 func (r *Repository) GetCommitDetails(hash string) (*CommitDetails, error) {
 	// Get basic commit info
-	format := "%h|%H|%s|%b|%an|%ae|%at"
-	cmd := exec.Command("git", "show", hash, fmt.Sprintf("--format=%s", format), "--shortstat", "--no-patch")
+	cmd := exec.Command("git", "show", hash, logFormat, "--shortstat", "--no-patch")
 	cmd.Dir = r.path
 
 	output, err := cmd.Output()
@@ -459,12 +411,18 @@ func (r *Repository) GetCommitDetails(hash string) (*CommitDetails, error) {
 			// Continue without diff content
 		}
 
-		details.Files = append(details.Files, FileChange{
+		change := FileChange{
 			Path:      filePath,
 			Additions: additions,
 			Deletions: deletions,
 			Diff:      string(diffOutput),
-		})
+		}
+		if parsed := ParseDiff(change.Diff); len(parsed) > 0 {
+			parsed[0].Truncate(MaxDiffLines)
+			parsed[0].Highlight()
+			change.Parsed = &parsed[0]
+		}
+		details.Files = append(details.Files, change)
 	}
 
 	return details, nil
@@ -484,79 +442,57 @@ func (r *Repository) GetLatestCommit() (*CommitInfo, error) {
 	return &commits[0], nil
 }
 
-// parseGitLog parses git log output into CommitInfo structs
+// parseGitLog parses git log output (in logFormat) into CommitInfo structs
 func parseGitLog(output string) ([]CommitInfo, error) {
 	var commits []CommitInfo
-	lines := strings.Split(output, "\n")
 
-	var currentCommit *CommitInfo
-	var bodyLines []string
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
+	for _, record := range strings.Split(output, "\x1e") {
+		if strings.TrimSpace(record) == "" {
 			continue
 		}
 
-		// Check if this is a commit info line (contains pipes)
-		if strings.Contains(line, "|") {
-			// Save previous commit if exists
-			if currentCommit != nil {
-				if len(bodyLines) > 0 {
-					currentCommit.FullMessage = strings.Join(bodyLines, "\n")
-				}
-				commits = append(commits, *currentCommit)
-			}
-
-			// Parse new commit line
-			parts := strings.Split(line, "|")
-			if len(parts) < 7 {
-				continue
-			}
+		parts := strings.SplitN(record, "\x1f", 8)
+		if len(parts) < 7 {
+			continue
+		}
 
-			var timestamp int64
-			fmt.Sscanf(parts[6], "%d", &timestamp)
-
-			currentCommit = &CommitInfo{
-				Hash:        parts[0],
-				FullHash:    parts[1],
-				Message:     parts[2],
-				FullMessage: parts[2], // Will be updated with body if present
-				Author:      parts[4],
-				Email:       parts[5],
-				Timestamp:   timestamp,
-			}
+		var timestamp int64
+		fmt.Sscanf(parts[5], "%d", &timestamp)
+
+		commit := CommitInfo{
+			Hash:        parts[0],
+			FullHash:    parts[1],
+			Message:     parts[2],
+			FullMessage: strings.TrimSpace(parts[6]),
+			Author:      parts[3],
+			Email:       parts[4],
+			Timestamp:   timestamp,
+		}
+		if commit.FullMessage == "" {
+			commit.FullMessage = commit.Message
+		}
 
-			bodyLines = []string{parts[2]} // Start with subject
-			if parts[3] != "" {
-				bodyLines = append(bodyLines, "", parts[3]) // Add body if present
-			}
+		commit.Trailers = ParseTrailers(commit.FullMessage)
+		commit.ConversationID = TrailerValue(commit.Trailers, TrailerConversation)
+		commit.SessionID = TrailerValue(commit.Trailers, TrailerSession)
 
-		} else if strings.Contains(line, "changed,") || strings.Contains(line, "insertion") || strings.Contains(line, "deletion") {
-			// Parse shortstat line: "3 files changed, 45 insertions(+), 12 deletions(-)"
-			if currentCommit != nil {
-				fields := strings.Fields(line)
-				for i, field := range fields {
-					if field == "changed," && i > 0 {
-						fmt.Sscanf(fields[i-1], "%d", &currentCommit.Stats.FilesChanged)
-					}
-					if strings.HasPrefix(field, "insertion") && i > 0 {
-						fmt.Sscanf(fields[i-1], "%d", &currentCommit.Stats.Additions)
-					}
-					if strings.HasPrefix(field, "deletion") && i > 0 {
-						fmt.Sscanf(fields[i-1], "%d", &currentCommit.Stats.Deletions)
-					}
+		// Parse shortstat line: "3 files changed, 45 insertions(+), 12 deletions(-)"
+		if len(parts) == 8 {
+			fields := strings.Fields(parts[7])
+			for i, field := range fields {
+				if strings.HasPrefix(field, "file") && i > 0 {
+					fmt.Sscanf(fields[i-1], "%d", &commit.Stats.FilesChanged)
+				}
+				if strings.HasPrefix(field, "insertion") && i > 0 {
+					fmt.Sscanf(fields[i-1], "%d", &commit.Stats.Additions)
+				}
+				if strings.HasPrefix(field, "deletion") && i > 0 {
+					fmt.Sscanf(fields[i-1], "%d", &commit.Stats.Deletions)
 				}
 			}
 		}
-	}
 
-	// Don't forget the last commit
-	if currentCommit != nil {
-		if len(bodyLines) > 0 {
-			currentCommit.FullMessage = strings.Join(bodyLines, "\n")
-		}
-		commits = append(commits, *currentCommit)
+		commits = append(commits, commit)
 	}
 
 	return commits, nil
@@ -662,11 +598,11 @@ func (r *Repository) GetCommitsSince(sinceHash string, limit int) ([]CommitInfo,
 
 	if sinceHash == "" {
 		// No reference hash, just get recent commits
-		cmd = exec.Command("git", "log", fmt.Sprintf("-%d", limit), "--format=%h|%H|%s|%b|%an|%ae|%at", "--shortstat", "--no-merges")
+		cmd = exec.Command("git", "log", fmt.Sprintf("-%d", limit), logFormat, "--shortstat", "--no-merges")
 	} else {
 		// Get commits since the reference hash (exclusive of sinceHash)
 		// Use sinceHash..HEAD to get commits reachable from HEAD but not from sinceHash
-		cmd = exec.Command("git", "log", fmt.Sprintf("%s..HEAD", sinceHash), "--format=%h|%H|%s|%b|%an|%ae|%at", "--shortstat", "--no-merges")
+		cmd = exec.Command("git", "log", fmt.Sprintf("%s..HEAD", sinceHash), logFormat, "--shortstat", "--no-merges")
 	}
 
 	cmd.Dir = r.path
@@ -0,0 +1,73 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// commitHooks are the hooks git commit runs, in order. --no-verify skips
+// pre-commit and commit-msg.
+var commitHooks = []string{"pre-commit", "prepare-commit-msg", "commit-msg"}
+
+// HookFailedError is returned when a commit hook refused a commit
+type HookFailedError struct {
+	Hook       string // Hook that most likely failed, e.g. "pre-commit"
+	Output     string // Everything the hook (and git) printed
+	Bypassable bool   // Whether --no-verify skips this hook
+}
+
+func (e *HookFailedError) Error() string {
+	return fmt.Sprintf("the %s hook blocked the commit", e.Hook)
+}
+
+// activeHooks lists the executable commit hooks of the repository,
+// honouring core.hooksPath
+func (r *Repository) activeHooks() []string {
+	var hooks []string
+	for _, hook := range commitHooks {
+		output, err := r.run("rev-parse", "--git-path", "hooks/"+hook)
+		if err != nil {
+			continue
+		}
+		path := strings.TrimSpace(output)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(r.path, path)
+		}
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if runtime.GOOS != "windows" && info.Mode()&0111 == 0 {
+			continue // git ignores hooks that aren't executable
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks
+}
+
+// hookFailure decides whether a failed commit was refused by a hook. Git
+// adds nothing of its own when a hook exits non-zero, so a failure with
+// hooks installed and no git error line is put down to the first hook that
+// could have refused it.
+func (r *Repository) hookFailure(output string, noVerify bool) *HookFailedError {
+	if strings.Contains(output, "nothing to commit") || strings.Contains(output, "nothing added to commit") {
+		return nil
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "fatal: ") {
+			return nil
+		}
+	}
+
+	for _, hook := range r.activeHooks() {
+		bypassable := hook != "prepare-commit-msg"
+		if noVerify && bypassable {
+			continue
+		}
+		return &HookFailedError{Hook: hook, Output: output, Bypassable: bypassable}
+	}
+	return nil
+}
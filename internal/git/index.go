@@ -0,0 +1,85 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// tempIndex is a throwaway index for staging changes without touching the
+// repository's real index
+type tempIndex struct {
+	repo    *Repository
+	path    string
+	objects string // Private object directory (isolateObjects), "" for the repository's
+}
+
+// newTempIndex creates an empty temporary index. Remove it when done.
+func (r *Repository) newTempIndex() (*tempIndex, error) {
+	file, err := os.CreateTemp("", "finn-index-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary index: %w", err)
+	}
+	file.Close()
+	os.Remove(file.Name()) // git creates it; an empty file is an invalid index
+	return &tempIndex{repo: r, path: file.Name()}, nil
+}
+
+// run runs a git command against the temporary index, feeding it stdin
+func (t *tempIndex) run(stdin string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = t.repo.path
+	cmd.Env = append(os.Environ(), "GIT_INDEX_FILE="+t.path, "GIT_LITERAL_PATHSPECS=1")
+	if t.objects != "" {
+		cmd.Env = append(cmd.Env, "GIT_OBJECT_DIRECTORY="+t.objects, "GIT_ALTERNATE_OBJECT_DIRECTORIES="+t.repo.objectsDir())
+	}
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s failed: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s failed: %w", args[0], err)
+	}
+	return string(output), nil
+}
+
+// isolateObjects makes the objects written through the index (blobs of
+// added files, trees, commits) go to a private directory removed with it,
+// rather than be left dangling in the repository. The repository's objects
+// stay readable.
+func (t *tempIndex) isolateObjects() error {
+	dir, err := os.MkdirTemp("", "finn-objects-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary object directory: %w", err)
+	}
+	t.objects = dir
+	return nil
+}
+
+// Remove deletes the temporary index and its private objects
+func (t *tempIndex) Remove() {
+	os.Remove(t.path)
+	if t.objects != "" {
+		os.RemoveAll(t.objects)
+	}
+}
+
+// objectsDir returns the absolute path of the repository's object directory
+func (r *Repository) objectsDir() string {
+	output, err := r.run("rev-parse", "--git-path", "objects")
+	dir := strings.TrimSpace(output)
+	if err != nil || dir == "" {
+		dir = filepath.Join(".git", "objects")
+	}
+	if !filepath.IsAbs(dir) {
+		if abs, err := filepath.Abs(filepath.Join(r.path, dir)); err == nil {
+			dir = abs
+		}
+	}
+	return dir
+}
@@ -0,0 +1,265 @@
+package git
+
+import "unicode"
+
+// maxIntralineCells bounds the token comparison of a line pair (tokens of
+// one line times tokens of the other); longer pairs get no spans
+const maxIntralineCells = 1 << 18
+
+// minIntralineSimilarity is the share of a line pair that must be unchanged
+// for spans to be worth showing; below it the lines were rewritten
+const minIntralineSimilarity = 0.4
+
+// Span is a changed range of a line's content, in UTF-16 code units (how
+// JavaScript, Dart, Swift and Kotlin index strings); End is exclusive
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// SideBySideRow is one row of a side-by-side hunk: indexes into the hunk's
+// Lines of its old (left) and new (right) line, -1 for a blank cell. A
+// context line fills both sides.
+type SideBySideRow struct {
+	Old int `json:"old"`
+	New int `json:"new"`
+}
+
+// Highlight computes the Spans of each deleted and added line paired with a
+// line on the other side: changed words, narrowed to the changed characters
+// within a word. Lines left unpaired or mostly rewritten get none, as
+// they changed as a whole.
+func (f *FileDiff) Highlight() {
+	for i := range f.Hunks {
+		lines := f.Hunks[i].Lines
+		for _, row := range sideBySideRows(lines) {
+			if row.Old < 0 || row.New < 0 || row.Old == row.New {
+				continue
+			}
+			lines[row.Old].Spans, lines[row.New].Spans = intralineSpans(lines[row.Old].Content, lines[row.New].Content)
+		}
+	}
+}
+
+// AlignSideBySide fills each hunk's Rows, pairing the deleted and added
+// lines of each change in order
+func (f *FileDiff) AlignSideBySide() {
+	for i := range f.Hunks {
+		f.Hunks[i].Rows = sideBySideRows(f.Hunks[i].Lines)
+	}
+}
+
+// sideBySideRows aligns a hunk's lines. git lists a change's deleted lines
+// before its added ones, so the nth deleted line of a run is paired with the
+// nth added line following it.
+func sideBySideRows(lines []DiffLine) []SideBySideRow {
+	rows := make([]SideBySideRow, 0, len(lines))
+	for i := 0; i < len(lines); {
+		if lines[i].Kind == LineContext {
+			rows = append(rows, SideBySideRow{Old: i, New: i})
+			i++
+			continue
+		}
+
+		var deleted, added []int
+		for ; i < len(lines) && lines[i].Kind == LineDeleted; i++ {
+			deleted = append(deleted, i)
+		}
+		for ; i < len(lines) && lines[i].Kind == LineAdded; i++ {
+			added = append(added, i)
+		}
+		for n := 0; n < len(deleted) || n < len(added); n++ {
+			row := SideBySideRow{Old: -1, New: -1}
+			if n < len(deleted) {
+				row.Old = deleted[n]
+			}
+			if n < len(added) {
+				row.New = added[n]
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// token is a word, a run of whitespace or a single other character of a
+// line, with its range in UTF-16 code units
+type token struct {
+	text       string
+	runes      []rune
+	start, end int
+}
+
+// tokenize splits a line into tokens
+func tokenize(line string) []token {
+	var tokens []token
+	runes := []rune(line)
+	offset := 0
+	for i := 0; i < len(runes); {
+		class := runeClass(runes[i])
+		j := i + 1
+		if class != 0 {
+			for j < len(runes) && runeClass(runes[j]) == class {
+				j++
+			}
+		}
+		width := 0
+		for _, r := range runes[i:j] {
+			width += utf16Len(r)
+		}
+		tokens = append(tokens, token{text: string(runes[i:j]), runes: runes[i:j], start: offset, end: offset + width})
+		offset += width
+		i = j
+	}
+	return tokens
+}
+
+// utf16Len is the number of UTF-16 code units encoding r
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2 // Surrogate pair
+	}
+	return 1
+}
+
+// runeClass groups the runes forming multi-rune tokens: 1 for word
+// characters, 2 for whitespace, 0 for anything else (a token by itself)
+func runeClass(r rune) int {
+	switch {
+	case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 1
+	case unicode.IsSpace(r):
+		return 2
+	}
+	return 0
+}
+
+// intralineSpans diffs the tokens of a deleted and an added line and
+// returns the changed spans of each, nil for both if the lines have too
+// little in common (or are too long to compare)
+func intralineSpans(oldLine, newLine string) ([]Span, []Span) {
+	a, b := tokenize(oldLine), tokenize(newLine)
+
+	// Common leading and trailing tokens need no comparison
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix].text == b[prefix].text {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix].text == b[len(b)-1-suffix].text {
+		suffix++
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(midA) == 0 && len(midB) == 0 {
+		return nil, nil
+	}
+	if len(midA)*len(midB) > maxIntralineCells {
+		return nil, nil
+	}
+
+	// lcs[i][j] is the longest common subsequence of midA[i:] and midB[j:]
+	lcs := make([][]int, len(midA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(midB)+1)
+	}
+	for i := len(midA) - 1; i >= 0; i-- {
+		for j := len(midB) - 1; j >= 0; j-- {
+			if midA[i].text == midB[j].text {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// Walk the matches; the tokens between two matches form a change region
+	var oldSpans, newSpans []Span
+	unchanged := 0
+	for _, t := range a[:prefix] {
+		unchanged += t.end - t.start
+	}
+	for _, t := range a[len(a)-suffix:] {
+		unchanged += t.end - t.start
+	}
+	regionA, regionB := 0, 0
+	i, j := 0, 0
+	for i < len(midA) || j < len(midB) {
+		if i < len(midA) && j < len(midB) && midA[i].text == midB[j].text {
+			o, n := changeRegion(midA[regionA:i], midB[regionB:j])
+			oldSpans, newSpans = appendSpan(oldSpans, o), appendSpan(newSpans, n)
+			unchanged += midA[i].end - midA[i].start
+			i++
+			j++
+			regionA, regionB = i, j
+		} else if j >= len(midB) || (i < len(midA) && lcs[i+1][j] >= lcs[i][j+1]) {
+			i++
+		} else {
+			j++
+		}
+	}
+	o, n := changeRegion(midA[regionA:], midB[regionB:])
+	oldSpans, newSpans = appendSpan(oldSpans, o), appendSpan(newSpans, n)
+
+	total := 0
+	if len(a) > 0 {
+		total += a[len(a)-1].end
+	}
+	if len(b) > 0 {
+		total += b[len(b)-1].end
+	}
+	if float64(2*unchanged) < minIntralineSimilarity*float64(total) {
+		return nil, nil
+	}
+	return oldSpans, newSpans
+}
+
+// changeRegion returns the spans of a region of changed tokens on each
+// side. When both sides changed, the characters they start and end with in
+// common are left out, so a one-character edit in a long word shows as
+// that character.
+func changeRegion(a, b []token) (Span, Span) {
+	var oldSpan, newSpan Span
+	if len(a) > 0 {
+		oldSpan = Span{Start: a[0].start, End: a[len(a)-1].end}
+	}
+	if len(b) > 0 {
+		newSpan = Span{Start: b[0].start, End: b[len(b)-1].end}
+	}
+	if len(a) == 0 || len(b) == 0 {
+		return oldSpan, newSpan
+	}
+
+	var ra, rb []rune
+	for _, t := range a {
+		ra = append(ra, t.runes...)
+	}
+	for _, t := range b {
+		rb = append(rb, t.runes...)
+	}
+	p := 0
+	for p < len(ra) && p < len(rb) && ra[p] == rb[p] {
+		oldSpan.Start += utf16Len(ra[p])
+		newSpan.Start += utf16Len(rb[p])
+		p++
+	}
+	for s := 1; s <= len(ra)-p && s <= len(rb)-p && ra[len(ra)-s] == rb[len(rb)-s]; s++ {
+		oldSpan.End -= utf16Len(ra[len(ra)-s])
+		newSpan.End -= utf16Len(rb[len(rb)-s])
+	}
+	return oldSpan, newSpan
+}
+
+// appendSpan appends a non-empty span, merging it into the last one if they
+// touch
+func appendSpan(spans []Span, span Span) []Span {
+	if span.End <= span.Start {
+		return spans
+	}
+	if n := len(spans); n > 0 && spans[n-1].End >= span.Start {
+		spans[n-1].End = span.End
+		return spans
+	}
+	return append(spans, span)
+}
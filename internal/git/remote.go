@@ -0,0 +1,204 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrNoRemote is returned when pushing a repository without remotes
+var ErrNoRemote = errors.New("no remote repository configured")
+
+// ErrNoUpstream is returned when pulling a branch that tracks nothing
+var ErrNoUpstream = errors.New("the current branch has no upstream branch")
+
+// PushRejectedError is returned when the remote refuses a push because it
+// has commits the local branch doesn't
+type PushRejectedError struct {
+	Branch string
+	Reason string // e.g. "non-fast-forward", "fetch first"
+}
+
+func (e *PushRejectedError) Error() string {
+	return fmt.Sprintf("push of %s rejected (%s): the remote has commits that aren't here - pull, then push again", e.Branch, e.Reason)
+}
+
+// PullConflictError is returned when a pull stopped on conflicts. The merge
+// or rebase is left in progress, to be resolved or aborted.
+type PullConflictError struct {
+	Files []string
+}
+
+func (e *PullConflictError) Error() string {
+	return fmt.Sprintf("pull stopped on conflicts in %d file(s)", len(e.Files))
+}
+
+// PullStrategy says how a pull integrates upstream commits
+type PullStrategy string
+
+const (
+	PullMerge  PullStrategy = "merge"
+	PullRebase PullStrategy = "rebase"
+)
+
+// PullResult describes a completed pull
+type PullResult struct {
+	Strategy PullStrategy `json:"strategy"`
+	Before   string       `json:"before"` // HEAD before the pull
+	After    string       `json:"after"`  // HEAD after the pull
+	Pulled   int          `json:"pulled"` // Upstream commits that were new here
+	UpToDate bool         `json:"up_to_date"`
+}
+
+// SyncStatus is a branch's position relative to its upstream
+type SyncStatus struct {
+	Branch   string `json:"branch"`
+	Upstream string `json:"upstream,omitempty"` // Empty without one
+	Ahead    int    `json:"ahead"`
+	Behind   int    `json:"behind"`
+}
+
+// pushRemote returns the remote to push new branches to: origin, or else
+// the first remote
+func (r *Repository) pushRemote() (string, error) {
+	output, err := r.run("remote")
+	if err != nil {
+		return "", err
+	}
+	remotes := strings.Fields(output)
+	if len(remotes) == 0 {
+		return "", ErrNoRemote
+	}
+	for _, remote := range remotes {
+		if remote == "origin" {
+			return remote, nil
+		}
+	}
+	return remotes[0], nil
+}
+
+// PushUpstream pushes branch to origin (or the only remote) and sets it as
+// the branch's upstream
+func (r *Repository) PushUpstream(branch string) error {
+	remote, err := r.pushRemote()
+	if err != nil {
+		return err
+	}
+	if _, err := r.run("push", "--set-upstream", remote, branch); err != nil {
+		return pushError(branch, err)
+	}
+	return nil
+}
+
+// upstream returns the upstream of the current branch, "" without one
+func (r *Repository) upstream() string {
+	output, err := r.run("rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(output)
+}
+
+// GetSyncStatus reports how far the current branch is ahead of and behind
+// its upstream, as of the last fetch
+func (r *Repository) GetSyncStatus() (*SyncStatus, error) {
+	branch, err := r.GetCurrentBranch()
+	if err != nil {
+		return nil, err
+	}
+	status := &SyncStatus{Branch: branch, Upstream: r.upstream()}
+	if status.Upstream == "" {
+		return status, nil
+	}
+
+	output, err := r.run("rev-list", "--left-right", "--count", "HEAD...@{upstream}")
+	if err != nil {
+		return nil, err
+	}
+	if counts := strings.Fields(output); len(counts) == 2 {
+		status.Ahead, _ = strconv.Atoi(counts[0])
+		status.Behind, _ = strconv.Atoi(counts[1])
+	}
+	return status, nil
+}
+
+// Fetch fetches all remotes, pruning deleted remote branches
+func (r *Repository) Fetch() error {
+	if _, err := r.pushRemote(); err != nil {
+		return err
+	}
+	if _, err := r.run("fetch", "--all", "--prune"); err != nil {
+		return fmt.Errorf("failed to fetch: %w", err)
+	}
+	return nil
+}
+
+// Pull fetches and integrates the upstream of the current branch by merge
+// or rebase. Uncommitted changes make it return a *DirtyTreeError; conflicts
+// stop it with a *PullConflictError.
+func (r *Repository) Pull(strategy PullStrategy) (*PullResult, error) {
+	if strategy == "" {
+		strategy = PullMerge
+	}
+	if strategy != PullMerge && strategy != PullRebase {
+		return nil, fmt.Errorf("unknown pull strategy %q", strategy)
+	}
+	if r.upstream() == "" {
+		return nil, ErrNoUpstream
+	}
+
+	files, err := r.DetectChangedFiles()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > 0 {
+		return nil, &DirtyTreeError{Files: files}
+	}
+
+	before, err := r.GetHeadHash()
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"pull", "--no-edit", "--no-rebase"}
+	if strategy == PullRebase {
+		args = []string{"pull", "--rebase"}
+	}
+	if _, err := r.run(args...); err != nil {
+		if conflicts, _ := r.ConflictedFiles(); len(conflicts) > 0 {
+			return nil, &PullConflictError{Files: conflicts}
+		}
+		return nil, fmt.Errorf("failed to pull: %w", err)
+	}
+
+	after, err := r.GetHeadHash()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PullResult{Strategy: strategy, Before: before, After: after, UpToDate: before == after}
+	if output, err := r.run("rev-list", "--count", before+"..@{upstream}"); err == nil {
+		result.Pulled, _ = strconv.Atoi(strings.TrimSpace(output))
+	}
+	return result, nil
+}
+
+// pushError turns a failed push into a *PushRejectedError when the remote
+// refused it for not being a fast-forward
+func pushError(branch string, err error) error {
+	msg := err.Error()
+	if strings.Contains(msg, "[rejected]") {
+		reason := "rejected"
+		switch {
+		case strings.Contains(msg, "non-fast-forward"):
+			reason = "non-fast-forward"
+		case strings.Contains(msg, "fetch first"):
+			reason = "fetch first"
+		case strings.Contains(msg, "stale info"):
+			reason = "stale info"
+		}
+		return &PushRejectedError{Branch: branch, Reason: reason}
+	}
+	return fmt.Errorf("failed to push: %w", err)
+}
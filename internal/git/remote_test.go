@@ -0,0 +1,295 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// isolateGit keeps the user's and system's git configuration out of a test
+// and gives its commits a fixed identity
+func isolateGit(tb testing.TB) {
+	tb.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	tb.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	tb.Setenv("GIT_AUTHOR_NAME", "Finn Test")
+	tb.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	tb.Setenv("GIT_COMMITTER_NAME", "Finn Test")
+	tb.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+}
+
+// gitCmd runs git in dir, failing the test on error
+func gitCmd(tb testing.TB, dir string, args ...string) string {
+	tb.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		tb.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// writeFile writes content to a file of the repository at dir
+func writeFile(tb testing.TB, dir, name, content string) {
+	tb.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		tb.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// commitFile writes a file and commits it
+func commitFile(tb testing.TB, dir, name, content string) {
+	tb.Helper()
+	writeFile(tb, dir, name, content)
+	gitCmd(tb, dir, "add", "--", name)
+	gitCmd(tb, dir, "commit", "-q", "-m", "Update "+name)
+}
+
+// initRepo creates a repository with no remotes and one commit
+func initRepo(tb testing.TB) string {
+	tb.Helper()
+	isolateGit(tb)
+	dir := tb.TempDir()
+	gitCmd(tb, dir, "init", "-q", "-b", "main")
+	commitFile(tb, dir, "README.md", "hello\n")
+	return dir
+}
+
+// remoteFixture is a bare remote with two clones tracking its main branch,
+// standing for this machine and someone else pushing to the same remote
+type remoteFixture struct {
+	remote string
+	local  string
+	other  string
+}
+
+func newRemoteFixture(t *testing.T) *remoteFixture {
+	t.Helper()
+	isolateGit(t)
+	root := t.TempDir()
+	f := &remoteFixture{
+		remote: filepath.Join(root, "remote.git"),
+		local:  filepath.Join(root, "local"),
+		other:  filepath.Join(root, "other"),
+	}
+	gitCmd(t, root, "init", "-q", "--bare", "-b", "main", f.remote)
+	gitCmd(t, root, "clone", "-q", f.remote, f.local)
+	commitFile(t, f.local, "README.md", "hello\n")
+	gitCmd(t, f.local, "push", "-q", "-u", "origin", "main")
+	gitCmd(t, root, "clone", "-q", f.remote, f.other)
+	return f
+}
+
+// push commits a file in the other clone and pushes it
+func (f *remoteFixture) push(t *testing.T, name, content string) {
+	t.Helper()
+	commitFile(t, f.other, name, content)
+	gitCmd(t, f.other, "push", "-q")
+}
+
+func TestFetch(t *testing.T) {
+	f := newRemoteFixture(t)
+	f.push(t, "other.txt", "theirs\n")
+
+	repo := NewRepository(f.local)
+	if err := repo.Fetch(); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	status, err := repo.GetSyncStatus()
+	if err != nil {
+		t.Fatalf("GetSyncStatus: %v", err)
+	}
+	if status.Upstream != "origin/main" || status.Ahead != 0 || status.Behind != 1 {
+		t.Errorf("sync status = %+v, want origin/main, 0 ahead, 1 behind", status)
+	}
+}
+
+func TestFetchNoRemote(t *testing.T) {
+	repo := NewRepository(initRepo(t))
+	if err := repo.Fetch(); !errors.Is(err, ErrNoRemote) {
+		t.Errorf("Fetch = %v, want ErrNoRemote", err)
+	}
+}
+
+func TestPull(t *testing.T) {
+	tests := []struct {
+		strategy PullStrategy
+		parents  int // Parents of HEAD after the pull
+	}{
+		{PullMerge, 2},
+		{PullRebase, 1},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			f := newRemoteFixture(t)
+			f.push(t, "other.txt", "theirs\n")
+			commitFile(t, f.local, "local.txt", "ours\n")
+
+			repo := NewRepository(f.local)
+			result, err := repo.Pull(tt.strategy)
+			if err != nil {
+				t.Fatalf("Pull: %v", err)
+			}
+			if result.Strategy != tt.strategy || result.UpToDate || result.Pulled != 1 {
+				t.Errorf("result = %+v, want %s, 1 pulled", result, tt.strategy)
+			}
+			if parents := strings.Fields(gitCmd(t, f.local, "rev-list", "--parents", "-n", "1", "HEAD")); len(parents)-1 != tt.parents {
+				t.Errorf("HEAD has %d parent(s), want %d", len(parents)-1, tt.parents)
+			}
+			for _, name := range []string{"other.txt", "local.txt"} {
+				if _, err := os.Stat(filepath.Join(f.local, name)); err != nil {
+					t.Errorf("%s missing after the pull: %v", name, err)
+				}
+			}
+			status, err := repo.GetSyncStatus()
+			if err != nil {
+				t.Fatalf("GetSyncStatus: %v", err)
+			}
+			if status.Ahead != tt.parents || status.Behind != 0 {
+				t.Errorf("sync status = %+v, want %d ahead, 0 behind", status, tt.parents)
+			}
+		})
+	}
+}
+
+func TestPullUpToDate(t *testing.T) {
+	f := newRemoteFixture(t)
+	result, err := NewRepository(f.local).Pull(PullMerge)
+	if err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+	if !result.UpToDate || result.Pulled != 0 || result.Before != result.After {
+		t.Errorf("result = %+v, want up to date", result)
+	}
+}
+
+func TestPullConflict(t *testing.T) {
+	tests := []struct {
+		strategy  PullStrategy
+		operation Operation
+	}{
+		{PullMerge, OperationMerge},
+		{PullRebase, OperationRebase},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			f := newRemoteFixture(t)
+			f.push(t, "README.md", "theirs\n")
+			commitFile(t, f.local, "README.md", "ours\n")
+
+			repo := NewRepository(f.local)
+			_, err := repo.Pull(tt.strategy)
+			var conflictErr *PullConflictError
+			if !errors.As(err, &conflictErr) {
+				t.Fatalf("Pull = %v, want *PullConflictError", err)
+			}
+			if len(conflictErr.Files) != 1 || conflictErr.Files[0] != "README.md" {
+				t.Errorf("conflicted files = %v, want [README.md]", conflictErr.Files)
+			}
+			if op := repo.OperationInProgress(); op != tt.operation {
+				t.Errorf("operation in progress = %q, want %q", op, tt.operation)
+			}
+		})
+	}
+}
+
+func TestPullDirtyTree(t *testing.T) {
+	f := newRemoteFixture(t)
+	f.push(t, "other.txt", "theirs\n")
+	writeFile(t, f.local, "README.md", "uncommitted\n")
+
+	_, err := NewRepository(f.local).Pull(PullMerge)
+	var dirtyErr *DirtyTreeError
+	if !errors.As(err, &dirtyErr) {
+		t.Fatalf("Pull = %v, want *DirtyTreeError", err)
+	}
+	if len(dirtyErr.Files) != 1 || dirtyErr.Files[0] != "README.md" {
+		t.Errorf("dirty files = %v, want [README.md]", dirtyErr.Files)
+	}
+}
+
+func TestPullNoUpstream(t *testing.T) {
+	f := newRemoteFixture(t)
+	gitCmd(t, f.local, "switch", "-q", "-c", "feature")
+
+	if _, err := NewRepository(f.local).Pull(PullMerge); !errors.Is(err, ErrNoUpstream) {
+		t.Errorf("Pull = %v, want ErrNoUpstream", err)
+	}
+}
+
+func TestPush(t *testing.T) {
+	f := newRemoteFixture(t)
+	commitFile(t, f.local, "local.txt", "ours\n")
+
+	repo := NewRepository(f.local)
+	if err := repo.Push(); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	head := gitCmd(t, f.local, "rev-parse", "HEAD")
+	if remote := gitCmd(t, f.remote, "rev-parse", "main"); remote != head {
+		t.Errorf("remote main = %s, want %s", remote, head)
+	}
+}
+
+func TestPushUpstream(t *testing.T) {
+	f := newRemoteFixture(t)
+	gitCmd(t, f.local, "switch", "-q", "-c", "feature")
+	commitFile(t, f.local, "feature.txt", "feature\n")
+
+	repo := NewRepository(f.local)
+	if err := repo.PushUpstream("feature"); err != nil {
+		t.Fatalf("PushUpstream: %v", err)
+	}
+	if upstream := repo.upstream(); upstream != "origin/feature" {
+		t.Errorf("upstream = %q, want origin/feature", upstream)
+	}
+	head := gitCmd(t, f.local, "rev-parse", "HEAD")
+	if remote := gitCmd(t, f.remote, "rev-parse", "feature"); remote != head {
+		t.Errorf("remote feature = %s, want %s", remote, head)
+	}
+
+	// A branch without an upstream gets one on its first Push
+	gitCmd(t, f.local, "switch", "-q", "-c", "second")
+	if err := repo.Push(); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if upstream := repo.upstream(); upstream != "origin/second" {
+		t.Errorf("upstream = %q, want origin/second", upstream)
+	}
+}
+
+func TestPushRejected(t *testing.T) {
+	f := newRemoteFixture(t)
+	f.push(t, "other.txt", "theirs\n")
+	commitFile(t, f.local, "local.txt", "ours\n")
+
+	repo := NewRepository(f.local)
+	if err := repo.Fetch(); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	err := repo.Push()
+	var rejectedErr *PushRejectedError
+	if !errors.As(err, &rejectedErr) {
+		t.Fatalf("Push = %v, want *PushRejectedError", err)
+	}
+	if rejectedErr.Branch != "main" || rejectedErr.Reason != "non-fast-forward" {
+		t.Errorf("rejection = %+v, want main, non-fast-forward", rejectedErr)
+	}
+}
+
+func TestPushNoRemote(t *testing.T) {
+	repo := NewRepository(initRepo(t))
+	if err := repo.Push(); !errors.Is(err, ErrNoRemote) {
+		t.Errorf("Push = %v, want ErrNoRemote", err)
+	}
+	if err := repo.PushUpstream("main"); !errors.Is(err, ErrNoRemote) {
+		t.Errorf("PushUpstream = %v, want ErrNoRemote", err)
+	}
+}
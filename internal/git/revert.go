@@ -0,0 +1,108 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrPublishedHistory is returned when an operation would rewrite pushed commits
+var ErrPublishedHistory = errors.New("the commit has been pushed - rewriting published history is not allowed")
+
+// ResolveCommit returns the full hash of a commit
+func (r *Repository) ResolveCommit(hash string) (string, error) {
+	if hash == "" || strings.HasPrefix(hash, "-") {
+		return "", fmt.Errorf("invalid commit %q", hash)
+	}
+	output, err := r.run("rev-parse", "--verify", "--quiet", hash+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("commit %s not found", hash)
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// IsPushed reports whether a commit is on any remote-tracking branch
+func (r *Repository) IsPushed(hash string) (bool, error) {
+	output, err := r.run("branch", "--remotes", "--contains", hash)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(output) != "", nil
+}
+
+// IsAncestor reports whether a commit is reachable from HEAD
+func (r *Repository) IsAncestor(hash string) bool {
+	_, err := r.run("merge-base", "--is-ancestor", hash, "HEAD")
+	return err == nil
+}
+
+// RevertingCommit returns the full hash of the commit a revert in progress
+// (stopped on conflicts) is undoing, "" if no revert is in progress
+func (r *Repository) RevertingCommit() string {
+	output, err := r.run("rev-parse", "--verify", "--quiet", "REVERT_HEAD")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(output)
+}
+
+// Revert creates a commit undoing hash (against its first parent for a
+// merge). Uncommitted changes make it return a *DirtyTreeError. On conflicts
+// the revert is left in progress and its conflicts are returned.
+func (r *Repository) Revert(hash string) (*ConflictState, error) {
+	full, err := r.ResolveCommit(hash)
+	if err != nil {
+		return nil, err
+	}
+	if op := r.OperationInProgress(); op != OperationNone {
+		return nil, fmt.Errorf("a %s is in progress - finish or abort it first", op)
+	}
+	files, err := r.DetectChangedFiles()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > 0 {
+		return nil, &DirtyTreeError{Files: files}
+	}
+
+	args := []string{"revert", "--no-edit"}
+	if parents, err := r.run("rev-list", "--parents", "-n", "1", full); err == nil && len(strings.Fields(parents)) > 2 {
+		args = append(args, "-m", "1")
+	}
+	if _, err := r.run(append(args, full)...); err != nil {
+		if conflicts, _ := r.GetConflicts(); conflicts != nil {
+			return conflicts, nil
+		}
+		return nil, fmt.Errorf("failed to revert: %w", err)
+	}
+	return nil, nil
+}
+
+// UndoHeadCommit removes HEAD, which must be hash, with a soft reset: its
+// changes stay staged. Refused once the commit has been pushed.
+func (r *Repository) UndoHeadCommit(hash string) error {
+	full, err := r.ResolveCommit(hash)
+	if err != nil {
+		return err
+	}
+	head, err := r.GetHeadHash()
+	if err != nil {
+		return err
+	}
+	if head != full {
+		return fmt.Errorf("commit %s is not the latest commit", hash)
+	}
+	if pushed, err := r.IsPushed(full); err != nil {
+		return err
+	} else if pushed {
+		return ErrPublishedHistory
+	}
+	if _, err := r.ResolveCommit(full + "~1"); err != nil {
+		return fmt.Errorf("can't undo the first commit of the repository")
+	}
+
+	if _, err := r.run("reset", "--soft", full+"~1"); err != nil {
+		return fmt.Errorf("failed to undo commit: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package git
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ChangeKind is how a path differs from HEAD
+type ChangeKind string
+
+const (
+	ChangeAdded       ChangeKind = "added"
+	ChangeModified    ChangeKind = "modified"
+	ChangeDeleted     ChangeKind = "deleted"
+	ChangeRenamed     ChangeKind = "renamed"
+	ChangeCopied      ChangeKind = "copied"
+	ChangeTypeChanged ChangeKind = "type_changed" // e.g. a file replaced by a symlink
+	ChangeUntracked   ChangeKind = "untracked"
+	ChangeUnmerged    ChangeKind = "unmerged"
+)
+
+// FileStatus is one changed path from git status
+type FileStatus struct {
+	Path       string     `json:"path"`
+	OrigPath   string     `json:"orig_path,omitempty"` // Source of a rename or copy
+	Kind       ChangeKind `json:"kind"`
+	Staged     bool       `json:"staged"`               // The index differs from HEAD
+	Unstaged   bool       `json:"unstaged"`             // The working tree differs from the index
+	OldMode    string     `json:"old_mode,omitempty"`   // Set with NewMode when the file mode changed
+	NewMode    string     `json:"new_mode,omitempty"`   // e.g. "100755"
+	Similarity int        `json:"similarity,omitempty"` // Rename or copy score, in percent
+}
+
+// ModeChanged reports whether the file mode changed (e.g. made executable)
+func (f FileStatus) ModeChanged() bool {
+	return f.OldMode != "" && f.OldMode != f.NewMode
+}
+
+// Status lists every changed path, untracked files included, in a single
+// git status pass. Paths are exact: NUL-separated, never quoted.
+func (r *Repository) Status() ([]FileStatus, error) {
+	// --no-optional-locks: don't refresh the index under the user's feet
+	output, err := r.run("--no-optional-locks", "status", "--porcelain=v2", "-z", "--untracked-files=all", "--find-renames")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+	return parseStatusV2(output), nil
+}
+
+// parseStatusV2 parses git status --porcelain=v2 -z output
+func parseStatusV2(output string) []FileStatus {
+	var files []FileStatus
+	entries := strings.Split(output, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 3 {
+			continue
+		}
+
+		switch entry[0] {
+		case '1':
+			// 1 <XY> <sub> <mH> <mI> <mW> <hH> <hI> <path>
+			fields := strings.SplitN(entry, " ", 9)
+			if len(fields) == 9 {
+				files = append(files, trackedStatus(fields[1], fields[3], fields[5], fields[8]))
+			}
+		case '2':
+			// 2 <XY> <sub> <mH> <mI> <mW> <hH> <hI> <X><score> <path>\0<origPath>
+			fields := strings.SplitN(entry, " ", 10)
+			if len(fields) != 10 || i+1 >= len(entries) {
+				continue
+			}
+			i++
+			status := trackedStatus(fields[1], fields[3], fields[5], fields[9])
+			status.OrigPath = entries[i]
+			status.Similarity, _ = strconv.Atoi(fields[8][1:])
+			files = append(files, status)
+		case 'u':
+			// u <XY> <sub> <m1> <m2> <m3> <mW> <h1> <h2> <h3> <path>
+			fields := strings.SplitN(entry, " ", 11)
+			if len(fields) == 11 {
+				files = append(files, FileStatus{Path: fields[10], Kind: ChangeUnmerged, Staged: true, Unstaged: true})
+			}
+		case '?':
+			files = append(files, FileStatus{Path: entry[2:], Kind: ChangeUntracked, Unstaged: true})
+		}
+	}
+	return files
+}
+
+// trackedStatus builds the status of a tracked path from its XY code and
+// HEAD and working tree modes
+func trackedStatus(xy, headMode, worktreeMode, path string) FileStatus {
+	x, y := xy[0], xy[1]
+	status := FileStatus{Path: path, Kind: ChangeModified, Staged: x != '.', Unstaged: y != '.'}
+
+	switch {
+	case x == 'R':
+		status.Kind = ChangeRenamed
+	case x == 'C':
+		status.Kind = ChangeCopied
+	case x == 'D' || y == 'D':
+		status.Kind = ChangeDeleted
+	case x == 'A':
+		status.Kind = ChangeAdded
+	case x == 'T' || y == 'T':
+		status.Kind = ChangeTypeChanged
+	}
+
+	// Mode "000000" means the path doesn't exist on that side
+	if headMode != worktreeMode && headMode != "000000" && worktreeMode != "000000" {
+		status.OldMode = headMode
+		status.NewMode = worktreeMode
+	}
+	return status
+}
+
+// GenerateDiffs returns the diff against HEAD of each of paths, keyed by
+// path (the new path of a rename or copy); unchanged paths are left out
+func (r *Repository) GenerateDiffs(paths []string) (map[string]string, error) {
+	files, err := r.GenerateFileDiffs(paths)
+	if err != nil {
+		return nil, err
+	}
+	diffs := make(map[string]string, len(files))
+	for _, file := range files {
+		diffs[file.Path] = file.Text
+	}
+	return diffs, nil
+}
+
+// GenerateFileDiffs diffs paths against HEAD in one git diff run. The paths
+// are staged into a temporary index, so untracked files show as new files
+// and renames are detected, while the real index and object store stay
+// untouched. Unchanged paths are left out.
+func (r *Repository) GenerateFileDiffs(paths []string) ([]FileDiff, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	wanted := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		wanted[path] = true
+	}
+
+	// Only changed paths can be staged (git refuses missing and ignored
+	// ones); a rename needs its source staged too to be detected
+	statuses, err := r.Status()
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for _, status := range statuses {
+		if !wanted[status.Path] && !wanted[status.OrigPath] {
+			continue
+		}
+		changed = append(changed, status.Path)
+		if status.OrigPath != "" && status.Kind == ChangeRenamed {
+			changed = append(changed, status.OrigPath)
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	index, err := r.newTempIndex()
+	if err != nil {
+		return nil, err
+	}
+	defer index.Remove()
+	if err := index.isolateObjects(); err != nil {
+		return nil, err
+	}
+
+	if head, err := r.GetHeadHash(); err == nil {
+		if _, err := index.run("", "read-tree", head); err != nil {
+			return nil, err
+		}
+	}
+
+	// Stage exactly the requested paths (NUL-separated on stdin, no length limit)
+	pathspecs := strings.Join(changed, "\x00") + "\x00"
+	if _, err := index.run(pathspecs, "add", "--all", "--ignore-errors", "--pathspec-from-file=-", "--pathspec-file-nul"); err != nil {
+		return nil, fmt.Errorf("failed to stage changes for diff: %w", err)
+	}
+
+	output, err := index.run("", "diff", "--cached", "--no-color", "--no-ext-diff", "--find-renames", "--find-copies")
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate diffs: %w", err)
+	}
+
+	var files []FileDiff
+	for _, file := range ParseDiff(output) {
+		if wanted[file.Path] || (file.OldPath != "" && wanted[file.OldPath]) {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,292 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// statusEntry builds a porcelain v2 entry of a tracked path
+func statusEntry(kind, xy, modes, rest string) string {
+	hash := strings.Repeat("a", 40)
+	return fmt.Sprintf("%s %s N... %s %s %s %s", kind, xy, modes, hash, hash, rest)
+}
+
+func TestParseStatusV2(t *testing.T) {
+	hash := strings.Repeat("b", 40)
+	tests := []struct {
+		name   string
+		output string
+		want   FileStatus
+	}{
+		{
+			name:   "modified path with spaces",
+			output: statusEntry("1", ".M", "100644 100644 100644", "docs/read me.md"),
+			want:   FileStatus{Path: "docs/read me.md", Kind: ChangeModified, Unstaged: true},
+		},
+		{
+			name:   "staged delete",
+			output: statusEntry("1", "D.", "100644 000000 000000", "gone.txt"),
+			want:   FileStatus{Path: "gone.txt", Kind: ChangeDeleted, Staged: true},
+		},
+		{
+			name:   "unstaged delete",
+			output: statusEntry("1", ".D", "100644 100644 000000", "gone.txt"),
+			want:   FileStatus{Path: "gone.txt", Kind: ChangeDeleted, Unstaged: true},
+		},
+		{
+			name:   "mode only",
+			output: statusEntry("1", ".M", "100644 100644 100755", "run.sh"),
+			want:   FileStatus{Path: "run.sh", Kind: ChangeModified, Unstaged: true, OldMode: "100644", NewMode: "100755"},
+		},
+		{
+			name:   "rename with quotes",
+			output: statusEntry("2", "R.", "100644 100644 100644", `R87 new "name".txt`) + "\x00" + `old "name".txt`,
+			want:   FileStatus{Path: `new "name".txt`, OrigPath: `old "name".txt`, Kind: ChangeRenamed, Staged: true, Similarity: 87},
+		},
+		{
+			name:   "unmerged",
+			output: fmt.Sprintf("u UU N... 100644 100644 100644 100644 %s %s %s conflict.txt", hash, hash, hash),
+			want:   FileStatus{Path: "conflict.txt", Kind: ChangeUnmerged, Staged: true, Unstaged: true},
+		},
+		{
+			name:   "untracked non-ASCII",
+			output: "? café/naïve 文件.txt",
+			want:   FileStatus{Path: "café/naïve 文件.txt", Kind: ChangeUntracked, Unstaged: true},
+		},
+		{
+			name:   "untracked leading dash",
+			output: "? -rf",
+			want:   FileStatus{Path: "-rf", Kind: ChangeUntracked, Unstaged: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseStatusV2(tt.output + "\x00")
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("parseStatusV2 = %+v, want [%+v]", got, tt.want)
+			}
+		})
+	}
+}
+
+// oddPaths are file names that trip up naive parsing: spaces, quotes,
+// non-ASCII characters and a leading dash
+var oddPaths = []string{"read me.md", `say "hi".txt`, "café/naïve 文件.txt", "-rf"}
+
+// newStatusRepo creates a repository with one change of each kind, and
+// returns it and the expected status of each changed path
+func newStatusRepo(t *testing.T) (string, map[string]FileStatus) {
+	t.Helper()
+	dir := initRepo(t)
+	for _, path := range oddPaths {
+		writeFile(t, dir, path, "original\n")
+	}
+	writeFile(t, dir, "run.sh", "echo hi\n")
+	writeFile(t, dir, "gone.txt", "bye\n")
+	writeFile(t, dir, "old name.txt", strings.Repeat("a line that stays the same\n", 10))
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-q", "-m", "Add files")
+
+	want := map[string]FileStatus{}
+	for _, path := range oddPaths {
+		writeFile(t, dir, path, "changed\n")
+		want[path] = FileStatus{Path: path, Kind: ChangeModified, Unstaged: true}
+	}
+	if err := os.Chmod(filepath.Join(dir, "run.sh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	want["run.sh"] = FileStatus{Path: "run.sh", Kind: ChangeModified, Unstaged: true, OldMode: "100644", NewMode: "100755"}
+	if err := os.Remove(filepath.Join(dir, "gone.txt")); err != nil {
+		t.Fatal(err)
+	}
+	want["gone.txt"] = FileStatus{Path: "gone.txt", Kind: ChangeDeleted, Unstaged: true}
+	gitCmd(t, dir, "mv", "--", "old name.txt", "new -name.txt")
+	want["new -name.txt"] = FileStatus{Path: "new -name.txt", OrigPath: "old name.txt", Kind: ChangeRenamed, Staged: true, Similarity: 100}
+	writeFile(t, dir, "-new ünïcode.txt", "new\n")
+	want["-new ünïcode.txt"] = FileStatus{Path: "-new ünïcode.txt", Kind: ChangeUntracked, Unstaged: true}
+	return dir, want
+}
+
+func TestStatus(t *testing.T) {
+	dir, want := newStatusRepo(t)
+
+	statuses, err := NewRepository(dir).Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if len(statuses) != len(want) {
+		t.Errorf("Status returned %d paths, want %d: %+v", len(statuses), len(want), statuses)
+	}
+	for _, status := range statuses {
+		if status != want[status.Path] {
+			t.Errorf("status = %+v, want %+v", status, want[status.Path])
+		}
+	}
+}
+
+func TestStatusUnmerged(t *testing.T) {
+	dir := initRepo(t)
+	gitCmd(t, dir, "switch", "-q", "-c", "theirs")
+	commitFile(t, dir, "README.md", "theirs\n")
+	gitCmd(t, dir, "switch", "-q", "main")
+	commitFile(t, dir, "README.md", "ours\n")
+	cmd := exec.Command("git", "merge", "theirs")
+	cmd.Dir = dir
+	if err := cmd.Run(); err == nil {
+		t.Fatal("merge succeeded, want a conflict")
+	}
+
+	statuses, err := NewRepository(dir).Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	want := FileStatus{Path: "README.md", Kind: ChangeUnmerged, Staged: true, Unstaged: true}
+	if len(statuses) != 1 || statuses[0] != want {
+		t.Errorf("Status = %+v, want [%+v]", statuses, want)
+	}
+}
+
+func TestGenerateFileDiffs(t *testing.T) {
+	dir, statuses := newStatusRepo(t)
+	var paths []string
+	for path, status := range statuses {
+		paths = append(paths, path)
+		if status.OrigPath != "" {
+			paths = append(paths, status.OrigPath)
+		}
+	}
+	objectsBefore := gitCmd(t, dir, "count-objects")
+
+	files, err := NewRepository(dir).GenerateFileDiffs(paths)
+	if err != nil {
+		t.Fatalf("GenerateFileDiffs: %v", err)
+	}
+
+	want := map[string]FileDiff{
+		"run.sh":           {Path: "run.sh", Kind: ChangeModified, OldMode: "100644", NewMode: "100755"},
+		"gone.txt":         {Path: "gone.txt", Kind: ChangeDeleted, Deletions: 1},
+		"new -name.txt":    {Path: "new -name.txt", OldPath: "old name.txt", Kind: ChangeRenamed, Similarity: 100},
+		"-new ünïcode.txt": {Path: "-new ünïcode.txt", Kind: ChangeAdded, Additions: 1},
+	}
+	for _, path := range oddPaths {
+		want[path] = FileDiff{Path: path, Kind: ChangeModified, Additions: 1, Deletions: 1}
+	}
+	if len(files) != len(want) {
+		t.Errorf("GenerateFileDiffs returned %d files, want %d", len(files), len(want))
+	}
+	for _, file := range files {
+		w, ok := want[file.Path]
+		if !ok {
+			t.Errorf("unexpected diff of %q", file.Path)
+			continue
+		}
+		if file.OldPath != w.OldPath || file.Kind != w.Kind || file.OldMode != w.OldMode || file.NewMode != w.NewMode ||
+			file.Similarity != w.Similarity || file.Additions != w.Additions || file.Deletions != w.Deletions {
+			t.Errorf("diff of %q = %+v, want %+v", file.Path, file, w)
+		}
+		if !strings.Contains(file.Text, "diff --git") {
+			t.Errorf("diff of %q has no text", file.Path)
+		}
+	}
+
+	// The temporary index's blobs must not stay behind in the repository
+	if objectsAfter := gitCmd(t, dir, "count-objects"); objectsAfter != objectsBefore {
+		t.Errorf("objects went from %q to %q", objectsBefore, objectsAfter)
+	}
+}
+
+const (
+	benchFiles     = 5000 // Committed files, spread over directories
+	benchModified  = 500
+	benchDeleted   = 100
+	benchRenamed   = 100
+	benchUntracked = 300
+)
+
+// benchFileContent is a few dozen lines of source for file i
+func benchFileContent(i int) string {
+	var b strings.Builder
+	for line := 0; line < 40; line++ {
+		fmt.Fprintf(&b, "func f%d_%d() int { return %d }\n", i, line, i*line)
+	}
+	return b.String()
+}
+
+func benchFileName(i int) string {
+	return fmt.Sprintf("pkg%02d/file%04d.go", i%50, i)
+}
+
+// newBenchRepo creates a repository of benchFiles committed files, then
+// modifies, deletes and renames some and adds untracked ones. Returns its
+// path and every changed path.
+func newBenchRepo(b *testing.B) (string, []string) {
+	b.Helper()
+	dir := initRepo(b)
+	for i := 0; i < benchFiles; i++ {
+		writeFile(b, dir, benchFileName(i), benchFileContent(i))
+	}
+	gitCmd(b, dir, "add", "-A")
+	gitCmd(b, dir, "commit", "-q", "-m", "Add files")
+
+	var changed []string
+	i := 0
+	for n := 0; n < benchModified; n, i = n+1, i+1 {
+		writeFile(b, dir, benchFileName(i), benchFileContent(i)+"// modified\n")
+		changed = append(changed, benchFileName(i))
+	}
+	for n := 0; n < benchDeleted; n, i = n+1, i+1 {
+		if err := os.Remove(filepath.Join(dir, benchFileName(i))); err != nil {
+			b.Fatal(err)
+		}
+		changed = append(changed, benchFileName(i))
+	}
+	for n := 0; n < benchRenamed; n, i = n+1, i+1 {
+		renamed := strings.TrimSuffix(benchFileName(i), ".go") + "_renamed.go"
+		if err := os.Rename(filepath.Join(dir, benchFileName(i)), filepath.Join(dir, renamed)); err != nil {
+			b.Fatal(err)
+		}
+		changed = append(changed, benchFileName(i), renamed)
+	}
+	for n := 0; n < benchUntracked; n++ {
+		name := fmt.Sprintf("new/file%04d.go", n)
+		writeFile(b, dir, name, benchFileContent(benchFiles+n))
+		changed = append(changed, name)
+	}
+	return dir, changed
+}
+
+func BenchmarkStatus(b *testing.B) {
+	dir, _ := newBenchRepo(b)
+	repo := NewRepository(dir)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		statuses, err := repo.Status()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(statuses) == 0 {
+			b.Fatal("no changes reported")
+		}
+	}
+}
+
+func BenchmarkGenerateFileDiffs(b *testing.B) {
+	dir, changed := newBenchRepo(b)
+	repo := NewRepository(dir)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		diffs, err := repo.GenerateFileDiffs(changed)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(diffs) == 0 {
+			b.Fatal("no diffs generated")
+		}
+	}
+}
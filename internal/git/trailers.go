@@ -0,0 +1,87 @@
+package git
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Trailer keys Finn writes to the commits it makes
+const (
+	TrailerConversation = "Finn-Conversation" // Finn conversation that produced the commit
+	TrailerSession      = "Claude-Session"    // Claude session the changes were made in
+	TrailerCoAuthor     = "Co-authored-by"
+)
+
+// Trailer is a "Key: value" line at the end of a commit message
+type Trailer struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+var trailerLine = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9-]*):\s+(.+)$`)
+
+// ParseTrailers returns the trailers of a commit message: its last
+// paragraph, when every line of it is a trailer. The subject line alone is
+// never a trailer block.
+func ParseTrailers(message string) []Trailer {
+	paragraphs := strings.Split(strings.TrimSpace(strings.ReplaceAll(message, "\r\n", "\n")), "\n\n")
+	if len(paragraphs) < 2 {
+		return nil
+	}
+
+	var trailers []Trailer
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		m := trailerLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			return nil
+		}
+		trailers = append(trailers, Trailer{Key: m[1], Value: strings.TrimSpace(m[2])})
+	}
+	return trailers
+}
+
+// AppendTrailers adds trailers to a commit message, joining its trailer
+// block if it already ends in one. Trailers without a value or already
+// present are skipped.
+func AppendTrailers(message string, trailers []Trailer) string {
+	message = strings.TrimRight(message, "\n\t ")
+	existing := ParseTrailers(message)
+
+	var lines []string
+	for _, t := range trailers {
+		if t.Key == "" || strings.TrimSpace(t.Value) == "" || hasTrailer(existing, t) {
+			continue
+		}
+		lines = append(lines, t.Key+": "+strings.TrimSpace(t.Value))
+		existing = append(existing, t)
+	}
+	if len(lines) == 0 {
+		return message
+	}
+
+	separator := "\n\n"
+	if len(ParseTrailers(message)) > 0 {
+		separator = "\n"
+	}
+	return message + separator + strings.Join(lines, "\n")
+}
+
+// TrailerValue returns the value of the first trailer with key (matched
+// case-insensitively, as git does), "" without one
+func TrailerValue(trailers []Trailer, key string) string {
+	for _, t := range trailers {
+		if strings.EqualFold(t.Key, key) {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+func hasTrailer(trailers []Trailer, t Trailer) bool {
+	for _, existing := range trailers {
+		if strings.EqualFold(existing.Key, t.Key) && existing.Value == strings.TrimSpace(t.Value) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,8 @@ package llm
 
 import (
 	"fmt"
+
+	"github.com/getfinn/finn/internal/event"
 )
 
 // DefaultFactory is the default executor factory implementation.
@@ -84,7 +86,7 @@ func GetFactory() *DefaultFactory {
 // Quick helper functions for common use cases
 
 // NewExecutor creates a one-shot executor using the global factory.
-func NewExecutor(provider Provider, projectPath string, onEvent EventHandler) (Executor, error) {
+func NewExecutor(provider Provider, projectPath string, onEvent event.Handler) (Executor, error) {
 	return GetFactory().CreateExecutor(Config{
 		Provider:    provider,
 		ProjectPath: projectPath,
@@ -93,7 +95,7 @@ func NewExecutor(provider Provider, projectPath string, onEvent EventHandler) (E
 }
 
 // NewInteractiveExecutor creates an interactive executor using the global factory.
-func NewInteractiveExecutor(provider Provider, projectPath string, onEvent EventHandler) (InteractiveExecutor, error) {
+func NewInteractiveExecutor(provider Provider, projectPath string, onEvent event.Handler) (InteractiveExecutor, error) {
 	return GetFactory().CreateInteractiveExecutor(Config{
 		Provider:    provider,
 		ProjectPath: projectPath,
@@ -20,16 +20,8 @@ type Executor struct {
 
 // NewExecutor creates a new Claude executor.
 func NewExecutor(cfg llm.Config) (llm.Executor, error) {
-	// Convert llm.EventHandler to claude.EventHandler
-	claudeHandler := func(e claude.Event) {
-		cfg.OnEvent(llm.Event{
-			Type:    llm.EventType(e.Type),
-			Content: e.Content,
-		})
-	}
-
 	return &Executor{
-		inner: claude.NewTaskExecutor(cfg.ProjectPath, false, claudeHandler),
+		inner: claude.NewTaskExecutor(cfg.ProjectPath, false, cfg.OnEvent),
 	}, nil
 }
 
@@ -51,16 +43,8 @@ type InteractiveExecutor struct {
 
 // NewInteractiveExecutor creates a new Claude interactive executor.
 func NewInteractiveExecutor(cfg llm.Config) (llm.InteractiveExecutor, error) {
-	// Convert llm.EventHandler to claude.EventHandler
-	claudeHandler := func(e claude.Event) {
-		cfg.OnEvent(llm.Event{
-			Type:    llm.EventType(e.Type),
-			Content: e.Content,
-		})
-	}
-
 	return &InteractiveExecutor{
-		inner: claude.NewInteractiveTaskExecutor(cfg.ProjectPath, claudeHandler),
+		inner: claude.NewInteractiveTaskExecutor(cfg.ProjectPath, cfg.OnEvent),
 	}, nil
 }
 
@@ -13,6 +13,7 @@ package codex
 import (
 	"fmt"
 
+	"github.com/getfinn/finn/internal/event"
 	"github.com/getfinn/finn/internal/llm"
 )
 
@@ -26,7 +27,7 @@ func init() {
 // Executor implements llm.Executor for OpenAI Codex/GPT-4 API.
 type Executor struct {
 	projectPath string
-	onEvent     llm.EventHandler
+	onEvent     event.Handler
 	apiKey      string
 	model       string
 }
@@ -72,7 +73,7 @@ func (e *Executor) Provider() llm.Provider {
 // InteractiveExecutor implements llm.InteractiveExecutor for OpenAI API.
 type InteractiveExecutor struct {
 	projectPath      string
-	onEvent          llm.EventHandler
+	onEvent          event.Handler
 	apiKey           string
 	model            string
 	conversationID   string
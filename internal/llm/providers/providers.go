@@ -20,4 +20,5 @@ import (
 	_ "github.com/getfinn/finn/internal/llm/providers/claude"
 	_ "github.com/getfinn/finn/internal/llm/providers/codex"
 	_ "github.com/getfinn/finn/internal/llm/providers/gemini"
+	_ "github.com/getfinn/finn/internal/llm/providers/replay"
 )
@@ -0,0 +1,143 @@
+// Package replay provides an LLM executor that plays back a transcript recorded
+// from a real Claude Code session (see claude.Recorder). It drives the same
+// stream handling as the Claude provider, so diffs, decisions and approvals can
+// be exercised offline and in CI without a Claude subscription. A session
+// resumed during the recording is resumed the same way during playback; a
+// resume beyond what was recorded fails.
+//
+// Configuration is passed through llm.Config.ExtraConfig:
+//   - "transcript": path to the recorded .jsonl transcript (required)
+//   - "speed": playback speed multiplier, "1" for original timing, "0" for no delays
+package replay
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/getfinn/finn/internal/claude"
+	"github.com/getfinn/finn/internal/llm"
+)
+
+func init() {
+	// Register Replay provider with the global factory
+	factory := llm.GetFactory()
+	factory.RegisterExecutor(llm.ProviderReplay, NewExecutor)
+	factory.RegisterInteractiveExecutor(llm.ProviderReplay, NewInteractiveExecutor)
+}
+
+// newReplayer loads the transcript named in the config
+func newReplayer(cfg llm.Config) (*claude.Replayer, error) {
+	path := cfg.ExtraConfig["transcript"]
+	if path == "" {
+		return nil, fmt.Errorf("replay requires a transcript path (ExtraConfig[\"transcript\"])")
+	}
+
+	speed := 1.0
+	if s := cfg.ExtraConfig["speed"]; s != "" {
+		parsed, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid replay speed %q: %w", s, err)
+		}
+		speed = parsed
+	}
+
+	return claude.NewReplayer(path, speed)
+}
+
+// Executor replays a transcript to completion as a one-shot task.
+type Executor struct {
+	inner *claude.InteractiveTaskExecutor
+}
+
+// NewExecutor creates a new one-shot replay executor.
+func NewExecutor(cfg llm.Config) (llm.Executor, error) {
+	replayer, err := newReplayer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Executor{
+		inner: claude.NewReplayTaskExecutor(cfg.ProjectPath, replayer, cfg.OnEvent),
+	}, nil
+}
+
+// ExecuteTask replays the transcript and blocks until playback finishes.
+func (e *Executor) ExecuteTask(prompt string) error {
+	if err := e.inner.ExecuteTask(prompt); err != nil {
+		return err
+	}
+	e.inner.Wait()
+	return nil
+}
+
+// Provider returns the provider type.
+func (e *Executor) Provider() llm.Provider {
+	return llm.ProviderReplay
+}
+
+// InteractiveExecutor replays a transcript turn by turn, pausing wherever the
+// recording waited for user input.
+type InteractiveExecutor struct {
+	inner   *claude.InteractiveTaskExecutor
+	running bool
+}
+
+// NewInteractiveExecutor creates a new interactive replay executor.
+func NewInteractiveExecutor(cfg llm.Config) (llm.InteractiveExecutor, error) {
+	replayer, err := newReplayer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InteractiveExecutor{
+		inner: claude.NewReplayTaskExecutor(cfg.ProjectPath, replayer, cfg.OnEvent),
+	}, nil
+}
+
+// ExecuteTask starts playback with the given prompt.
+func (e *InteractiveExecutor) ExecuteTask(prompt string) error {
+	return e.Start(prompt)
+}
+
+// Provider returns the provider type.
+func (e *InteractiveExecutor) Provider() llm.Provider {
+	return llm.ProviderReplay
+}
+
+// Start begins playback.
+func (e *InteractiveExecutor) Start(initialPrompt string) error {
+	e.running = true
+	return e.inner.ExecuteTask(initialPrompt)
+}
+
+// SendChoice releases playback past the next recorded user message.
+func (e *InteractiveExecutor) SendChoice(choiceID string) error {
+	return e.inner.SendMessage(choiceID)
+}
+
+// SendFollowUp releases playback past the next recorded user message.
+func (e *InteractiveExecutor) SendFollowUp(prompt string) error {
+	return e.inner.SendMessage(prompt)
+}
+
+// ResumeSession starts playback as if resuming the recorded session.
+func (e *InteractiveExecutor) ResumeSession(sessionID string, prompt string) error {
+	e.running = true
+	return e.inner.ResumeSession(sessionID, prompt)
+}
+
+// Stop ends playback.
+func (e *InteractiveExecutor) Stop() {
+	e.running = false
+	_ = e.inner.Stop() // Ignore error - best effort cleanup
+}
+
+// IsRunning returns whether playback is active.
+func (e *InteractiveExecutor) IsRunning() bool {
+	return e.running
+}
+
+// SetSessionLinkedHandler sets callback for session ID detection.
+func (e *InteractiveExecutor) SetSessionLinkedHandler(handler func(sessionID string)) {
+	e.inner.SetSessionLinkedHandler(handler)
+}
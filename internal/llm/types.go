@@ -3,7 +3,7 @@
 package llm
 
 import (
-	"encoding/json"
+	"github.com/getfinn/finn/internal/event"
 )
 
 // Provider represents an LLM provider.
@@ -13,49 +13,14 @@ const (
 	ProviderClaude Provider = "claude"
 	ProviderGemini Provider = "gemini"
 	ProviderCodex  Provider = "codex"
+	ProviderReplay Provider = "replay" // Plays back a recorded Claude transcript (offline testing/demos)
 )
 
-// EventType represents different event types during execution.
-type EventType string
-
-const (
-	EventTypeThinking EventType = "thinking"
-	EventTypeToolUse  EventType = "tool_use"
-	EventTypeDecision EventType = "decision"
-	EventTypeProgress EventType = "progress"
-	EventTypeDiff     EventType = "diff"
-	EventTypeComplete EventType = "complete"
-	EventTypeError    EventType = "error"
-)
-
-// Event represents an event during task execution.
-// All LLM providers emit events in this common format.
-type Event struct {
-	Type    EventType       `json:"type"`
-	Content json.RawMessage `json:"content"`
-}
-
-// EventHandler is called for each event during execution.
-type EventHandler func(Event)
-
-// Decision represents a question requiring user input.
-type Decision struct {
-	Question string   `json:"question"`
-	Options  []Option `json:"options"`
-}
-
-// Option represents a choice in a decision.
-type Option struct {
-	ID          string `json:"id"`
-	Label       string `json:"label"`
-	Description string `json:"description"`
-}
-
 // Executor is the core interface that all LLM providers must implement.
 // This enables swapping between Claude, Gemini, Codex, etc.
 type Executor interface {
 	// ExecuteTask runs a task with the given prompt.
-	// Events are emitted via the EventHandler provided at construction.
+	// Events (see package event) are emitted via the handler provided at construction.
 	ExecuteTask(prompt string) error
 
 	// Provider returns which LLM provider this executor uses.
@@ -92,7 +57,7 @@ type InteractiveExecutor interface {
 type Config struct {
 	Provider    Provider
 	ProjectPath string
-	OnEvent     EventHandler
+	OnEvent     event.Handler // All providers emit the shared event model
 
 	// Provider-specific settings
 	APIKey      string            // For API-based providers (Gemini, Codex)
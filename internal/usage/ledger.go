@@ -0,0 +1,164 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DayFormat is how days are keyed (local time)
+	DayFormat = "2006-01-02"
+
+	// How long daily records are kept
+	retentionDays = 400
+)
+
+// Key identifies a ledger record: one folder, conversation and model on one day
+type Key struct {
+	Day            string `json:"day"` // DayFormat, local time
+	FolderID       string `json:"folder_id"`
+	ConversationID string `json:"conversation_id"`
+	Model          string `json:"model,omitempty"`
+}
+
+// Totals are the tokens and cost accumulated in a record or rollup
+type Totals struct {
+	InputTokens              int64   `json:"input_tokens"`
+	OutputTokens             int64   `json:"output_tokens"`
+	CacheReadInputTokens     int64   `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int64   `json:"cache_creation_input_tokens"`
+	CostUSD                  float64 `json:"cost_usd"`
+	EstimatedCostUSD         float64 `json:"estimated_cost_usd,omitempty"` // Part of CostUSD priced from tokens (no result from Claude)
+	DurationMs               int64   `json:"duration_ms"`
+	Turns                    int     `json:"turns"`
+}
+
+// Add accumulates other into t
+func (t *Totals) Add(other Totals) {
+	t.InputTokens += other.InputTokens
+	t.OutputTokens += other.OutputTokens
+	t.CacheReadInputTokens += other.CacheReadInputTokens
+	t.CacheCreationInputTokens += other.CacheCreationInputTokens
+	t.CostUSD += other.CostUSD
+	t.EstimatedCostUSD += other.EstimatedCostUSD
+	t.DurationMs += other.DurationMs
+	t.Turns += other.Turns
+}
+
+// Record is one ledger row
+type Record struct {
+	Key
+	Totals
+}
+
+// Ledger accumulates Claude usage on disk, keyed by folder, conversation,
+// model and day. It is safe for concurrent use.
+type Ledger struct {
+	path    string
+	mu      sync.Mutex
+	records map[Key]*Totals
+}
+
+// Open loads the ledger at path, starting empty if it doesn't exist yet
+func Open(path string) (*Ledger, error) {
+	l := &Ledger{
+		path:    path,
+		records: make(map[Key]*Totals),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return l, fmt.Errorf("failed to read usage ledger: %w", err)
+	}
+
+	var records []Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return l, fmt.Errorf("failed to parse usage ledger: %w", err)
+	}
+	for i := range records {
+		totals := records[i].Totals
+		l.records[records[i].Key] = &totals
+	}
+	return l, nil
+}
+
+// Add records usage and persists the ledger
+func (l *Ledger) Add(key Key, totals Totals) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record, ok := l.records[key]
+	if !ok {
+		record = &Totals{}
+		l.records[key] = record
+	}
+	record.Add(totals)
+
+	return l.saveLocked()
+}
+
+// ConversationCost returns the total cost recorded for a conversation
+func (l *Ledger) ConversationCost(conversationID string) float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var cost float64
+	for key, totals := range l.records {
+		if key.ConversationID == conversationID {
+			cost += totals.CostUSD
+		}
+	}
+	return cost
+}
+
+// saveLocked writes the ledger, dropping records past retention. Caller holds mu.
+func (l *Ledger) saveLocked() error {
+	cutoff := time.Now().AddDate(0, 0, -retentionDays).Format(DayFormat)
+
+	records := make([]Record, 0, len(l.records))
+	for key, totals := range l.records {
+		if key.Day < cutoff {
+			delete(l.records, key)
+			continue
+		}
+		records = append(records, Record{Key: key, Totals: *totals})
+	}
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i].Key, records[j].Key
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.FolderID != b.FolderID {
+			return a.FolderID < b.FolderID
+		}
+		if a.ConversationID != b.ConversationID {
+			return a.ConversationID < b.ConversationID
+		}
+		return a.Model < b.Model
+	})
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode usage ledger: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to save usage ledger: %w", err)
+	}
+	// Write then rename so a crash never leaves a truncated ledger
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to save usage ledger: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return fmt.Errorf("failed to save usage ledger: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package usage
+
+import "strings"
+
+// price is the cost in USD per million tokens
+type price struct {
+	input, output, cacheRead, cacheWrite float64
+}
+
+// Published API prices by model family. Only used to estimate the cost of a
+// turn still in progress; recorded costs come from Claude's result message.
+var (
+	priceOpusLegacy = price{input: 15, output: 75, cacheRead: 1.5, cacheWrite: 18.75}
+	priceOpus       = price{input: 5, output: 25, cacheRead: 0.5, cacheWrite: 6.25}
+	priceSonnet     = price{input: 3, output: 15, cacheRead: 0.3, cacheWrite: 3.75}
+	priceHaiku      = price{input: 1, output: 5, cacheRead: 0.1, cacheWrite: 1.25}
+	priceHaikuOld   = price{input: 0.8, output: 4, cacheRead: 0.08, cacheWrite: 1}
+)
+
+// priceFor picks the price for a model ID such as "claude-sonnet-4-5-20250929".
+// Unknown models are priced as Sonnet.
+func priceFor(model string) price {
+	model = strings.ToLower(model)
+	switch {
+	case strings.Contains(model, "opus-4-1"), strings.Contains(model, "opus-4-2025"), strings.Contains(model, "3-opus"):
+		return priceOpusLegacy
+	case strings.Contains(model, "opus"):
+		return priceOpus
+	case strings.Contains(model, "3-5-haiku"), strings.Contains(model, "3-haiku"):
+		return priceHaikuOld
+	case strings.Contains(model, "haiku"):
+		return priceHaiku
+	default:
+		return priceSonnet
+	}
+}
+
+// EstimateCost prices token counts for a model
+func EstimateCost(model string, input, output, cacheRead, cacheWrite int) float64 {
+	p := priceFor(model)
+	return (float64(input)*p.input +
+		float64(output)*p.output +
+		float64(cacheRead)*p.cacheRead +
+		float64(cacheWrite)*p.cacheWrite) / 1e6
+}
@@ -0,0 +1,122 @@
+package usage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DayTotals is the usage of one day
+type DayTotals struct {
+	Day string `json:"day"`
+	Totals
+}
+
+// WeekTotals is the usage of one ISO week
+type WeekTotals struct {
+	Week  string `json:"week"`  // e.g. "2026-W42"
+	Start string `json:"start"` // Monday of the week
+	Totals
+}
+
+// ModelTotals is the usage of one model
+type ModelTotals struct {
+	Model string `json:"model"`
+	Totals
+}
+
+// FolderTotals is the usage of one folder, broken down by model
+type FolderTotals struct {
+	FolderID      string        `json:"folder_id"`
+	Conversations int           `json:"conversations"`
+	Models        []ModelTotals `json:"models"`
+	Totals
+}
+
+// Report rolls the ledger up by day, week and folder
+type Report struct {
+	From    string         `json:"from"` // DayFormat, inclusive
+	To      string         `json:"to"`   // DayFormat, inclusive
+	Total   Totals         `json:"total"`
+	Daily   []DayTotals    `json:"daily"`
+	Weekly  []WeekTotals   `json:"weekly"`
+	Folders []FolderTotals `json:"folders"`
+}
+
+// Report rolls up the last days days (including today) of usage. folderID,
+// if set, restricts the report to one folder.
+func (l *Ledger) Report(now time.Time, days int, folderID string) Report {
+	if days < 1 {
+		days = 1
+	}
+	from := now.AddDate(0, 0, -(days - 1)).Format(DayFormat)
+	to := now.Format(DayFormat)
+
+	daily := make(map[string]*Totals)
+	weekly := make(map[string]*WeekTotals)
+	folders := make(map[string]*FolderTotals)
+	folderModels := make(map[string]map[string]*Totals)
+	folderConversations := make(map[string]map[string]bool)
+	report := Report{From: from, To: to}
+
+	l.mu.Lock()
+	for key, totals := range l.records {
+		if key.Day < from || key.Day > to || (folderID != "" && key.FolderID != folderID) {
+			continue
+		}
+		report.Total.Add(*totals)
+
+		if daily[key.Day] == nil {
+			daily[key.Day] = &Totals{}
+		}
+		daily[key.Day].Add(*totals)
+
+		if day, err := time.ParseInLocation(DayFormat, key.Day, now.Location()); err == nil {
+			year, week := day.ISOWeek()
+			name := fmt.Sprintf("%d-W%02d", year, week)
+			if weekly[name] == nil {
+				monday := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+				weekly[name] = &WeekTotals{Week: name, Start: monday.Format(DayFormat)}
+			}
+			weekly[name].Add(*totals)
+		}
+
+		if folders[key.FolderID] == nil {
+			folders[key.FolderID] = &FolderTotals{FolderID: key.FolderID}
+			folderModels[key.FolderID] = make(map[string]*Totals)
+			folderConversations[key.FolderID] = make(map[string]bool)
+		}
+		folders[key.FolderID].Add(*totals)
+		folderConversations[key.FolderID][key.ConversationID] = true
+		if folderModels[key.FolderID][key.Model] == nil {
+			folderModels[key.FolderID][key.Model] = &Totals{}
+		}
+		folderModels[key.FolderID][key.Model].Add(*totals)
+	}
+	l.mu.Unlock()
+
+	report.Daily = make([]DayTotals, 0, len(daily))
+	for day, totals := range daily {
+		report.Daily = append(report.Daily, DayTotals{Day: day, Totals: *totals})
+	}
+	sort.Slice(report.Daily, func(i, j int) bool { return report.Daily[i].Day < report.Daily[j].Day })
+
+	report.Weekly = make([]WeekTotals, 0, len(weekly))
+	for _, week := range weekly {
+		report.Weekly = append(report.Weekly, *week)
+	}
+	sort.Slice(report.Weekly, func(i, j int) bool { return report.Weekly[i].Start < report.Weekly[j].Start })
+
+	report.Folders = make([]FolderTotals, 0, len(folders))
+	for id, folder := range folders {
+		folder.Conversations = len(folderConversations[id])
+		for model, totals := range folderModels[id] {
+			folder.Models = append(folder.Models, ModelTotals{Model: model, Totals: *totals})
+		}
+		sort.Slice(folder.Models, func(i, j int) bool { return folder.Models[i].CostUSD > folder.Models[j].CostUSD })
+		report.Folders = append(report.Folders, *folder)
+	}
+	sort.Slice(report.Folders, func(i, j int) bool { return report.Folders[i].CostUSD > report.Folders[j].CostUSD })
+
+	return report
+}
@@ -21,7 +21,15 @@ const (
 	MessageTypeDecision       MessageType = "decision"
 	MessageTypeChoice         MessageType = "choice"
 	MessageTypeThinking       MessageType = "thinking"
+	MessageTypeThinkingDelta  MessageType = "thinking_delta" // Desktop → Mobile: Streamed partial assistant text
 	MessageTypeToolUse        MessageType = "tool_use"
+	MessageTypeToolResult     MessageType = "tool_result"
+	MessageTypeGetToolOutput  MessageType = "get_tool_output" // Mobile → Desktop: Fetch full output of a truncated tool_result
+	MessageTypeToolOutput     MessageType = "tool_output"     // Desktop → Mobile: Full tool output response
+	MessageTypeGetCLIHealth   MessageType = "get_cli_health"  // Mobile → Desktop: Probe the Claude Code CLI
+	MessageTypeCLIHealth      MessageType = "cli_health"      // Desktop → Mobile: Installed, version and auth state
+	MessageTypeTaskParked     MessageType = "task_parked"     // Desktop → Mobile: Task waiting for usage limit reset
+	MessageTypeTaskResumed    MessageType = "task_resumed"    // Desktop → Mobile: Parked task resumed after reset
 	MessageTypeProgress       MessageType = "progress"
 	MessageTypeDiff           MessageType = "diff"
 	MessageTypeApproval       MessageType = "approval"
@@ -38,16 +46,67 @@ const (
 	MessageTypeCommitsList      MessageType = "commits_list"       // Desktop → Mobile: Commit list response
 	MessageTypeGetCommitDetail  MessageType = "get_commit_detail"  // Mobile → Desktop: Request single commit details
 	MessageTypeCommitDetail     MessageType = "commit_detail"      // Desktop → Mobile: Single commit details response
+	MessageTypeGetCommitConversation MessageType = "get_commit_conversation" // Mobile → Desktop: Request the conversation a commit came from
+	MessageTypeCommitConversation    MessageType = "commit_conversation"     // Desktop → Mobile: Linked conversation, session and transcript
 	MessageTypeSessionLinked    MessageType = "session_linked"     // Desktop → Relay: Link conversation_id with session_id
 
+	// Slash commands
+	MessageTypeListCommands MessageType = "list_commands" // Mobile → Desktop: Request built-in and custom commands of a folder
+	MessageTypeCommandsList MessageType = "commands_list" // Desktop → Mobile: Commands with descriptions and argument hints
+	MessageTypeRunCommand   MessageType = "run_command"   // Mobile → Desktop: Run a slash command (prompt variant)
+
+	// Branch management
+	MessageTypeListBranches   MessageType = "list_branches"   // Mobile → Desktop: Request local and remote branches of a folder
+	MessageTypeBranchesList   MessageType = "branches_list"   // Desktop → Mobile: Branches with upstream, ahead/behind and last commit
+	MessageTypeCreateBranch   MessageType = "create_branch"   // Mobile → Desktop: Create a branch (optionally check it out)
+	MessageTypeSwitchBranch   MessageType = "switch_branch"   // Mobile → Desktop: Check out a branch (optionally stashing changes)
+	MessageTypeDeleteBranch   MessageType = "delete_branch"   // Mobile → Desktop: Delete a local branch
+	MessageTypeBranchResponse MessageType = "branch_response" // Desktop → Mobile: Outcome of create/switch/delete_branch
+
+	// Remote sync
+	MessageTypeGitFetch      MessageType = "git_fetch"       // Mobile → Desktop: Fetch all remotes of a folder
+	MessageTypeGitPull       MessageType = "git_pull"        // Mobile → Desktop: Pull the current branch (merge or rebase)
+	MessageTypeGitPush       MessageType = "git_push"        // Mobile → Desktop: Push the current branch, setting its upstream if needed
+	MessageTypeGitSyncResult MessageType = "git_sync_result" // Desktop → Mobile: Outcome of a fetch/pull/push with ahead/behind
+
+	// Merge and rebase conflicts
+	MessageTypeGetConflicts     MessageType = "get_conflicts"     // Mobile → Desktop: Request the conflicted files of a folder
+	MessageTypeConflicts        MessageType = "conflicts"         // Desktop → Mobile: Operation in progress and conflicted files with hunks
+	MessageTypeResolveConflicts MessageType = "resolve_conflicts" // Mobile → Desktop: Let Claude resolve the conflicts (reviewed like any task)
+	MessageTypeConflictAction   MessageType = "conflict_action"   // Mobile → Desktop: Continue or abort the merge/rebase
+	MessageTypeConflictResult   MessageType = "conflict_result"   // Desktop → Mobile: Outcome of continuing or aborting
+
+	// Commit hooks
+	MessageTypeCommitBlocked       MessageType = "commit_blocked"        // Desktop → Mobile: A hook blocked the approval commit
+	MessageTypeCommitBlockedAction MessageType = "commit_blocked_action" // Mobile → Desktop: Fix, commit with --no-verify, or abort
+
+	// Revert and undo
+	MessageTypeRevertCommit   MessageType = "revert_commit"    // Mobile → Desktop: Revert a commit with a new commit
+	MessageTypeUndoLastCommit MessageType = "undo_last_commit" // Mobile → Desktop: Undo the last Finn commit (reset if unpushed, else revert)
+	MessageTypeRevertResult   MessageType = "revert_result"    // Desktop → Mobile: Outcome of a revert or undo
+
+	// Prompt references (@file, @commit:, @session:, @dirty)
+	MessageTypeResolveReferences  MessageType = "resolve_references"  // Mobile → Desktop: Autocomplete a reference or resolve a draft's references
+	MessageTypeReferencesResolved MessageType = "references_resolved" // Desktop → Mobile: Suggestions and resolved references
+
+	// Claude process governor
+	MessageTypeGetResourceStatus MessageType = "get_resource_status" // Mobile → Desktop: Request Claude process usage
+	MessageTypeResourceStatus    MessageType = "resource_status"     // Desktop → Mobile: Running processes vs. limits
+
+	// Usage ledger and budgets
+	MessageTypeGetUsageReport MessageType = "get_usage_report" // Mobile → Desktop: Request daily/weekly/per-folder usage
+	MessageTypeUsageReport    MessageType = "usage_report"     // Desktop → Mobile: Usage rollups and folder budgets
+	MessageTypeBudgetAlert    MessageType = "budget_alert"     // Desktop → Mobile: Conversation crossed a soft or hard budget
+
 	// Live Preview (Pro/Max only)
 	MessageTypePreviewStart  MessageType = "preview_start"  // Mobile/Web → Desktop: Start preview for folder
 	MessageTypePreviewReady  MessageType = "preview_ready"  // Desktop → Mobile/Web: Preview URL is ready
 	MessageTypePreviewStop   MessageType = "preview_stop"   // Mobile/Web → Desktop: Stop preview
 	MessageTypePreviewStatus MessageType = "preview_status" // Desktop → Mobile/Web: Preview status update
 
-	// maxMessageSize is the maximum message size allowed (512 KB)
-	maxMessageSize = 512 * 1024
+	// maxMessageSize is the maximum message size allowed (16 MB, fits prompts
+	// with base64 attachments)
+	maxMessageSize = 16 * 1024 * 1024
 
 	// pingInterval is how often we send pings to keep connection alive
 	pingInterval = 30 * time.Second